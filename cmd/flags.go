@@ -27,6 +27,14 @@ func SchedulerFlags() {
 	pflag.String("scheduler.downloadPath", "/data/current", "Download path")
 	pflag.String("scheduler.uploadPath", "/data/processed", "Upload path")
 	pflag.Int64("scheduler.minFileSize", 1e+8, "Min File Size")
+	pflag.String("scheduler.defaultProfile", "default", "Encoding profile used when a job does not request one")
+	pflag.Bool("scheduler.chunking.enabled", false, "Split long jobs at keyframes into segments encoded in parallel by several workers")
+	pflag.Duration("scheduler.chunking.segmentDuration", time.Minute*5, "Target duration of each segment of a chunked job")
+	pflag.Duration("scheduler.chunking.minDuration", time.Minute*30, "Minimum source duration to split a job in segments")
+	pflag.String("scheduler.samples.path", "/data/samples", "Path where the clips of sample jobs are stored, empty disables sample jobs")
+	pflag.Duration("scheduler.samples.retention", time.Hour*24, "Remove sample jobs and their clips after this time")
+	pflag.Int("scheduler.samples.clips", 3, "Default number of clips encoded by a sample job")
+	pflag.Duration("scheduler.samples.clipDuration", time.Second*20, "Default duration of each clip of a sample job")
 }
 
 func WebFlags() {
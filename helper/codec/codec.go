@@ -1,25 +1,99 @@
 package codec
 
 import (
+	"fmt"
 	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 var (
-	x264Regex = regexp.MustCompile(`(?i)(((x|h)264)|mpeg-4|mpeg-1|mpeg-2|mpeg|xvid|divx|vc-1|av1|vp8|vp9|wmv3|mp43)`)
-	ac3Regex  = regexp.MustCompile(`(?i)(ac3|eac3|pcm|flac|mp2|dts|mp2|mp3|truehd|wma|vorbis|opus|mpeg audio)`)
+	videoRegex = regexp.MustCompile(`(?i)(((x|h)26[45])|hevc|mpeg-4|mpeg-1|mpeg-2|mpeg|xvid|divx|vc-1|av1|vp8|vp9|wmv3|mp43)`)
+	ac3Regex   = regexp.MustCompile(`(?i)(ac3|eac3|pcm|flac|mp2|dts|mp2|mp3|truehd|wma|vorbis|opus|mpeg audio)`)
 )
 
-func NeedsTranscoding(path string) bool {
-	return x264Regex.MatchString(path)
+// Target describes an output video codec: the ffmpeg encoder used to produce
+// it, the ffprobe codec names already in that format, the token used in file
+// names and whether ffmpeg can run a two-pass encode with it.
+type Target struct {
+	Encoder    string
+	Name       string
+	CodecNames []string
+	TwoPass    bool
 }
 
-func FormatTargetName(path string) string {
-	p := x264Regex.ReplaceAllString(path, "x265")
+var (
+	X265 = Target{Encoder: "libx265", Name: "x265", CodecNames: []string{"hevc", "h265", "x265"}, TwoPass: true}
+	AV1  = Target{Encoder: "libsvtav1", Name: "AV1", CodecNames: []string{"av1"}}
+	X264 = Target{Encoder: "libx264", Name: "x264", CodecNames: []string{"h264", "x264"}, TwoPass: true}
+
+	Targets = []Target{X265, AV1, X264}
+)
+
+// TargetByEncoder returns the target produced by an ffmpeg encoder.
+func TargetByEncoder(encoder string) (Target, error) {
+	for _, target := range Targets {
+		if target.Encoder == encoder {
+			return target, nil
+		}
+	}
+	return Target{}, fmt.Errorf("unsupported video encoder %s", encoder)
+}
+
+// IsTargetCodec reports whether a codec name, as reported by ffprobe or found
+// in a file name, is already the target codec.
+func (t Target) IsTargetCodec(codecName string) bool {
+	for _, name := range t.CodecNames {
+		if strings.EqualFold(name, codecName) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsTranscoding reports whether the file name advertises a video codec
+// different from the target one.
+func (t Target) NeedsTranscoding(path string) bool {
+	for _, match := range videoRegex.FindAllString(path, -1) {
+		if !t.IsTargetCodec(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatTargetName rewrites the codec tokens of the file name to the target
+// codec and AAC audio, and changes the extension to the output container one.
+func (t Target) FormatTargetName(path string, container string) string {
+	p := videoRegex.ReplaceAllStringFunc(path, func(match string) string {
+		if t.IsTargetCodec(match) {
+			return match
+		}
+		return t.Name
+	})
 	p = ac3Regex.ReplaceAllString(p, "AAC")
 	extension := filepath.Ext(p)
 	if extension != "" {
-		p = p[:len(p)-len(extension)] + ".mkv"
+		p = p[:len(p)-len(extension)] + "." + container
 	}
 	return p
 }
+
+// interlacedCodecNames are the ffprobe names of the codecs of DVD and broadcast
+// captures, often interlaced even when the stream is flagged progressive.
+var interlacedCodecNames = []string{"mpeg2video", "vc1"}
+
+// MayBeInterlaced reports whether a video stream may be interlaced, from its
+// ffprobe codec name and field order.
+func MayBeInterlaced(codecName string, fieldOrder string) bool {
+	switch fieldOrder {
+	case "tt", "bb", "tb", "bt":
+		return true
+	}
+	for _, name := range interlacedCodecNames {
+		if strings.EqualFold(name, codecName) {
+			return true
+		}
+	}
+	return false
+}
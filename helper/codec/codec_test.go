@@ -4,7 +4,7 @@ import (
 	"testing"
 )
 
-func TestNeedsTranscoding(t *testing.T) {
+func TestX265NeedsTranscoding(t *testing.T) {
 	tests := []struct {
 		name     string
 		path     string
@@ -64,7 +64,7 @@ func TestNeedsTranscoding(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := NeedsTranscoding(tt.path)
+			result := X265.NeedsTranscoding(tt.path)
 			if result != tt.expected {
 				t.Errorf("NeedsTranscoding(%q) = %v, want %v", tt.path, result, tt.expected)
 			}
@@ -72,7 +72,7 @@ func TestNeedsTranscoding(t *testing.T) {
 	}
 }
 
-func TestFormatTargetName(t *testing.T) {
+func TestX265FormatTargetName(t *testing.T) {
 	tests := []struct {
 		name     string
 		path     string
@@ -112,10 +112,106 @@ func TestFormatTargetName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := FormatTargetName(tt.path)
+			result := X265.FormatTargetName(tt.path, "mkv")
 			if result != tt.expected {
 				t.Errorf("FormatTargetName(%q) = %q, want %q", tt.path, result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestTargetNeedsTranscoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   Target
+		path     string
+		expected bool
+	}{
+		{name: "x265 file with x265 target", target: X265, path: "/path/to/video.x265.mkv", expected: false},
+		{name: "x265 file with av1 target", target: AV1, path: "/path/to/video.x265.mkv", expected: true},
+		{name: "hevc file with av1 target", target: AV1, path: "/path/to/video.HEVC.mkv", expected: true},
+		{name: "av1 file with av1 target", target: AV1, path: "/path/to/video.AV1.mkv", expected: false},
+		{name: "av1 file with x265 target", target: X265, path: "/path/to/video.av1.mkv", expected: true},
+		{name: "h264 file with x264 target", target: X264, path: "/path/to/video.h264.mkv", expected: false},
+		{name: "xvid file with x264 target", target: X264, path: "/path/to/video.xvid.avi", expected: true},
+		{name: "path without codec info", target: AV1, path: "/path/to/video.mkv", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.target.NeedsTranscoding(tt.path)
+			if result != tt.expected {
+				t.Errorf("%s.NeedsTranscoding(%q) = %v, want %v", tt.target.Name, tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTargetFormatTargetName(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    Target
+		container string
+		path      string
+		expected  string
+	}{
+		{name: "x264 to AV1", target: AV1, container: "mkv", path: "/path/to/video.x264.DTS.mp4", expected: "/path/to/video.AV1.AAC.mkv"},
+		{name: "hevc to AV1", target: AV1, container: "mkv", path: "/path/to/video.hevc.mkv", expected: "/path/to/video.AV1.mkv"},
+		{name: "xvid to x264", target: X264, container: "mkv", path: "/path/to/video.XviD.avi", expected: "/path/to/video.x264.mkv"},
+		{name: "h264 kept with x264 target", target: X264, container: "mkv", path: "/path/to/video.h264.mkv", expected: "/path/to/video.h264.mkv"},
+		{name: "mkv to mp4", target: X264, container: "mp4", path: "/path/to/video.XviD.AC3.mkv", expected: "/path/to/video.x264.AAC.mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.target.FormatTargetName(tt.path, tt.container)
+			if result != tt.expected {
+				t.Errorf("%s.FormatTargetName(%q) = %q, want %q", tt.target.Name, tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTargetByEncoder(t *testing.T) {
+	for _, target := range Targets {
+		found, err := TargetByEncoder(target.Encoder)
+		if err != nil || found.Name != target.Name {
+			t.Errorf("TargetByEncoder(%q) = %v, %v", target.Encoder, found, err)
+		}
+	}
+	if _, err := TargetByEncoder("libtheora"); err == nil {
+		t.Error("expected error for unsupported encoder")
+	}
+}
+
+func TestTargetIsTargetCodec(t *testing.T) {
+	if !X265.IsTargetCodec("hevc") {
+		t.Error("hevc should be x265 target codec")
+	}
+	if X265.IsTargetCodec("av1") {
+		t.Error("av1 should not be x265 target codec")
+	}
+	if !AV1.IsTargetCodec("av1") {
+		t.Error("av1 should be AV1 target codec")
+	}
+}
+
+func TestMayBeInterlaced(t *testing.T) {
+	tests := []struct {
+		codecName  string
+		fieldOrder string
+		expected   bool
+	}{
+		{"mpeg2video", "progressive", true},
+		{"vc1", "", true},
+		{"h264", "tt", true},
+		{"h264", "progressive", false},
+		{"hevc", "unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := MayBeInterlaced(tt.codecName, tt.fieldOrder); got != tt.expected {
+			t.Errorf("MayBeInterlaced(%q, %q) = %v, want %v", tt.codecName, tt.fieldOrder, got, tt.expected)
+		}
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 )
 
 type ReaderFunc func(buffer []byte, exit bool)
+
+// ProcessFunc receives the process of the command once it is started.
+type ProcessFunc func(process *os.Process)
 type Option struct {
 	PanicOnError bool
 	AllowedCodes []int
@@ -23,6 +27,7 @@ type Command struct {
 	WorkDir    string
 	StdoutFunc ReaderFunc
 	SterrFunc  ReaderFunc
+	StartFunc  ProcessFunc
 }
 
 func NewPanicOption() Option {
@@ -35,9 +40,6 @@ func NewAllowedCodesOption(codes ...int) Option {
 		AllowedCodes: codes,
 	}
 }
-func NewCommandByString(command string, params string) *Command {
-	return NewCommand(command, StringToSlice(params)...)
-}
 func NewCommand(command string, params ...string) *Command {
 	cmd := &Command{
 		Command: command,
@@ -53,6 +55,11 @@ func (C *Command) AddParam(param string) *Command {
 	return C
 }
 
+func (C *Command) AddParams(params ...string) *Command {
+	C.Params = append(C.Params, params...)
+	return C
+}
+
 func (C *Command) SetWorkDir(workDir string) *Command {
 	C.WorkDir = workDir
 	return C
@@ -76,6 +83,11 @@ func (C *Command) SetStderrFunc(StderrtFunc ReaderFunc) *Command {
 	C.SterrFunc = StderrtFunc
 	return C
 }
+
+func (C *Command) SetStartFunc(startFunc ProcessFunc) *Command {
+	C.StartFunc = startFunc
+	return C
+}
 func (C *Command) Run(opt ...Option) (exitCode int, err error) {
 	return C.RunWithContext(context.Background(), opt...)
 }
@@ -101,9 +113,22 @@ func (C *Command) RunWithContext(ctx context.Context, opt ...Option) (exitCode i
 	if err = cmd.Start(); err != nil {
 		return -1, err
 	}
+	if C.StartFunc != nil {
+		C.StartFunc(cmd.Process)
+	}
 
-	go C.readerStreamProcessor(ctx, stdout, C.StdoutFunc)
-	go C.readerStreamProcessor(ctx, stderr, C.SterrFunc)
+	// Wait closes the pipes, all the output has to be read before
+	readers := sync.WaitGroup{}
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		C.readerStreamProcessor(ctx, stdout, C.StdoutFunc)
+	}()
+	go func() {
+		defer readers.Done()
+		C.readerStreamProcessor(ctx, stderr, C.SterrFunc)
+	}()
+	readers.Wait()
 
 	err = cmd.Wait()
 	if err != nil {
@@ -171,8 +196,21 @@ loop:
 	}
 }
 
+// GetFullCommand returns the command line for logs, quoting the parameters
+// that a shell would split or expand.
 func (C *Command) GetFullCommand() string {
-	return fmt.Sprintf("%s %s", C.Command, strings.Join(C.Params, " "))
+	params := make([]string, len(C.Params))
+	for i, param := range C.Params {
+		params[i] = quoteParam(param)
+	}
+	return fmt.Sprintf("%s %s", C.Command, strings.Join(params, " "))
+}
+
+func quoteParam(param string) string {
+	if param != "" && !strings.ContainsAny(param, " \t\n'\"\\$`*?![]{}()<>|&;#~") {
+		return param
+	}
+	return "'" + strings.ReplaceAll(param, "'", `'\''`) + "'"
 }
 
 func GetWD() string {
@@ -182,30 +220,3 @@ func GetWD() string {
 	}
 	return path
 }
-
-func StringToSlice(command string) (output []string) {
-	cutDoubleQuote := true
-	cutQuote := true
-	inLineWord := ""
-	for _, c := range command {
-		if c == ' ' && cutDoubleQuote && cutQuote {
-			if len(inLineWord) > 0 {
-				if inLineWord[0] == '\'' {
-					inLineWord = strings.Trim(inLineWord, "'")
-				} else if inLineWord[0] == '"' {
-					inLineWord = strings.Trim(inLineWord, "\"")
-				}
-				output = append(output, inLineWord)
-				inLineWord = ""
-			}
-			continue
-		} else if c == '"' {
-			cutDoubleQuote = !cutDoubleQuote
-		} else if c == '\'' {
-			cutQuote = !cutQuote
-		}
-		inLineWord = inLineWord + string(c)
-	}
-	output = append(output, inLineWord)
-	return output
-}
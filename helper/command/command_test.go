@@ -1,63 +1,13 @@
 package command
 
 import (
+	"os"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
-func TestStringToSlice(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected []string
-	}{
-		{
-			name:     "simple command",
-			input:    "ffmpeg -i input.mp4 output.mp4",
-			expected: []string{"ffmpeg", "-i", "input.mp4", "output.mp4"},
-		},
-		{
-			name:     "double quoted word in middle",
-			input:    `cmd "hello world" rest`,
-			expected: []string{"cmd", "hello world", "rest"},
-		},
-		{
-			name:     "single quoted word in middle",
-			input:    `cmd 'hello world' rest`,
-			expected: []string{"cmd", "hello world", "rest"},
-		},
-		{
-			name:     "empty string",
-			input:    "",
-			expected: []string{""},
-		},
-		{
-			name:     "single word",
-			input:    "ffmpeg",
-			expected: []string{"ffmpeg"},
-		},
-		{
-			name:     "multiple spaces",
-			input:    "cmd   arg1    arg2",
-			expected: []string{"cmd", "arg1", "arg2"},
-		},
-		{
-			name:     "quoted word followed by word",
-			input:    `ffmpeg -i "input file.mp4" -c:v libx265`,
-			expected: []string{"ffmpeg", "-i", "input file.mp4", "-c:v", "libx265"},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := StringToSlice(tt.input)
-			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("StringToSlice(%q) = %v, want %v", tt.input, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestGetWD(t *testing.T) {
 	wd := GetWD()
 	if wd == "" {
@@ -81,6 +31,11 @@ func TestGetFullCommand(t *testing.T) {
 			cmd:      NewCommand("echo", "hello", "world"),
 			expected: "echo hello world",
 		},
+		{
+			name:     "quoted params",
+			cmd:      NewCommand("ffmpeg", "-i", "/media/Movie (2020) it's.mkv", "-metadata:s:a:0", `title=Director "Cut"`, ""),
+			expected: `ffmpeg -i '/media/Movie (2020) it'\''s.mkv' -metadata:s:a:0 'title=Director "Cut"' ''`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -110,16 +65,6 @@ func TestNewAllowedCodesOption(t *testing.T) {
 	}
 }
 
-func TestNewCommandByString(t *testing.T) {
-	cmd := NewCommandByString("ffmpeg", "-i input.mp4 output.mp4")
-	if cmd.Command != "ffmpeg" {
-		t.Errorf("Command = %q, want %q", cmd.Command, "ffmpeg")
-	}
-	if len(cmd.Params) != 3 {
-		t.Errorf("len(Params) = %d, want 3", len(cmd.Params))
-	}
-}
-
 func TestCommand_AddParam(t *testing.T) {
 	cmd := NewCommand("ffmpeg")
 	result := cmd.AddParam("-i")
@@ -199,6 +144,20 @@ func TestCommand_SetStderrFunc(t *testing.T) {
 	}
 }
 
+func TestCommand_SetStartFunc(t *testing.T) {
+	pid := 0
+	cmd := NewCommand("echo", "hello").SetStartFunc(func(process *os.Process) {
+		pid = process.Pid
+	})
+
+	if _, err := cmd.Run(); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if pid == 0 {
+		t.Error("StartFunc was not called with the started process")
+	}
+}
+
 func TestCommand_Run_Success(t *testing.T) {
 	cmd := NewCommand("echo", "hello")
 	exitCode, err := cmd.Run()
@@ -333,3 +292,28 @@ func TestAllowedCodes(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_AddParams(t *testing.T) {
+	cmd := NewCommand("mkvextract", "tracks").AddParams("source.mkv", "3:3.sup")
+	if !reflect.DeepEqual(cmd.Params, []string{"tracks", "source.mkv", "3:3.sup"}) {
+		t.Errorf("Params = %v", cmd.Params)
+	}
+}
+
+func TestCommand_RunKeepsParams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("printf is not available on windows")
+	}
+	params := []string{"it's a \"movie\".mkv", "$HOME", "a  b", "*", "-x;rm -rf /", "`id`"}
+	output := ""
+	cmd := NewCommand("printf", append([]string{"%s\\n"}, params...)...).
+		SetStdoutFunc(func(buffer []byte, exit bool) {
+			output += string(buffer)
+		})
+	if _, err := cmd.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n"); !reflect.DeepEqual(lines, params) {
+		t.Errorf("printf received %q, want %q", lines, params)
+	}
+}
@@ -0,0 +1,109 @@
+// Package detect analyses windows of a video with ffmpeg filters, finding the
+// black bars to crop and whether the source is interlaced.
+package detect
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	CropDetectSamples       = 8
+	CropDetectSampleSeconds = 2
+	// cropDetectFilter keeps the bounding box of every non black frame of the
+	// window, the limit is relative so it works for any bit depth.
+	cropDetectFilter = "cropdetect=limit=0.094:round=2:reset=0"
+)
+
+var cropRegex = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(-?\d+):(-?\d+)`)
+
+// Crop is a rectangle of the source frame, as taken by the ffmpeg crop filter.
+type Crop struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+func (C *Crop) Filter() string {
+	return fmt.Sprintf("crop=%d:%d:%d:%d", C.Width, C.Height, C.X, C.Y)
+}
+
+// CropSamples returns the start of each window analysed by cropdetect, spread
+// evenly over the source leaving out the beginning and the end, usually logos
+// and credits.
+func CropSamples(duration float64) []float64 {
+	if duration <= CropDetectSampleSeconds {
+		return []float64{0}
+	}
+	starts := make([]float64, CropDetectSamples)
+	for i := range starts {
+		starts[i] = (duration - CropDetectSampleSeconds) * float64(i+1) / float64(CropDetectSamples+1)
+	}
+	return starts
+}
+
+// CropDetectArguments are the ffmpeg arguments running cropdetect on the
+// window of the video stream starting at start.
+func CropDetectArguments(sourcePath string, stream int, start float64) []string {
+	return []string{"-hide_banner", "-nostats",
+		"-ss", fmt.Sprintf("%.3f", start), "-t", strconv.Itoa(CropDetectSampleSeconds), "-i", sourcePath,
+		"-map", fmt.Sprintf("0:%d", stream), "-vf", cropDetectFilter, "-f", "null", "-"}
+}
+
+// ParseCrop reads the last rectangle logged by cropdetect, or the one of a
+// crop filter. Windows of black frames log an empty rectangle and return nil.
+func ParseCrop(output string) *Crop {
+	matches := cropRegex.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	values := make([]int, 4)
+	for i := range values {
+		values[i], _ = strconv.Atoi(matches[len(matches)-1][i+1])
+	}
+	crop := &Crop{Width: values[0], Height: values[1], X: values[2], Y: values[3]}
+	if crop.Width <= 0 || crop.Height <= 0 || crop.X < 0 || crop.Y < 0 {
+		return nil
+	}
+	return crop
+}
+
+// MergeCrops settles on the smallest rectangle holding every sampled one, so
+// no window loses picture, with even dimensions as the encoders need. It
+// returns nil when there is nothing to crop.
+func MergeCrops(crops []*Crop, width int, height int) *Crop {
+	if len(crops) == 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+	left, top := width, height
+	right, bottom := 0, 0
+	for _, crop := range crops {
+		left = min(left, crop.X)
+		top = min(top, crop.Y)
+		right = max(right, crop.X+crop.Width)
+		bottom = max(bottom, crop.Y+crop.Height)
+	}
+	merged := &Crop{
+		X:      left,
+		Y:      top,
+		Width:  evenSize(min(right, width)-left, width-left),
+		Height: evenSize(min(bottom, height)-top, height-top),
+	}
+	if merged.Width >= width && merged.Height >= height {
+		return nil
+	}
+	return merged
+}
+
+// evenSize rounds size up to an even number, or down when it would not fit.
+func evenSize(size int, available int) int {
+	if size%2 == 0 {
+		return size
+	}
+	if size+1 <= available {
+		return size + 1
+	}
+	return size - 1
+}
@@ -0,0 +1,99 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCrop(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected *Crop
+	}{
+		{
+			name: "last rectangle",
+			output: "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:142 y2:937 w:1920 h:796 x:0 y:142 pts:12 t:0.5 crop=1920:796:0:142\n" +
+				"[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:140 y2:939 w:1920 h:800 x:0 y:140 pts:24 t:1.0 crop=1920:800:0:140\n",
+			expected: &Crop{Width: 1920, Height: 800, X: 0, Y: 140},
+		},
+		{
+			name:     "black frames",
+			output:   "[Parsed_cropdetect_0 @ 0x1] x1:1919 x2:0 y1:1079 y2:0 w:-1904 h:-1072 x:1912 y:1076 pts:24 t:1.0 crop=-1904:-1072:1912:1076\n",
+			expected: nil,
+		},
+		{
+			name:     "crop filter",
+			output:   "crop=1920:800:0:140",
+			expected: &Crop{Width: 1920, Height: 800, X: 0, Y: 140},
+		},
+		{
+			name:     "no output",
+			output:   "",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCrop(tt.output); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseCrop() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeCrops(t *testing.T) {
+	tests := []struct {
+		name     string
+		crops    []*Crop
+		expected *Crop
+	}{
+		{
+			name:     "stable letterbox",
+			crops:    []*Crop{{Width: 1920, Height: 800, X: 0, Y: 140}, {Width: 1920, Height: 800, X: 0, Y: 140}},
+			expected: &Crop{Width: 1920, Height: 800, X: 0, Y: 140},
+		},
+		{
+			name:     "dark scene keeps the widest rectangle",
+			crops:    []*Crop{{Width: 1920, Height: 800, X: 0, Y: 140}, {Width: 1600, Height: 600, X: 160, Y: 240}},
+			expected: &Crop{Width: 1920, Height: 800, X: 0, Y: 140},
+		},
+		{
+			name:     "pillarbox and letterbox",
+			crops:    []*Crop{{Width: 1440, Height: 1040, X: 240, Y: 20}, {Width: 1436, Height: 1040, X: 240, Y: 21}},
+			expected: &Crop{Width: 1440, Height: 1042, X: 240, Y: 20},
+		},
+		{
+			name:     "full frame",
+			crops:    []*Crop{{Width: 1920, Height: 1080, X: 0, Y: 0}, {Width: 1920, Height: 800, X: 0, Y: 140}},
+			expected: nil,
+		},
+		{
+			name:     "nothing detected",
+			crops:    nil,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeCrops(tt.crops, 1920, 1080); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("MergeCrops() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCropSamples(t *testing.T) {
+	starts := CropSamples(3600)
+	if len(starts) != CropDetectSamples {
+		t.Fatalf("CropSamples() returned %d windows, want %d", len(starts), CropDetectSamples)
+	}
+	if starts[0] <= 0 || starts[len(starts)-1]+CropDetectSampleSeconds >= 3600 {
+		t.Errorf("CropSamples() = %v should leave out the beginning and the end", starts)
+	}
+	if starts := CropSamples(1); len(starts) != 1 || starts[0] != 0 {
+		t.Errorf("CropSamples(1) = %v, want [0]", starts)
+	}
+}
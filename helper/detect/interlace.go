@@ -0,0 +1,90 @@
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"gearr/model"
+	"regexp"
+	"strconv"
+)
+
+const (
+	idetWindows = 4
+	// idetSampleFrames is enough frames per window for the multi frame
+	// detection to settle.
+	idetSampleFrames = 500
+)
+
+var idetRegex = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)\s*Undetermined:\s*(\d+)`)
+
+// IdetResult counts the frames classified by the ffmpeg idet filter.
+type IdetResult struct {
+	TFF          int
+	BFF          int
+	Progressive  int
+	Undetermined int
+}
+
+func (I *IdetResult) Add(result IdetResult) {
+	I.TFF += result.TFF
+	I.BFF += result.BFF
+	I.Progressive += result.Progressive
+	I.Undetermined += result.Undetermined
+}
+
+// Interlaced reports whether most of the classified frames are interlaced.
+func (I IdetResult) Interlaced() bool {
+	return I.TFF+I.BFF > I.Progressive
+}
+
+// Parity is the field order of the interlaced frames, as taken by the
+// deinterlacing filters.
+func (I IdetResult) Parity() string {
+	if I.BFF > I.TFF {
+		return "bff"
+	}
+	return "tff"
+}
+
+func (I IdetResult) String() string {
+	return fmt.Sprintf("TFF: %d, BFF: %d, progressive: %d, undetermined: %d", I.TFF, I.BFF, I.Progressive, I.Undetermined)
+}
+
+// ParseIdet reads the multi frame detection summary logged by idet.
+func ParseIdet(output string) (IdetResult, error) {
+	matches := idetRegex.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return IdetResult{}, errors.New("no idet summary found in ffmpeg output")
+	}
+	values := make([]int, 4)
+	for i := range values {
+		values[i], _ = strconv.Atoi(matches[len(matches)-1][i+1])
+	}
+	return IdetResult{TFF: values[0], BFF: values[1], Progressive: values[2], Undetermined: values[3]}, nil
+}
+
+// DeinterlaceFilter deinterlaces every frame, keeping the frame rate.
+func DeinterlaceFilter(deinterlacer string, parity string) string {
+	if deinterlacer == "" {
+		deinterlacer = model.DeinterlacerBwdif
+	}
+	return fmt.Sprintf("%s=mode=send_frame:parity=%s:deint=all", deinterlacer, parity)
+}
+
+// IdetSamples returns the start of each window analysed by idet, spread evenly
+// over the source.
+func IdetSamples(duration float64) []float64 {
+	starts := make([]float64, idetWindows)
+	for i := range starts {
+		starts[i] = duration * float64(i+1) / float64(idetWindows+1)
+	}
+	return starts
+}
+
+// IdetArguments are the ffmpeg arguments running idet on the window of the
+// video stream starting at start.
+func IdetArguments(sourcePath string, stream int, start float64) []string {
+	return []string{"-hide_banner", "-nostats",
+		"-ss", fmt.Sprintf("%.3f", start), "-i", sourcePath,
+		"-map", fmt.Sprintf("0:%d", stream), "-frames:v", strconv.Itoa(idetSampleFrames), "-vf", "idet", "-f", "null", "-"}
+}
@@ -0,0 +1,57 @@
+package detect
+
+import (
+	"testing"
+)
+
+func TestParseIdet(t *testing.T) {
+	output := "[Parsed_idet_0 @ 0x1] Repeated Fields: Neither:   499 Top:     0 Bottom:     1\n" +
+		"[Parsed_idet_0 @ 0x1] Single frame detection: TFF:   301 BFF:     2 Progressive:   150 Undetermined:    47\n" +
+		"[Parsed_idet_0 @ 0x1] Multi frame detection: TFF:   412 BFF:     0 Progressive:    80 Undetermined:     8\n"
+
+	result, err := ParseIdet(output)
+	if err != nil {
+		t.Fatalf("ParseIdet() unexpected error: %v", err)
+	}
+	expected := IdetResult{TFF: 412, BFF: 0, Progressive: 80, Undetermined: 8}
+	if result != expected {
+		t.Errorf("ParseIdet() = %+v, want %+v", result, expected)
+	}
+
+	if _, err := ParseIdet("no summary"); err == nil {
+		t.Error("ParseIdet() expected error without summary")
+	}
+}
+
+func TestIdetResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     IdetResult
+		interlaced bool
+		parity     string
+	}{
+		{"top field first", IdetResult{TFF: 412, Progressive: 80}, true, "tff"},
+		{"bottom field first", IdetResult{BFF: 1500, TFF: 10, Progressive: 100}, true, "bff"},
+		{"progressive", IdetResult{TFF: 20, Progressive: 1900, Undetermined: 80}, false, "tff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result.Interlaced() != tt.interlaced {
+				t.Errorf("Interlaced() = %v, want %v", tt.result.Interlaced(), tt.interlaced)
+			}
+			if tt.result.Parity() != tt.parity {
+				t.Errorf("Parity() = %s, want %s", tt.result.Parity(), tt.parity)
+			}
+		})
+	}
+}
+
+func TestDeinterlaceFilter(t *testing.T) {
+	if filter := DeinterlaceFilter("", "tff"); filter != "bwdif=mode=send_frame:parity=tff:deint=all" {
+		t.Errorf("DeinterlaceFilter() = %s, want bwdif by default", filter)
+	}
+	if filter := DeinterlaceFilter("yadif", "bff"); filter != "yadif=mode=send_frame:parity=bff:deint=all" {
+		t.Errorf("DeinterlaceFilter() = %s", filter)
+	}
+}
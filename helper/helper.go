@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -149,6 +150,16 @@ func DisembedFile(embedFS http.FileSystem, statikPath string, targetFilePath str
 	return targetCopyFile, nil
 }
 
+// Version returns the version of the running binary, stamped by the go
+// toolchain from the VCS tag it was built from.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Version
+}
+
 func GetFFmpegPath() string {
 	return ffmpegPath
 }
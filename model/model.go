@@ -1,10 +1,13 @@
 package model
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"gearr/helper"
 	"gearr/helper/max"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +21,7 @@ type EventType string
 type NotificationType string
 type NotificationStatus string
 type JobAction string
+type WorkerState string
 type TaskEvents []*TaskEvent
 
 type CustomError struct {
@@ -47,6 +51,10 @@ const (
 	FFProbeNotification    NotificationType = "FFProbe"
 	PGSNotification        NotificationType = "PGS"
 	FFMPEGSNotification    NotificationType = "FFMPEG"
+	JoinNotification       NotificationType = "Join"
+	QualityNotification    NotificationType = "Quality"
+	CropDetectNotification NotificationType = "CropDetect"
+	IdetNotification       NotificationType = "Idet"
 
 	QueuedNotificationStatus      NotificationStatus = "queued"
 	ReQueuedNotificationStatus    NotificationStatus = "requeued"
@@ -54,11 +62,40 @@ const (
 	CompletedNotificationStatus   NotificationStatus = "completed"
 	CanceledNotificationStatus    NotificationStatus = "canceled"
 	FailedNotificationStatus      NotificationStatus = "failed"
-
-	EncodeJobType   JobType = "encode"
-	PGSToSrtJobType JobType = "pgstosrt"
+	// KeptOriginalNotificationStatus ends the jobs whose encode was larger
+	// than the source, which is left untouched.
+	KeptOriginalNotificationStatus NotificationStatus = "kept_original"
+	// PausedNotificationStatus holds a job on its worker until it is resumed.
+	PausedNotificationStatus NotificationStatus = "paused"
+
+	// CancelJobAction stops a running job on the worker holding it.
+	CancelJobAction JobAction = "cancel"
+	// PauseJobAction and ResumeJobAction suspend and continue a running job
+	// without losing its progress.
+	PauseJobAction  JobAction = "pause"
+	ResumeJobAction JobAction = "resume"
+	// Worker actions have no job, they change the state of the worker.
+	DrainWorkerAction  JobAction = "drain_worker"
+	PauseWorkerAction  JobAction = "pause_worker"
+	ResumeWorkerAction JobAction = "resume_worker"
+
+	// ActiveWorkerState takes new jobs. DrainingWorkerState finishes its
+	// jobs without taking new ones and is DrainedWorkerState once it has none.
+	// PausedWorkerState suspends its jobs and takes no new ones.
+	ActiveWorkerState   WorkerState = "active"
+	DrainingWorkerState WorkerState = "draining"
+	DrainedWorkerState  WorkerState = "drained"
+	PausedWorkerState   WorkerState = "paused"
+
+	EncodeJobType        JobType = "encode"
+	PGSToSrtJobType      JobType = "pgstosrt"
+	EncodeSegmentJobType JobType = "encodesegment"
+	JoinSegmentsJobType  JobType = "joinsegments"
 )
 
+// EncodeJobTypes are the job types handled by the encode worker.
+var EncodeJobTypes = []JobType{EncodeJobType, EncodeSegmentJobType, JoinSegmentsJobType}
+
 type Identity interface {
 	getUUID() uuid.UUID
 }
@@ -72,6 +109,108 @@ type Job struct {
 	StatusMessage   string           `json:"status_message,omitempty"`
 	LastUpdate      *time.Time       `json:"last_update,omitempty"`
 	Priority        int              `json:"priority,omitempty"`
+	Profile         string           `json:"profile,omitempty"`
+	ParentId        *uuid.UUID       `json:"parent_id,omitempty"`
+	Segment         *Segment         `json:"segment,omitempty"`
+	Segments        []*Job           `json:"segments,omitempty"`
+	Progress        float64          `json:"progress,omitempty"`
+	QualityMetric   string           `json:"quality_metric,omitempty"`
+	QualityScore    *float64         `json:"quality_score,omitempty"`
+	Sample          *Sample          `json:"sample,omitempty"`
+	Size            int64            `json:"size,omitempty"`
+	EncodeProgress  *EncodeProgress  `json:"encode_progress,omitempty"`
+}
+
+// EncodeProgress is the live state of an ffmpeg encode, read from its -progress
+// output and carried by FFMPEG progressing events. Bitrate is in kbps, output
+// size in bytes and ETA in seconds; Pass and Passes are set on two-pass encodes.
+type EncodeProgress struct {
+	Percent    float64 `json:"percent"`
+	Frame      int64   `json:"frame"`
+	FPS        float64 `json:"fps"`
+	Bitrate    float64 `json:"bitrate"`
+	OutputSize int64   `json:"output_size"`
+	Speed      float64 `json:"speed"`
+	ETA        int64   `json:"eta"`
+	Pass       int     `json:"pass,omitempty"`
+	Passes     int     `json:"passes,omitempty"`
+}
+
+// ParseEncodeProgress reads the progress stored as JSON, nil when there is none.
+func ParseEncodeProgress(value []byte) (*EncodeProgress, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	progress := &EncodeProgress{}
+	if err := json.Unmarshal(value, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// UploadSession is the state of a chunked upload of a job result, Offset
+// being the bytes the server already verified and stored.
+type UploadSession struct {
+	Size   int64 `json:"size"`
+	Offset int64 `json:"offset"`
+}
+
+// JobLog is a log uploaded by the worker of a job, like the ffmpeg output.
+type JobLog struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Sample asks for a preview encode of a few short clips of the source instead
+// of the whole file, to compare profiles before encoding a library. Zero
+// values take the server defaults.
+type Sample struct {
+	Clips       int `json:"clips,omitempty"`
+	ClipSeconds int `json:"clip_seconds,omitempty"`
+}
+
+// QualityResult is the message of Quality events, the perceptual score of an
+// encode made with the given CRF.
+type QualityResult struct {
+	Metric string  `json:"metric"`
+	Score  float64 `json:"score"`
+	CRF    int     `json:"crf,omitempty"`
+	Passed bool    `json:"passed"`
+}
+
+func (q QualityResult) ToJson() string {
+	b, _ := json.Marshal(q)
+	return string(b)
+}
+
+// ParseQualityResult reads the result carried by a Quality event message.
+func ParseQualityResult(message string) (*QualityResult, error) {
+	result := &QualityResult{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil, err
+	}
+	if result.Metric == "" {
+		return nil, errors.New("quality result without metric")
+	}
+	return result, nil
+}
+
+// Segment is a time range of the source, cut at keyframes, encoded as an
+// independent task. An End of zero means up to the end of the source.
+type Segment struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+}
+
+// Duration returns the length of the segment in a source of the given duration.
+func (s Segment) Duration(total float64) float64 {
+	end := s.End
+	if end <= 0 || end > total {
+		end = total
+	}
+	return end - s.Start
 }
 
 type JobEventQueue struct {
@@ -79,10 +218,29 @@ type JobEventQueue struct {
 	JobEvent *JobEvent
 }
 type Worker struct {
-	Name      string    `json:"name"`
-	Ip        string    `json:"id"`
-	QueueName string    `json:"queue_name"`
-	LastSeen  time.Time `json:"last_seen"`
+	Name      string      `json:"name"`
+	Ip        string      `json:"id"`
+	QueueName string      `json:"queue_name"`
+	LastSeen  time.Time   `json:"last_seen"`
+	State     WorkerState `json:"state"`
+	// Capabilities are nil for workers that do not report them.
+	Capabilities *WorkerCapabilities `json:"capabilities,omitempty"`
+}
+
+// WorkerCapabilities are registered by the workers with their pings. Encode
+// jobs are only dispatched to workers having the encoders of their profile.
+type WorkerCapabilities struct {
+	Version      string    `json:"version,omitempty"`
+	Threads      int       `json:"threads"`
+	AcceptedJobs []JobType `json:"accepted_jobs,omitempty"`
+	EncodeJobs   int       `json:"encode_jobs"`
+	PGSJobs      int       `json:"pgs_jobs"`
+	// Encoders also holds the ffmpeg filters. It is nil when the worker
+	// failed to list them, any job is dispatched to it then.
+	Encoders []string `json:"encoders,omitempty"`
+	// FreeTempSpace is the free space of the temporal path in bytes.
+	FreeTempSpace uint64            `json:"free_temp_space"`
+	Labels        map[string]string `json:"labels,omitempty"`
 }
 
 type ControlEvent struct {
@@ -98,11 +256,48 @@ type JobEvent struct {
 type JobType string
 
 type TaskEncode struct {
-	Id          uuid.UUID `json:"id"`
-	DownloadURL string    `json:"downloadURL"`
-	UploadURL   string    `json:"uploadURL"`
-	ChecksumURL string    `json:"checksumURL"`
-	EventID     int       `json:"eventID"`
+	Id          uuid.UUID        `json:"id"`
+	DownloadURL string           `json:"downloadURL"`
+	UploadURL   string           `json:"uploadURL"`
+	ChecksumURL string           `json:"checksumURL"`
+	EventID     int              `json:"eventID"`
+	Profile     *EncodingProfile `json:"profile,omitempty"`
+	Segment     *Segment         `json:"segment,omitempty"`
+	SegmentURLs []string         `json:"segmentURLs,omitempty"`
+	Sample      bool             `json:"sample,omitempty"`
+	// SourcePath and DestinationPath are the files of the job on the server,
+	// read and written in place by workers mounting the same storage.
+	SourcePath      string `json:"sourcePath,omitempty"`
+	DestinationPath string `json:"destinationPath,omitempty"`
+	// Labels route the task to the workers having all of them.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Analysis of the whole source, sent with the segments of chunked and
+	// sample jobs.
+	Analysis *SourceAnalysis `json:"analysis,omitempty"`
+}
+
+// SourceAnalysis is the detection the server runs once on the source of a
+// chunked or sample job. Its segments only get their own cut of the source,
+// so they take the crop, the deinterlacing and the bitrate from it.
+type SourceAnalysis struct {
+	// Crop and Deinterlace are ffmpeg filters, empty when not needed.
+	Crop        string  `json:"crop,omitempty"`
+	Deinterlace string  `json:"deinterlace,omitempty"`
+	Size        int64   `json:"size"`
+	Duration    float64 `json:"duration"`
+}
+
+// JobType returns the kind of encode task: a whole file, a single segment of
+// a chunked job or the join of the encoded segments.
+func (V TaskEncode) JobType() JobType {
+	switch {
+	case V.Segment != nil:
+		return EncodeSegmentJobType
+	case len(V.SegmentURLs) > 0:
+		return JoinSegmentsJobType
+	default:
+		return EncodeJobType
+	}
 }
 
 type WorkTaskEncode struct {
@@ -110,6 +305,8 @@ type WorkTaskEncode struct {
 	WorkDir        string
 	SourceFilePath string
 	TargetFilePath string
+	ctx            context.Context
+	cancel         context.CancelFunc
 }
 
 type TaskPGS struct {
@@ -125,6 +322,7 @@ type TaskPGSResponse struct {
 	PGSID int       `json:"pgsid"`
 	Srt   []byte    `json:"srt"`
 	Err   string    `json:"error"`
+	Log   string    `json:"log,omitempty"`
 	Queue string    `json:"queue"`
 }
 
@@ -140,27 +338,36 @@ type JobUpdateNotification struct {
 	Status          NotificationStatus `json:"status"`
 	StatusPhase     NotificationType   `json:"status_phase"`
 	Message         string             `json:"message"`
+	Progress        *EncodeProgress    `json:"progress,omitempty"`
 	EventTime       time.Time          `json:"event_time"`
 	SourcePath      string             `json:"source_path,omitempty"`
 	DestinationPath string             `json:"destination_path,omitempty"`
 }
 
 type TaskEvent struct {
-	Id               uuid.UUID          `json:"id"`
-	EventID          int                `json:"event_id"`
-	EventType        EventType          `json:"event_type"`
-	WorkerName       string             `json:"worker_name"`
-	WorkerQueue      string             `json:"worker_queue"`
-	EventTime        time.Time          `json:"event_time"`
-	IP               string             `json:"ip"`
-	NotificationType NotificationType   `json:"notification_type"`
-	Status           NotificationStatus `json:"status"`
-	Message          string             `json:"message"`
+	Id          uuid.UUID   `json:"id"`
+	EventID     int         `json:"event_id"`
+	EventType   EventType   `json:"event_type"`
+	WorkerName  string      `json:"worker_name"`
+	WorkerQueue string      `json:"worker_queue"`
+	WorkerState WorkerState `json:"worker_state,omitempty"`
+	// Capabilities are only sent with ping events.
+	Capabilities     *WorkerCapabilities `json:"capabilities,omitempty"`
+	EventTime        time.Time           `json:"event_time"`
+	IP               string              `json:"ip"`
+	NotificationType NotificationType    `json:"notification_type"`
+	Status           NotificationStatus  `json:"status"`
+	Message          string              `json:"message"`
+	Progress         *EncodeProgress     `json:"progress,omitempty"`
 }
 
 type TaskStatus struct {
 	LastState *TaskEvent
 	Task      *WorkTaskEncode
+	// Paused is a task suspended when it was saved, WorkerPaused when it was
+	// by a pause of the whole worker.
+	Paused       bool
+	WorkerPaused bool
 }
 
 func (e TaskEvent) IsDownloading() bool {
@@ -191,12 +398,21 @@ func (e TaskEvent) IsEncoding() bool {
 	if e.NotificationType == FFProbeNotification && (e.Status == ProgressingNotificationStatus || e.Status == CompletedNotificationStatus) {
 		return true
 	}
+	if e.NotificationType == CropDetectNotification && (e.Status == ProgressingNotificationStatus || e.Status == CompletedNotificationStatus) {
+		return true
+	}
+	if e.NotificationType == IdetNotification && (e.Status == ProgressingNotificationStatus || e.Status == CompletedNotificationStatus) {
+		return true
+	}
 	if e.NotificationType == PGSNotification && (e.Status == ProgressingNotificationStatus || e.Status == CompletedNotificationStatus) {
 		return true
 	}
 	if e.NotificationType == FFMPEGSNotification && e.Status == ProgressingNotificationStatus {
 		return true
 	}
+	if e.NotificationType == QualityNotification && e.Status == ProgressingNotificationStatus {
+		return true
+	}
 
 	return false
 }
@@ -208,6 +424,9 @@ func (e TaskEvent) IsUploading() bool {
 	if e.NotificationType == FFMPEGSNotification && e.Status == CompletedNotificationStatus {
 		return true
 	}
+	if e.NotificationType == QualityNotification && e.Status == CompletedNotificationStatus {
+		return true
+	}
 
 	if e.NotificationType == UploadNotification && e.Status == ProgressingNotificationStatus {
 		return true
@@ -216,6 +435,26 @@ func (e TaskEvent) IsUploading() bool {
 	return false
 }
 
+// StartContext derives the context of the task from the worker one, Cancel
+// stops only this task.
+func (W *WorkTaskEncode) StartContext(parent context.Context) {
+	W.ctx, W.cancel = context.WithCancel(parent)
+}
+
+// Context returns the context of the task, background when not started.
+func (W *WorkTaskEncode) Context() context.Context {
+	if W.ctx == nil {
+		return context.Background()
+	}
+	return W.ctx
+}
+
+func (W *WorkTaskEncode) Cancel() {
+	if W.cancel != nil {
+		W.cancel()
+	}
+}
+
 func (W *WorkTaskEncode) Clean() error {
 	err := os.RemoveAll(W.WorkDir)
 	if err != nil {
@@ -247,6 +486,21 @@ func (t *TaskEvents) GetLatestPerNotificationType(notificationType NotificationT
 	}
 	return returnEvent
 }
+
+// GetWorkerName returns the worker of the latest event sent by a worker, empty
+// when no worker took the job.
+func (t *TaskEvents) GetWorkerName() string {
+	eventID := -1
+	workerName := ""
+	for _, event := range *t {
+		if event.WorkerName != "" && event.EventID > eventID {
+			eventID = event.EventID
+			workerName = event.WorkerName
+		}
+	}
+	return workerName
+}
+
 func (t *TaskEvents) GetStatus() NotificationStatus {
 	event := t.GetLatestPerNotificationType(JobNotification)
 	if event == nil {
@@ -255,10 +509,65 @@ func (t *TaskEvents) GetStatus() NotificationStatus {
 	return event.Status
 }
 
+// SegmentsCompleted reports whether every segment of a chunked job has been
+// encoded and uploaded.
+func (v *Job) SegmentsCompleted() bool {
+	if len(v.Segments) == 0 {
+		return false
+	}
+	for _, segment := range v.Segments {
+		if segment.Status != string(CompletedNotificationStatus) {
+			return false
+		}
+	}
+	return true
+}
+
+// SegmentsProgress returns the encoding progress of a chunked job, the average
+// of the progress reported by its segments.
+func (v *Job) SegmentsProgress() float64 {
+	if len(v.Segments) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, segment := range v.Segments {
+		total += segment.segmentProgress()
+	}
+	return total / float64(len(v.Segments))
+}
+
+func (v *Job) segmentProgress() float64 {
+	switch {
+	case v.Status == string(CompletedNotificationStatus):
+		return 100
+	case v.StatusPhase == UploadNotification:
+		return 100
+	case v.StatusPhase == FFMPEGSNotification && v.Status == string(ProgressingNotificationStatus) && v.EncodeProgress != nil:
+		return v.EncodeProgress.Percent
+	case v.StatusPhase == FFMPEGSNotification && v.Status == string(ProgressingNotificationStatus):
+		// events stored before the structured progress
+		progress := struct {
+			Progress string `json:"progress"`
+		}{}
+		if err := json.Unmarshal([]byte(v.StatusMessage), &progress); err != nil {
+			return 0
+		}
+		value, err := strconv.ParseFloat(progress.Progress, 64)
+		if err != nil {
+			return 0
+		}
+		return value
+	default:
+		return 0
+	}
+}
+
 type JobRequest struct {
-	SourcePath      string `json:"source_path"`
-	DestinationPath string `json:"destination_path"`
-	Priority        int    `json:"priority,omitempty"`
+	SourcePath      string  `json:"source_path"`
+	DestinationPath string  `json:"destination_path"`
+	Priority        int     `json:"priority,omitempty"`
+	Profile         string  `json:"profile,omitempty"`
+	Sample          *Sample `json:"sample,omitempty"`
 }
 
 type TimeoutJob struct {
@@ -266,6 +575,7 @@ type TimeoutJob struct {
 	SourcePath      string             `json:"source_path"`
 	DestinationPath string             `json:"destination_path"`
 	Status          NotificationStatus `json:"status"`
+	Profile         string             `json:"profile"`
 }
 
 func (a TaskEvents) Len() int {
@@ -316,12 +626,19 @@ type FileProcessingStatus string
 const (
 	ScannerSource FileProcessingSource = "scanner"
 	WatcherSource FileProcessingSource = "watcher"
+	// EncoderSource records files from the outcome of their job.
+	EncoderSource FileProcessingSource = "encoder"
 
 	QueuedStatus  FileProcessingStatus = "queued"
 	SkippedStatus FileProcessingStatus = "skipped"
 	InvalidStatus FileProcessingStatus = "invalid"
 	X265Status    FileProcessingStatus = "x265"
 	ErrorStatus   FileProcessingStatus = "error"
+
+	TargetCodecStatus FileProcessingStatus = "target_codec"
+	// KeptOriginalStatus marks files whose encode was larger than the source,
+	// they are never queued again.
+	KeptOriginalStatus FileProcessingStatus = "kept_original"
 )
 
 type FileProcessing struct {
@@ -363,6 +680,7 @@ type ScannedFile struct {
 	Codec         string    `json:"codec,omitempty"`
 	LastScannedAt time.Time `json:"last_scanned_at"`
 	Queued        bool      `json:"queued"`
+	KeptOriginal  bool      `json:"kept_original"`
 	ScanId        string    `json:"scan_id,omitempty"`
 }
 
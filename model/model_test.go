@@ -0,0 +1,90 @@
+package model
+
+import "testing"
+
+func TestJob_SegmentsProgress(t *testing.T) {
+	job := &Job{
+		Segments: []*Job{
+			{Status: string(CompletedNotificationStatus), StatusPhase: JobNotification},
+			{Status: string(ProgressingNotificationStatus), StatusPhase: FFMPEGSNotification, EncodeProgress: &EncodeProgress{Percent: 50}},
+			{Status: string(ProgressingNotificationStatus), StatusPhase: UploadNotification},
+			{Status: string(QueuedNotificationStatus), StatusPhase: JobNotification},
+		},
+	}
+	if progress := job.SegmentsProgress(); progress != 62.5 {
+		t.Errorf("SegmentsProgress() = %f, want 62.5", progress)
+	}
+	legacy := &Job{Status: string(ProgressingNotificationStatus), StatusPhase: FFMPEGSNotification, StatusMessage: `{"progress":"50.00"}`}
+	if progress := legacy.segmentProgress(); progress != 50 {
+		t.Errorf("segmentProgress() = %f, want 50 from the event message", progress)
+	}
+	if job.SegmentsCompleted() {
+		t.Error("SegmentsCompleted() = true, want false")
+	}
+
+	for _, segment := range job.Segments {
+		segment.Status = string(CompletedNotificationStatus)
+	}
+	if !job.SegmentsCompleted() {
+		t.Error("SegmentsCompleted() = false, want true")
+	}
+}
+
+func TestTaskEncode_JobType(t *testing.T) {
+	tests := []struct {
+		task     TaskEncode
+		expected JobType
+	}{
+		{TaskEncode{}, EncodeJobType},
+		{TaskEncode{Segment: &Segment{Index: 1}}, EncodeSegmentJobType},
+		{TaskEncode{SegmentURLs: []string{"http://localhost/segment/0"}}, JoinSegmentsJobType},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.expected), func(t *testing.T) {
+			if jobType := tt.task.JobType(); jobType != tt.expected {
+				t.Errorf("JobType() = %s, want %s", jobType, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseQualityResult(t *testing.T) {
+	result := QualityResult{Metric: "vmaf", Score: 94.25, CRF: 21, Passed: true}
+	parsed, err := ParseQualityResult(result.ToJson())
+	if err != nil {
+		t.Fatalf("ParseQualityResult() error = %v", err)
+	}
+	if *parsed != result {
+		t.Errorf("ParseQualityResult() = %+v, want %+v", *parsed, result)
+	}
+
+	for _, message := range []string{"", "{}", "not json"} {
+		if _, err := ParseQualityResult(message); err == nil {
+			t.Errorf("ParseQualityResult(%q) expected error", message)
+		}
+	}
+}
+
+func TestTaskEvents_GetWorkerName(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   TaskEvents
+		expected string
+	}{
+		{"no events", TaskEvents{}, ""},
+		{"only server events", TaskEvents{{EventID: 0, Status: QueuedNotificationStatus}}, ""},
+		{"latest worker", TaskEvents{
+			{EventID: 0, Status: QueuedNotificationStatus},
+			{EventID: 1, WorkerName: "old-worker", Status: ProgressingNotificationStatus},
+			{EventID: 3, WorkerName: "worker", Status: ProgressingNotificationStatus},
+			{EventID: 2, Status: ReQueuedNotificationStatus},
+		}, "worker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if workerName := tt.events.GetWorkerName(); workerName != tt.expected {
+				t.Errorf("GetWorkerName() = %q, want %q", workerName, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"gearr/helper/codec"
+	"slices"
+)
+
+const DefaultEncodingProfileName = "default"
+
+var ErrProfileNotFound = errors.New("encoding profile not found")
+
+type EncodingProfile struct {
+	Name         string `mapstructure:"name" json:"name"`
+	VideoCodec   string `mapstructure:"videoCodec" json:"video_codec"`
+	CRF          int    `mapstructure:"crf" json:"crf,omitempty"`
+	Preset       string `mapstructure:"preset" json:"preset,omitempty"`
+	VideoProfile string `mapstructure:"videoProfile" json:"video_profile,omitempty"`
+	MaxWidth     int    `mapstructure:"maxWidth" json:"max_width,omitempty"`
+	PixelFormat  string `mapstructure:"pixelFormat" json:"pixel_format,omitempty"`
+	AudioCodec   string `mapstructure:"audioCodec" json:"audio_codec"`
+	AudioQuality int    `mapstructure:"audioQuality" json:"audio_quality,omitempty"`
+	AudioBitrate string `mapstructure:"audioBitrate" json:"audio_bitrate,omitempty"`
+	// Container is the format of the encoded files, mkv by default or mp4
+	// with faststart and mov_text subtitles.
+	Container string `mapstructure:"container" json:"container,omitempty"`
+	// Chapters, attachments (e.g. fonts for ASS subtitles) and global tags
+	// are kept unless stripped.
+	StripChapters    bool `mapstructure:"stripChapters" json:"strip_chapters"`
+	StripAttachments bool `mapstructure:"stripAttachments" json:"strip_attachments"`
+	StripTags        bool `mapstructure:"stripTags" json:"strip_tags"`
+	// Black bars are detected and cropped unless disabled.
+	DisableCrop bool `mapstructure:"disableCrop" json:"disable_crop"`
+	// Deinterlacer filters the sources detected as interlaced, bwdif by
+	// default or none to encode them as they are.
+	Deinterlacer string `mapstructure:"deinterlacer" json:"deinterlacer,omitempty"`
+	// LargerOutput is what happens when the encode is larger than the source:
+	// fail (default), keep the original or retry once at a higher CRF.
+	LargerOutput string         `mapstructure:"largerOutput" json:"larger_output,omitempty"`
+	Audio        AudioPolicy    `mapstructure:"audio" json:"audio"`
+	Subtitle     SubtitlePolicy `mapstructure:"subtitle" json:"subtitle"`
+	Quality      QualityPolicy  `mapstructure:"quality" json:"quality"`
+	Bitrate      BitratePolicy  `mapstructure:"bitrate" json:"bitrate"`
+}
+
+// AudioPolicy selects which audio tracks are kept and how they are encoded.
+// The zero value keeps the best track per language, re-encoding all of them.
+type AudioPolicy struct {
+	Languages           []string `mapstructure:"languages" json:"languages,omitempty"`
+	PreferredLanguages  []string `mapstructure:"preferredLanguages" json:"preferred_languages,omitempty"`
+	KeepOriginal        bool     `mapstructure:"keepOriginal" json:"keep_original"`
+	KeepCommentary      bool     `mapstructure:"keepCommentary" json:"keep_commentary"`
+	PassthroughLossless bool     `mapstructure:"passthroughLossless" json:"passthrough_lossless"`
+	PassthroughAAC      bool     `mapstructure:"passthroughAAC" json:"passthrough_aac"`
+	StereoDownmix       bool     `mapstructure:"stereoDownmix" json:"stereo_downmix"`
+}
+
+// SubtitlePolicy selects which subtitle tracks are kept. The zero value keeps
+// every track and embeds the subtitles converted from PGS.
+type SubtitlePolicy struct {
+	Languages  []string `mapstructure:"languages" json:"languages,omitempty"`
+	DropForced bool     `mapstructure:"dropForced" json:"drop_forced"`
+	DropSDH    bool     `mapstructure:"dropSDH" json:"drop_sdh"`
+	Sidecar    bool     `mapstructure:"sidecar" json:"sidecar"`
+}
+
+const (
+	ContainerMKV = "mkv"
+	ContainerMP4 = "mp4"
+)
+
+const (
+	DeinterlacerBwdif = "bwdif"
+	DeinterlacerYadif = "yadif"
+	DeinterlacerNone  = "none"
+)
+
+const (
+	LargerOutputFail  = "fail"
+	LargerOutputKeep  = "keep"
+	LargerOutputRetry = "retry"
+)
+
+const (
+	QualityMetricVMAF = "vmaf"
+	QualityMetricSSIM = "ssim"
+)
+
+// QualityPolicy verifies the encoded video against its source with a
+// perceptual metric computed on sampled windows. It is disabled when no metric
+// is set. Encodes scoring below MinScore are re-encoded with the CRF lowered by
+// CRFStep up to Retries times, then failed.
+type QualityPolicy struct {
+	Metric        string  `mapstructure:"metric" json:"metric,omitempty"`
+	MinScore      float64 `mapstructure:"minScore" json:"min_score,omitempty"`
+	Samples       int     `mapstructure:"samples" json:"samples,omitempty"`
+	SampleSeconds int     `mapstructure:"sampleSeconds" json:"sample_seconds,omitempty"`
+	Retries       int     `mapstructure:"retries" json:"retries,omitempty"`
+	CRFStep       int     `mapstructure:"crfStep" json:"crf_step,omitempty"`
+}
+
+// Enabled reports whether encodes are verified.
+func (q QualityPolicy) Enabled() bool {
+	return q.Metric != ""
+}
+
+func (q QualityPolicy) validate(profile EncodingProfile) error {
+	maxScore := 0.0
+	switch q.Metric {
+	case "":
+		return nil
+	case QualityMetricVMAF:
+		maxScore = 100
+	case QualityMetricSSIM:
+		maxScore = 1
+	default:
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid quality metric %s", profile.Name, q.Metric)}
+	}
+	if q.MinScore < 0 || q.MinScore > maxScore {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid minimum %s score %g", profile.Name, q.Metric, q.MinScore)}
+	}
+	if q.Samples < 0 || q.SampleSeconds < 0 || q.Retries < 0 || q.CRFStep < 0 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has negative quality settings", profile.Name)}
+	}
+	if q.Retries > 0 && profile.CRF == 0 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s needs a crf to re-encode on low quality", profile.Name)}
+	}
+	return nil
+}
+
+// BitratePolicy replaces CRF with a two-pass average bitrate encode for a
+// predictable output size. The video bitrate is SourcePercent of the source
+// average bitrate, capped by the first resolution entry fitting the output
+// width. The zero value keeps CRF.
+type BitratePolicy struct {
+	SourcePercent int                 `mapstructure:"sourcePercent" json:"source_percent,omitempty"`
+	Resolutions   []ResolutionBitrate `mapstructure:"resolutions" json:"resolutions,omitempty"`
+}
+
+// ResolutionBitrate is the video bitrate, in kbps, of outputs up to MaxWidth wide.
+type ResolutionBitrate struct {
+	MaxWidth int `mapstructure:"maxWidth" json:"max_width"`
+	Kbps     int `mapstructure:"kbps" json:"kbps"`
+}
+
+// Enabled reports whether encodes use two-pass average bitrate.
+func (b BitratePolicy) Enabled() bool {
+	return b.SourcePercent > 0 || len(b.Resolutions) > 0
+}
+
+func (b BitratePolicy) validate(profile EncodingProfile, target codec.Target) error {
+	if !b.Enabled() {
+		return nil
+	}
+	if !target.TwoPass {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s uses %s, which does not support two-pass bitrate encoding", profile.Name, profile.VideoCodec)}
+	}
+	if b.SourcePercent < 0 || b.SourcePercent > 100 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid source bitrate percent %d", profile.Name, b.SourcePercent)}
+	}
+	for _, resolution := range b.Resolutions {
+		if resolution.MaxWidth <= 0 || resolution.Kbps <= 0 {
+			return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid bitrate for width %d", profile.Name, resolution.MaxWidth)}
+		}
+	}
+	if profile.Quality.Retries > 0 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s can not re-encode on low quality with a fixed bitrate", profile.Name)}
+	}
+	return nil
+}
+
+type EncodingProfiles []EncodingProfile
+
+// DefaultEncodingProfile returns the settings used before profiles were configurable.
+func DefaultEncodingProfile() EncodingProfile {
+	return EncodingProfile{
+		Name:         DefaultEncodingProfileName,
+		VideoCodec:   "libx265",
+		CRF:          21,
+		VideoProfile: "main10",
+		MaxWidth:     1920,
+		PixelFormat:  "yuv420p10le",
+		AudioCodec:   "libfdk_aac",
+		AudioQuality: 5,
+	}
+}
+
+func (p EncodingProfile) Validate() error {
+	if p.Name == "" {
+		return &CustomError{Message: "encoding profile name is mandatory"}
+	}
+	if p.VideoCodec == "" {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has no video codec", p.Name)}
+	}
+	target, err := codec.TargetByEncoder(p.VideoCodec)
+	if err != nil {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid video codec", p.Name), Cause: err}
+	}
+	if p.AudioCodec == "" {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has no audio codec", p.Name)}
+	}
+	if p.CRF < 0 || p.CRF > 63 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid crf %d", p.Name, p.CRF)}
+	}
+	if p.MaxWidth < 0 {
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid max width %d", p.Name, p.MaxWidth)}
+	}
+	switch p.Container {
+	case "", ContainerMKV, ContainerMP4:
+	default:
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid container %s", p.Name, p.Container)}
+	}
+	switch p.Deinterlacer {
+	case "", DeinterlacerBwdif, DeinterlacerYadif, DeinterlacerNone:
+	default:
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid deinterlacer %s", p.Name, p.Deinterlacer)}
+	}
+	switch p.LargerOutput {
+	case "", LargerOutputFail, LargerOutputKeep:
+	case LargerOutputRetry:
+		if p.CRF == 0 || p.Bitrate.Enabled() {
+			return &CustomError{Message: fmt.Sprintf("encoding profile %s needs a crf to retry larger encodes", p.Name)}
+		}
+	default:
+		return &CustomError{Message: fmt.Sprintf("encoding profile %s has invalid larger output policy %s", p.Name, p.LargerOutput)}
+	}
+	if err := p.Bitrate.validate(p, target); err != nil {
+		return err
+	}
+	return p.Quality.validate(p)
+}
+
+// OutputContainer returns the format of the encoded files, mkv unless the
+// profile sets another one.
+func (p EncodingProfile) OutputContainer() string {
+	if p.Container == "" {
+		return ContainerMKV
+	}
+	return p.Container
+}
+
+// Target returns the output codec produced by the profile video encoder.
+func (p EncodingProfile) Target() (codec.Target, error) {
+	return codec.TargetByEncoder(p.VideoCodec)
+}
+
+// SubtitleEncoder is the encoder of the text subtitles in the container.
+func SubtitleEncoder(container string) string {
+	if container == ContainerMP4 {
+		return "mov_text"
+	}
+	return "srt"
+}
+
+// RequiredEncoders returns the ffmpeg encoders a worker needs to run the
+// profile, with the text subtitles of its container, and the libvmaf filter
+// when encodes are verified with VMAF. Copied streams need none.
+func (p EncodingProfile) RequiredEncoders() []string {
+	encoders := []string{}
+	required := []string{p.VideoCodec, p.AudioCodec, SubtitleEncoder(p.OutputContainer())}
+	if p.Quality.Metric == QualityMetricVMAF {
+		required = append(required, "libvmaf")
+	}
+	for _, encoder := range required {
+		if encoder != "" && encoder != "copy" && !slices.Contains(encoders, encoder) {
+			encoders = append(encoders, encoder)
+		}
+	}
+	return encoders
+}
+
+func (p EncodingProfiles) Validate() error {
+	names := make(map[string]bool, len(p))
+	for _, profile := range p {
+		if err := profile.Validate(); err != nil {
+			return err
+		}
+		if names[profile.Name] {
+			return &CustomError{Message: fmt.Sprintf("encoding profile %s is duplicated", profile.Name)}
+		}
+		names[profile.Name] = true
+	}
+	return nil
+}
+
+// Get looks up a profile by name. The built-in default profile is always
+// available unless it has been overridden in the configuration.
+func (p EncodingProfiles) Get(name string) (*EncodingProfile, error) {
+	for _, profile := range p {
+		if profile.Name == name {
+			found := profile
+			return &found, nil
+		}
+	}
+	if name == DefaultEncodingProfileName {
+		defaultProfile := DefaultEncodingProfile()
+		return &defaultProfile, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+}
+
+// All returns the configured profiles plus the built-in default when it is not overridden.
+func (p EncodingProfiles) All() EncodingProfiles {
+	for _, profile := range p {
+		if profile.Name == DefaultEncodingProfileName {
+			return p
+		}
+	}
+	return append(EncodingProfiles{DefaultEncodingProfile()}, p...)
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodingProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile EncodingProfile
+		wantErr bool
+	}{
+		{"default profile is valid", DefaultEncodingProfile(), false},
+		{"missing name", EncodingProfile{VideoCodec: "libx265", AudioCodec: "aac"}, true},
+		{"missing video codec", EncodingProfile{Name: "anime", AudioCodec: "aac"}, true},
+		{"missing audio codec", EncodingProfile{Name: "anime", VideoCodec: "libx265"}, true},
+		{"unsupported video codec", EncodingProfile{Name: "anime", VideoCodec: "libtheora", AudioCodec: "aac"}, true},
+		{"av1 target", EncodingProfile{Name: "anime", VideoCodec: "libsvtav1", AudioCodec: "libopus", CRF: 30}, false},
+		{"crf out of range", EncodingProfile{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac", CRF: 70}, true},
+		{"negative max width", EncodingProfile{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac", MaxWidth: -1}, true},
+		{"mp4 container", EncodingProfile{Name: "mobile", VideoCodec: "libx264", AudioCodec: "aac", Container: "mp4"}, false},
+		{"unknown container", EncodingProfile{Name: "mobile", VideoCodec: "libx264", AudioCodec: "aac", Container: "avi"}, true},
+		{"yadif deinterlacer", EncodingProfile{Name: "dvd", VideoCodec: "libx264", AudioCodec: "aac", Deinterlacer: "yadif"}, false},
+		{"unknown deinterlacer", EncodingProfile{Name: "dvd", VideoCodec: "libx264", AudioCodec: "aac", Deinterlacer: "nnedi"}, true},
+		{"keep larger output", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", LargerOutput: "keep"}, false},
+		{"retry larger output", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", CRF: 20, LargerOutput: "retry"}, false},
+		{"retry larger output without crf", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", LargerOutput: "retry"}, true},
+		{"retry larger output with bitrate", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", CRF: 20, LargerOutput: "retry", Bitrate: BitratePolicy{SourcePercent: 40}}, true},
+		{"unknown larger output", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", LargerOutput: "delete"}, true},
+		{"preset only", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "copy", Preset: "slow"}, false},
+		{"vmaf check", EncodingProfile{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac", CRF: 21, Quality: QualityPolicy{Metric: "vmaf", MinScore: 93, Retries: 2}}, false},
+		{"unknown quality metric", EncodingProfile{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac", Quality: QualityPolicy{Metric: "psnr"}}, true},
+		{"ssim score out of range", EncodingProfile{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac", Quality: QualityPolicy{Metric: "ssim", MinScore: 95}}, true},
+		{"two-pass bitrate", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", Bitrate: BitratePolicy{SourcePercent: 40, Resolutions: []ResolutionBitrate{{MaxWidth: 1920, Kbps: 6000}}}}, false},
+		{"two-pass bitrate not supported", EncodingProfile{Name: "archive", VideoCodec: "libsvtav1", AudioCodec: "aac", Bitrate: BitratePolicy{SourcePercent: 40}}, true},
+		{"invalid source percent", EncodingProfile{Name: "archive", VideoCodec: "libx264", AudioCodec: "aac", Bitrate: BitratePolicy{SourcePercent: 150}}, true},
+		{"invalid resolution bitrate", EncodingProfile{Name: "archive", VideoCodec: "libx264", AudioCodec: "aac", Bitrate: BitratePolicy{Resolutions: []ResolutionBitrate{{MaxWidth: 1280}}}}, true},
+		{"quality retries with bitrate", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "aac", CRF: 21, Bitrate: BitratePolicy{SourcePercent: 40}, Quality: QualityPolicy{Metric: "vmaf", MinScore: 90, Retries: 1}}, true},
+		{"quality retries without crf", EncodingProfile{Name: "archive", VideoCodec: "libx265", AudioCodec: "copy", Quality: QualityPolicy{Metric: "ssim", MinScore: 0.98, Retries: 1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodingProfilesValidateDuplicated(t *testing.T) {
+	profiles := EncodingProfiles{
+		{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac"},
+		{Name: "anime", VideoCodec: "libx264", AudioCodec: "aac"},
+	}
+	if err := profiles.Validate(); err == nil {
+		t.Error("Validate() expected error for duplicated profile names")
+	}
+}
+
+func TestEncodingProfilesGet(t *testing.T) {
+	profiles := EncodingProfiles{
+		{Name: "anime", VideoCodec: "libx265", CRF: 19, AudioCodec: "aac"},
+	}
+
+	profile, err := profiles.Get("anime")
+	if err != nil {
+		t.Fatalf("Get(anime) unexpected error: %v", err)
+	}
+	if profile.CRF != 19 {
+		t.Errorf("Get(anime).CRF = %d, want 19", profile.CRF)
+	}
+
+	profile, err = profiles.Get(DefaultEncodingProfileName)
+	if err != nil {
+		t.Fatalf("Get(default) unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(*profile, DefaultEncodingProfile()) {
+		t.Errorf("Get(default) = %+v, want built-in default", profile)
+	}
+
+	_, err = profiles.Get("unknown")
+	if !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Get(unknown) error = %v, want ErrProfileNotFound", err)
+	}
+}
+
+func TestEncodingProfilesGetOverriddenDefault(t *testing.T) {
+	profiles := EncodingProfiles{
+		{Name: DefaultEncodingProfileName, VideoCodec: "libx264", CRF: 23, AudioCodec: "aac"},
+	}
+
+	profile, err := profiles.Get(DefaultEncodingProfileName)
+	if err != nil {
+		t.Fatalf("Get(default) unexpected error: %v", err)
+	}
+	if profile.VideoCodec != "libx264" {
+		t.Errorf("Get(default).VideoCodec = %q, want libx264", profile.VideoCodec)
+	}
+	if len(profiles.All()) != 1 {
+		t.Errorf("All() returned %d profiles, want 1", len(profiles.All()))
+	}
+}
+
+func TestEncodingProfilesAll(t *testing.T) {
+	profiles := EncodingProfiles{
+		{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac"},
+	}
+
+	all := profiles.All()
+	if len(all) != 2 {
+		t.Fatalf("All() returned %d profiles, want 2", len(all))
+	}
+	if all[0].Name != DefaultEncodingProfileName {
+		t.Errorf("All()[0].Name = %q, want %q", all[0].Name, DefaultEncodingProfileName)
+	}
+}
+
+func TestEncodingProfileRequiredEncoders(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile EncodingProfile
+		want    []string
+	}{
+		{"default profile", DefaultEncodingProfile(), []string{"libx265", "libfdk_aac", "srt"}},
+		{"copied audio", EncodingProfile{VideoCodec: "libsvtav1", AudioCodec: "copy"}, []string{"libsvtav1", "srt"}},
+		{"mp4 container", EncodingProfile{VideoCodec: "libx264", AudioCodec: "aac", Container: ContainerMP4}, []string{"libx264", "aac", "mov_text"}},
+		{"vmaf verified", EncodingProfile{VideoCodec: "libx265", AudioCodec: "copy", Quality: QualityPolicy{Metric: QualityMetricVMAF}}, []string{"libx265", "srt", "libvmaf"}},
+		{"ssim verified", EncodingProfile{VideoCodec: "libx265", AudioCodec: "copy", Quality: QualityPolicy{Metric: QualityMetricSSIM}}, []string{"libx265", "srt"}},
+		{"no codecs", EncodingProfile{}, []string{"srt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.RequiredEncoders(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequiredEncoders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
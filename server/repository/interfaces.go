@@ -32,18 +32,20 @@ type JobRepository interface {
 	GetJobs(ctx context.Context) (*[]model.Job, error)
 	GetJobByPath(ctx context.Context, path string) (*model.Job, error)
 	AddJob(ctx context.Context, job *model.Job) error
+	GetJobSegments(ctx context.Context, parentID string) ([]*model.Job, error)
+	GetExpiredSampleJobs(ctx context.Context, retention time.Duration) ([]*model.Job, error)
 	UpdateJobPriority(ctx context.Context, jobID string, priority int) error
 }
 
 type WorkerRepository interface {
 	GetWorker(ctx context.Context, name string) (*model.Worker, error)
 	GetWorkers(ctx context.Context) (*[]model.Worker, error)
-	PingServerUpdate(ctx context.Context, name string, ip string, queueName string) error
+	PingServerUpdate(ctx context.Context, name string, queueName string, ip string, state model.WorkerState, capabilities *model.WorkerCapabilities) error
 }
 
 type QueueRepository interface {
 	EnqueueEncodeJob(ctx context.Context, task *model.TaskEncode) error
-	DequeueEncodeJob(ctx context.Context, workerName string) (*model.TaskEncode, error)
+	DequeueEncodeJob(ctx context.Context, workerName string, jobTypes []model.JobType, capabilities *model.WorkerCapabilities) (*model.TaskEncode, error)
 	EnqueuePGSJob(ctx context.Context, pgs *model.TaskPGS) error
 	DequeuePGSJob(ctx context.Context, workerName string) (*model.TaskPGS, error)
 	EnqueuePGSResponse(ctx context.Context, resp *model.TaskPGSResponse) error
@@ -52,6 +54,7 @@ type QueueRepository interface {
 	DequeueTaskEvents(ctx context.Context, limit int) ([]*model.TaskEvent, error)
 	EnqueueJobAction(ctx context.Context, jobID string, workerName string, action model.JobAction) error
 	DequeueJobActions(ctx context.Context, workerName string) ([]*model.JobEvent, error)
+	DeletePendingEncodeJob(ctx context.Context, jobID string) (bool, error)
 }
 
 type EventRepository interface {
@@ -72,6 +75,7 @@ type ScanRepository interface {
 	UpsertScannedFile(ctx context.Context, file *model.ScannedFile) error
 	GetScannedFile(ctx context.Context, path string) (*model.ScannedFile, error)
 	GetScannedFilesByScan(ctx context.Context, scanID string) ([]*model.ScannedFile, error)
+	SetScannedFileKeptOriginal(ctx context.Context, path string) error
 }
 
 type WebhookEventRepository interface {
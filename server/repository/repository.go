@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"gearr/helper"
 	"gearr/internal/constants"
@@ -104,7 +105,7 @@ func (S *SQLRepository) ProcessEvent(ctx context.Context, taskEvent *model.TaskE
 	var err error
 	switch taskEvent.EventType {
 	case model.PingEvent:
-		err = S.PingServerUpdate(ctx, taskEvent.WorkerName, taskEvent.WorkerQueue, taskEvent.IP)
+		err = S.PingServerUpdate(ctx, taskEvent.WorkerName, taskEvent.WorkerQueue, taskEvent.IP, taskEvent.WorkerState, taskEvent.Capabilities)
 	case model.NotificationEvent:
 		err = S.AddNewTaskEvent(ctx, taskEvent)
 	}
@@ -235,7 +236,7 @@ func (S *SQLRepository) GetWorker(ctx context.Context, name string) (worker *mod
 }
 
 func (S *SQLRepository) getWorker(ctx context.Context, db Transaction, name string) (*model.Worker, error) {
-	rows, err := db.QueryContext(ctx, "SELECT * FROM workers WHERE name=$1", name)
+	rows, err := db.QueryContext(ctx, "SELECT name, ip, queue_name, last_seen, state, capabilities FROM workers WHERE name=$1", name)
 	if err != nil {
 		return nil, err
 	}
@@ -243,7 +244,11 @@ func (S *SQLRepository) getWorker(ctx context.Context, db Transaction, name stri
 	worker := model.Worker{}
 	found := false
 	if rows.Next() {
-		if err := rows.Scan(&worker.Name, &worker.Ip, &worker.QueueName, &worker.LastSeen); err != nil {
+		var capabilities sql.NullString
+		if err := rows.Scan(&worker.Name, &worker.Ip, &worker.QueueName, &worker.LastSeen, &worker.State, &capabilities); err != nil {
+			return nil, err
+		}
+		if worker.Capabilities, err = parseWorkerCapabilities(capabilities); err != nil {
 			return nil, err
 		}
 		found = true
@@ -263,7 +268,7 @@ func (S *SQLRepository) GetWorkers(ctx context.Context) (*[]model.Worker, error)
 }
 
 func (S *SQLRepository) getWorkers(ctx context.Context, db Transaction) (*[]model.Worker, error) {
-	rows, err := db.QueryContext(ctx, "SELECT name, ip, queue_name, last_seen FROM workers")
+	rows, err := db.QueryContext(ctx, "SELECT name, ip, queue_name, last_seen, state, capabilities FROM workers")
 	if err != nil {
 		return nil, err
 	}
@@ -272,7 +277,11 @@ func (S *SQLRepository) getWorkers(ctx context.Context, db Transaction) (*[]mode
 	workers := []model.Worker{}
 	for rows.Next() {
 		worker := model.Worker{}
-		if err := rows.Scan(&worker.Name, &worker.Ip, &worker.QueueName, &worker.LastSeen); err != nil {
+		var capabilities sql.NullString
+		if err := rows.Scan(&worker.Name, &worker.Ip, &worker.QueueName, &worker.LastSeen, &worker.State, &capabilities); err != nil {
+			return nil, err
+		}
+		if worker.Capabilities, err = parseWorkerCapabilities(capabilities); err != nil {
 			return nil, err
 		}
 		workers = append(workers, worker)
@@ -318,9 +327,10 @@ func (S *SQLRepository) GetTimeoutJobs(ctx context.Context, timeout time.Duratio
 
 func (S *SQLRepository) getJob(ctx context.Context, tx Transaction, uuid string) (*model.Job, error) {
 	query := `
-		SELECT j.id, j.source_path, j.destination_path, j.priority,
+		SELECT j.id, j.source_path, j.destination_path, j.priority, j.profile, j.parent_id, j.segment,
+			   j.sample, COALESCE(j.quality_metric, ''), j.quality_score,
 			   COALESCE(js.event_time, NULL), COALESCE(js.status, ''), 
-			   COALESCE(js.notification_type, ''), COALESCE(js.message, '')
+			   COALESCE(js.notification_type, ''), COALESCE(js.message, ''), js.progress
 		FROM jobs j
 		LEFT JOIN job_status js ON j.id = js.job_id
 		WHERE j.id = $1
@@ -335,14 +345,28 @@ func (S *SQLRepository) getJob(ctx context.Context, tx Transaction, uuid string)
 	found := false
 	if rows.Next() {
 		var lastUpdate sql.NullTime
+		var parentID, segment, sample, progress sql.NullString
+		var qualityScore sql.NullFloat64
 		var status, statusPhase, statusMessage string
-		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority,
-			&lastUpdate, &status, &statusPhase, &statusMessage); err != nil {
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority, &job.Profile, &parentID, &segment,
+			&sample, &job.QualityMetric, &qualityScore, &lastUpdate, &status, &statusPhase, &statusMessage, &progress); err != nil {
+			return nil, err
+		}
+		if job.EncodeProgress, err = model.ParseEncodeProgress([]byte(progress.String)); err != nil {
 			return nil, err
 		}
 		if lastUpdate.Valid {
 			job.LastUpdate = &lastUpdate.Time
 		}
+		if qualityScore.Valid {
+			job.QualityScore = &qualityScore.Float64
+		}
+		if err := scanJobSegment(&job, parentID, segment); err != nil {
+			return nil, err
+		}
+		if err := scanJobSample(&job, sample); err != nil {
+			return nil, err
+		}
 		job.Status = status
 		job.StatusPhase = model.NotificationType(statusPhase)
 		job.StatusMessage = statusMessage
@@ -372,9 +396,11 @@ func (S *SQLRepository) deleteJob(tx Transaction, uuid string) error {
 
 func (S *SQLRepository) getJobs(ctx context.Context, tx Transaction) (*[]model.Job, error) {
 	query := fmt.Sprintf(`
-    SELECT v.id, v.source_path, v.destination_path, v.priority, vs.event_time, vs.status, vs.notification_type, vs.message
+    SELECT v.id, v.source_path, v.destination_path, v.priority, v.profile, v.sample, COALESCE(v.quality_metric, ''), v.quality_score,
+           vs.event_time, vs.status, vs.notification_type, vs.message, vs.progress
     FROM jobs v
     INNER JOIN job_status vs ON v.id = vs.job_id
+    WHERE v.parent_id IS NULL
 `)
 	rows, err := tx.QueryContext(ctx, query)
 	if err != nil {
@@ -385,9 +411,21 @@ func (S *SQLRepository) getJobs(ctx context.Context, tx Transaction) (*[]model.J
 	jobs := []model.Job{}
 	for rows.Next() {
 		job := model.Job{}
-		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority, &job.LastUpdate, &job.Status, &job.StatusPhase, &job.StatusMessage); err != nil {
+		var sample, progress sql.NullString
+		var qualityScore sql.NullFloat64
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority, &job.Profile, &sample, &job.QualityMetric, &qualityScore,
+			&job.LastUpdate, &job.Status, &job.StatusPhase, &job.StatusMessage, &progress); err != nil {
 			return nil, err
 		}
+		if job.EncodeProgress, err = model.ParseEncodeProgress([]byte(progress.String)); err != nil {
+			return nil, err
+		}
+		if err := scanJobSample(&job, sample); err != nil {
+			return nil, err
+		}
+		if qualityScore.Valid {
+			job.QualityScore = &qualityScore.Float64
+		}
 		jobs = append(jobs, job)
 	}
 
@@ -395,7 +433,7 @@ func (S *SQLRepository) getJobs(ctx context.Context, tx Transaction) (*[]model.J
 }
 
 func (S *SQLRepository) getTaskEvents(ctx context.Context, tx Transaction, uuid string) ([]*model.TaskEvent, error) {
-	rows, err := tx.QueryContext(ctx, "SELECT job_id, job_event_id, worker_name, event_time, event_type, notification_type, status, message FROM job_events WHERE job_id=$1 order by event_time asc", uuid)
+	rows, err := tx.QueryContext(ctx, "SELECT job_id, job_event_id, worker_name, event_time, event_type, notification_type, status, message, progress FROM job_events WHERE job_id=$1 order by event_time asc", uuid)
 	if err != nil {
 		helper.Errorf("no job events founds by uuid: %s", uuid)
 		return nil, err
@@ -404,7 +442,11 @@ func (S *SQLRepository) getTaskEvents(ctx context.Context, tx Transaction, uuid
 	var taskEvents []*model.TaskEvent
 	for rows.Next() {
 		event := model.TaskEvent{}
-		if err := rows.Scan(&event.Id, &event.EventID, &event.WorkerName, &event.EventTime, &event.EventType, &event.NotificationType, &event.Status, &event.Message); err != nil {
+		var progress sql.NullString
+		if err := rows.Scan(&event.Id, &event.EventID, &event.WorkerName, &event.EventTime, &event.EventType, &event.NotificationType, &event.Status, &event.Message, &progress); err != nil {
+			return nil, err
+		}
+		if event.Progress, err = model.ParseEncodeProgress([]byte(progress.String)); err != nil {
 			return nil, err
 		}
 		taskEvents = append(taskEvents, &event)
@@ -435,12 +477,12 @@ func (S *SQLRepository) getJobStatus(ctx context.Context, tx Transaction, uuid s
 
 func (S *SQLRepository) getJobByPath(ctx context.Context, tx Transaction, path string) (*model.Job, error) {
 	query := `
-		SELECT j.id, j.source_path, j.destination_path, j.priority,
+		SELECT j.id, j.source_path, j.destination_path, j.priority, j.profile,
 			   COALESCE(js.event_time, NULL), COALESCE(js.status, ''),
 			   COALESCE(js.notification_type, ''), COALESCE(js.message, '')
 		FROM jobs j
 		LEFT JOIN job_status js ON j.id = js.job_id
-		WHERE j.source_path = $1
+		WHERE j.source_path = $1 AND j.parent_id IS NULL AND j.sample IS NULL
 	`
 	rows, err := tx.QueryContext(ctx, query, path)
 	if err != nil {
@@ -453,7 +495,7 @@ func (S *SQLRepository) getJobByPath(ctx context.Context, tx Transaction, path s
 	if rows.Next() {
 		var lastUpdate sql.NullTime
 		var status, statusPhase, statusMessage string
-		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority,
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority, &job.Profile,
 			&lastUpdate, &status, &statusPhase, &statusMessage); err != nil {
 			return nil, err
 		}
@@ -485,12 +527,20 @@ func (S *SQLRepository) GetJobByPath(ctx context.Context, path string) (*model.J
 	return S.getJobByPath(ctx, conn, path)
 }
 
-func (S *SQLRepository) PingServerUpdate(ctx context.Context, name string, queueName string, ip string) (returnError error) {
+func (S *SQLRepository) PingServerUpdate(ctx context.Context, name string, queueName string, ip string, state model.WorkerState, capabilities *model.WorkerCapabilities) (returnError error) {
 	conn, err := S.getConnection(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = conn.ExecContext(ctx, "INSERT INTO workers (name, ip,queue_name,last_seen ) VALUES ($1,$2,$3,$4) ON CONFLICT (name) DO UPDATE SET ip = $2, queue_name=$3, last_seen=$4;", name, ip, queueName, time.Now())
+	// workers older than the worker states do not report one
+	if state == "" {
+		state = model.ActiveWorkerState
+	}
+	capabilitiesJSON, err := capabilitiesValue(capabilities)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "INSERT INTO workers (name, ip,queue_name,last_seen,state,capabilities ) VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT (name) DO UPDATE SET ip = $2, queue_name=$3, last_seen=$4, state=$5, capabilities=$6;", name, ip, queueName, time.Now(), state, capabilitiesJSON)
 	return err
 }
 
@@ -525,8 +575,29 @@ func (S *SQLRepository) addNewTaskEvent(ctx context.Context, tx Transaction, eve
 	}
 
 	rows.Close()
-	_, err = tx.ExecContext(ctx, "INSERT INTO job_events (job_id, job_event_id,worker_name,event_time,event_type,notification_type,status,message)"+
-		" VALUES ($1,$2,$3,$4,$5,$6,$7,$8)", event.Id.String(), event.EventID, event.WorkerName, time.Now(), event.EventType, event.NotificationType, event.Status, strings.TrimSpace(event.Message))
+	progress, err := progressValue(event.Progress)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO job_events (job_id, job_event_id,worker_name,event_time,event_type,notification_type,status,message,progress)"+
+		" VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)", event.Id.String(), event.EventID, event.WorkerName, time.Now(), event.EventType, event.NotificationType, event.Status, strings.TrimSpace(event.Message), progress)
+	if err != nil {
+		return err
+	}
+	if event.NotificationType == model.QualityNotification && event.Status != model.ProgressingNotificationStatus {
+		return S.updateJobQuality(ctx, tx, event)
+	}
+	return nil
+}
+
+// updateJobQuality keeps the score of the latest verified encode on the job.
+func (S *SQLRepository) updateJobQuality(ctx context.Context, tx Transaction, event *model.TaskEvent) error {
+	result, err := model.ParseQualityResult(event.Message)
+	if err != nil {
+		// failed checks report the error instead of a score
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE jobs SET quality_metric=$1, quality_score=$2 WHERE id=$3", result.Metric, result.Score, event.Id.String())
 	return err
 }
 func (S *SQLRepository) AddJob(ctx context.Context, job *model.Job) error {
@@ -538,16 +609,209 @@ func (S *SQLRepository) AddJob(ctx context.Context, job *model.Job) error {
 }
 
 func (S *SQLRepository) addJob(ctx context.Context, tx Transaction, job *model.Job) error {
-	_, err := tx.ExecContext(ctx, "INSERT INTO jobs (id, source_path, destination_path, priority)"+
-		" VALUES ($1,$2,$3,$4)", job.Id.String(), job.SourcePath, job.DestinationPath, job.Priority)
+	profile := job.Profile
+	if profile == "" {
+		profile = model.DefaultEncodingProfileName
+	}
+	var parentID, segment, sample interface{}
+	if job.ParentId != nil {
+		parentID = job.ParentId.String()
+	}
+	if job.Segment != nil {
+		segmentJSON, err := json.Marshal(job.Segment)
+		if err != nil {
+			return err
+		}
+		segment = string(segmentJSON)
+	}
+	if job.Sample != nil {
+		sampleJSON, err := json.Marshal(job.Sample)
+		if err != nil {
+			return err
+		}
+		sample = string(sampleJSON)
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO jobs (id, source_path, destination_path, priority, profile, parent_id, segment, sample)"+
+		" VALUES ($1,$2,$3,$4,$5,$6,$7,$8)", job.Id.String(), job.SourcePath, job.DestinationPath, job.Priority, profile, parentID, segment, sample)
 	return err
 }
 
+func (S *SQLRepository) GetJobSegments(ctx context.Context, parentID string) ([]*model.Job, error) {
+	conn, err := S.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return S.getJobSegments(ctx, conn, parentID)
+}
+
+// getJobSegments returns the segment jobs of a chunked job, with their current
+// status but without their events, sorted by segment index.
+func (S *SQLRepository) getJobSegments(ctx context.Context, tx Transaction, parentID string) ([]*model.Job, error) {
+	query := `
+		SELECT j.id, j.source_path, j.destination_path, j.priority, j.profile, j.parent_id, j.segment,
+			   j.sample, COALESCE(j.quality_metric, ''), j.quality_score,
+			   COALESCE(js.event_time, NULL), COALESCE(js.status, ''),
+			   COALESCE(js.notification_type, ''), COALESCE(js.message, ''), js.progress
+		FROM jobs j
+		LEFT JOIN job_status js ON j.id = js.job_id
+		WHERE j.parent_id = $1
+		ORDER BY (j.segment->>'index')::int ASC
+	`
+	rows, err := tx.QueryContext(ctx, query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []*model.Job
+	for rows.Next() {
+		job := &model.Job{}
+		var lastUpdate sql.NullTime
+		var parent, segment, sample, progress sql.NullString
+		var qualityScore sql.NullFloat64
+		var status, statusPhase, statusMessage string
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Priority, &job.Profile, &parent, &segment,
+			&sample, &job.QualityMetric, &qualityScore, &lastUpdate, &status, &statusPhase, &statusMessage, &progress); err != nil {
+			return nil, err
+		}
+		if job.EncodeProgress, err = model.ParseEncodeProgress([]byte(progress.String)); err != nil {
+			return nil, err
+		}
+		if lastUpdate.Valid {
+			job.LastUpdate = &lastUpdate.Time
+		}
+		if qualityScore.Valid {
+			job.QualityScore = &qualityScore.Float64
+		}
+		if err := scanJobSegment(job, parent, segment); err != nil {
+			return nil, err
+		}
+		if err := scanJobSample(job, sample); err != nil {
+			return nil, err
+		}
+		job.Status = status
+		job.StatusPhase = model.NotificationType(statusPhase)
+		job.StatusMessage = statusMessage
+		segments = append(segments, job)
+	}
+	return segments, nil
+}
+
+func scanJobSegment(job *model.Job, parentID sql.NullString, segment sql.NullString) error {
+	if parentID.Valid {
+		id, err := uuid.Parse(parentID.String)
+		if err != nil {
+			return err
+		}
+		job.ParentId = &id
+	}
+	if segment.Valid {
+		job.Segment = &model.Segment{}
+		if err := json.Unmarshal([]byte(segment.String), job.Segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressValue stores the encode progress of an event as JSON, NULL when the
+// event has none.
+func progressValue(progress *model.EncodeProgress) (interface{}, error) {
+	if progress == nil {
+		return nil, nil
+	}
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return nil, err
+	}
+	return string(progressJSON), nil
+}
+
+func capabilitiesValue(capabilities *model.WorkerCapabilities) (interface{}, error) {
+	if capabilities == nil {
+		return nil, nil
+	}
+	capabilitiesJSON, err := json.Marshal(capabilities)
+	if err != nil {
+		return nil, err
+	}
+	return string(capabilitiesJSON), nil
+}
+
+// labelsValue returns the labels as a JSON object, empty when there are none.
+func labelsValue(labels map[string]string) (string, error) {
+	if labels == nil {
+		return "{}", nil
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(labelsJSON), nil
+}
+
+func parseWorkerCapabilities(capabilities sql.NullString) (*model.WorkerCapabilities, error) {
+	if !capabilities.Valid {
+		return nil, nil
+	}
+	parsed := &model.WorkerCapabilities{}
+	if err := json.Unmarshal([]byte(capabilities.String), parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+func scanJobSample(job *model.Job, sample sql.NullString) error {
+	if !sample.Valid {
+		return nil
+	}
+	job.Sample = &model.Sample{}
+	return json.Unmarshal([]byte(sample.String), job.Sample)
+}
+
+func (S *SQLRepository) GetExpiredSampleJobs(ctx context.Context, retention time.Duration) ([]*model.Job, error) {
+	conn, err := S.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return S.getExpiredSampleJobs(ctx, conn, retention)
+}
+
+// getExpiredSampleJobs returns the sample jobs not updated within the
+// retention period, without their events.
+func (S *SQLRepository) getExpiredSampleJobs(ctx context.Context, tx Transaction, retention time.Duration) ([]*model.Job, error) {
+	query := `
+		SELECT j.id, j.source_path, j.destination_path, j.profile, j.sample
+		FROM jobs j
+		INNER JOIN job_status js ON j.id = js.job_id
+		WHERE j.sample IS NOT NULL AND j.parent_id IS NULL AND js.event_time < $1::timestamptz
+	`
+	rows, err := tx.QueryContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*model.Job
+	for rows.Next() {
+		job := &model.Job{}
+		var sample sql.NullString
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Profile, &sample); err != nil {
+			return nil, err
+		}
+		if err := scanJobSample(job, sample); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
 func (S *SQLRepository) getTimeoutJobs(ctx context.Context, tx Transaction, timeout time.Duration) ([]*model.TimeoutJob, error) {
 	timeoutDate := time.Now().Add(-timeout)
 
 	query := `
-		SELECT je.job_id, j.source_path, j.destination_path, je.status
+		SELECT je.job_id, j.source_path, j.destination_path, je.status, j.profile
 		FROM job_events je
 		INNER JOIN jobs j ON je.job_id = j.id
 		INNER JOIN (
@@ -556,7 +820,7 @@ func (S *SQLRepository) getTimeoutJobs(ctx context.Context, tx Transaction, time
 			WHERE notification_type = 'Job'
 			GROUP BY job_id
 		) latest ON je.job_id = latest.job_id AND je.job_event_id = latest.max_event_id
-		WHERE je.status = 'started' AND je.event_time < $1::timestamptz
+		WHERE je.status = 'started' AND je.event_time < $1::timestamptz AND j.parent_id IS NULL AND j.sample IS NULL
 	`
 	rows, err := tx.QueryContext(ctx, query, timeoutDate)
 	if err != nil {
@@ -567,7 +831,7 @@ func (S *SQLRepository) getTimeoutJobs(ctx context.Context, tx Transaction, time
 	var timeoutJobs []*model.TimeoutJob
 	for rows.Next() {
 		job := &model.TimeoutJob{}
-		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Status); err != nil {
+		if err := rows.Scan(&job.Id, &job.SourcePath, &job.DestinationPath, &job.Status, &job.Profile); err != nil {
 			return nil, err
 		}
 		timeoutJobs = append(timeoutJobs, job)
@@ -606,33 +870,94 @@ func (S *SQLRepository) EnqueueEncodeJob(ctx context.Context, task *model.TaskEn
 	if err != nil {
 		return err
 	}
+	var profile interface{}
+	requiredEncoders := []string{}
+	if task.Profile != nil {
+		profileJSON, err := json.Marshal(task.Profile)
+		if err != nil {
+			return err
+		}
+		profile = string(profileJSON)
+		requiredEncoders = task.Profile.RequiredEncoders()
+	}
+	var segment, segmentURLs interface{}
+	if task.Segment != nil {
+		segmentJSON, err := json.Marshal(task.Segment)
+		if err != nil {
+			return err
+		}
+		segment = string(segmentJSON)
+	}
+	if len(task.SegmentURLs) > 0 {
+		segmentURLsJSON, err := json.Marshal(task.SegmentURLs)
+		if err != nil {
+			return err
+		}
+		segmentURLs = string(segmentURLsJSON)
+	}
+	var analysis interface{}
+	if task.Analysis != nil {
+		analysisJSON, err := json.Marshal(task.Analysis)
+		if err != nil {
+			return err
+		}
+		analysis = string(analysisJSON)
+	}
+	requiredLabels, err := labelsValue(task.Labels)
+	if err != nil {
+		return err
+	}
 	_, err = conn.ExecContext(ctx,
-		"INSERT INTO encode_queue (job_id, download_url, upload_url, checksum_url, event_id) VALUES ($1, $2, $3, $4, $5)",
-		task.Id.String(), task.DownloadURL, task.UploadURL, task.ChecksumURL, task.EventID)
+		"INSERT INTO encode_queue (job_id, download_url, upload_url, checksum_url, event_id, profile, job_type, segment, segment_urls, sample, source_path, destination_path, required_encoders, required_labels, analysis) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)",
+		task.Id.String(), task.DownloadURL, task.UploadURL, task.ChecksumURL, task.EventID, profile, task.JobType(), segment, segmentURLs, task.Sample, task.SourcePath, task.DestinationPath, requiredEncoders, requiredLabels, analysis)
 	return err
 }
 
-func (S *SQLRepository) DequeueEncodeJob(ctx context.Context, workerName string) (*model.TaskEncode, error) {
+// DequeueEncodeJob locks the next pending job of the given types that the
+// worker can run. Jobs needing encoders missing from the worker capabilities
+// are left for other workers, nil capabilities or encoders match any job.
+// Jobs routed by labels are only taken by workers having all of them.
+func (S *SQLRepository) DequeueEncodeJob(ctx context.Context, workerName string, jobTypes []model.JobType, capabilities *model.WorkerCapabilities) (*model.TaskEncode, error) {
 	conn, err := S.getConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	types := make([]string, len(jobTypes))
+	for i, jobType := range jobTypes {
+		types[i] = string(jobType)
+	}
+	if capabilities == nil {
+		capabilities = &model.WorkerCapabilities{}
+	}
+	matchEncoders := capabilities.Encoders != nil
+	encoders := []string{}
+	if matchEncoders {
+		encoders = capabilities.Encoders
+	}
+	labels, err := labelsValue(capabilities.Labels)
+	if err != nil {
+		return nil, err
+	}
+
 	var task model.TaskEncode
 	var jobID string
+	var profile, segment, segmentURLs, analysis sql.NullString
 	err = conn.QueryRowContext(ctx, `
 		UPDATE encode_queue 
 		SET status = 'processing', locked_at = NOW(), locked_by = $1
 		WHERE id = (
 			SELECT eq.id FROM encode_queue eq
 			JOIN jobs j ON eq.job_id = j.id
-			WHERE eq.status = 'pending'
+			WHERE eq.status = 'pending' AND eq.job_type = ANY($2)
+			AND (NOT $4 OR eq.required_encoders <@ $3::text[])
+			AND eq.required_labels <@ $5::jsonb
 			ORDER BY j.priority DESC, eq.created_at ASC
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED
 		)
-		RETURNING job_id, download_url, upload_url, checksum_url, event_id
-	`, workerName).Scan(&jobID, &task.DownloadURL, &task.UploadURL, &task.ChecksumURL, &task.EventID)
+		RETURNING job_id, download_url, upload_url, checksum_url, event_id, profile, segment, segment_urls, sample, source_path, destination_path, analysis
+	`, workerName, types, encoders, matchEncoders, labels).Scan(&jobID, &task.DownloadURL, &task.UploadURL, &task.ChecksumURL, &task.EventID, &profile, &segment, &segmentURLs, &task.Sample, &task.SourcePath, &task.DestinationPath, &analysis)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -645,6 +970,29 @@ func (S *SQLRepository) DequeueEncodeJob(ctx context.Context, workerName string)
 	if err != nil {
 		return nil, err
 	}
+	if profile.Valid {
+		task.Profile = &model.EncodingProfile{}
+		if err := json.Unmarshal([]byte(profile.String), task.Profile); err != nil {
+			return nil, err
+		}
+	}
+	if segment.Valid {
+		task.Segment = &model.Segment{}
+		if err := json.Unmarshal([]byte(segment.String), task.Segment); err != nil {
+			return nil, err
+		}
+	}
+	if segmentURLs.Valid {
+		if err := json.Unmarshal([]byte(segmentURLs.String), &task.SegmentURLs); err != nil {
+			return nil, err
+		}
+	}
+	if analysis.Valid {
+		task.Analysis = &model.SourceAnalysis{}
+		if err := json.Unmarshal([]byte(analysis.String), task.Analysis); err != nil {
+			return nil, err
+		}
+	}
 	return &task, nil
 }
 
@@ -766,10 +1114,18 @@ func (S *SQLRepository) EnqueueTaskEvent(ctx context.Context, event *model.TaskE
 	if err != nil {
 		return err
 	}
+	progress, err := progressValue(event.Progress)
+	if err != nil {
+		return err
+	}
+	capabilities, err := capabilitiesValue(event.Capabilities)
+	if err != nil {
+		return err
+	}
 	_, err = conn.ExecContext(ctx,
-		`INSERT INTO task_event_queue (job_id, event_id, event_type, worker_name, worker_queue, event_time, ip, notification_type, status, message)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		event.Id.String(), event.EventID, event.EventType, event.WorkerName, event.WorkerQueue, event.EventTime, event.IP, event.NotificationType, event.Status, event.Message)
+		`INSERT INTO task_event_queue (job_id, event_id, event_type, worker_name, worker_queue, worker_state, event_time, ip, notification_type, status, message, progress, capabilities)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		event.Id.String(), event.EventID, event.EventType, event.WorkerName, event.WorkerQueue, event.WorkerState, event.EventTime, event.IP, event.NotificationType, event.Status, event.Message, progress, capabilities)
 	return err
 }
 
@@ -787,7 +1143,7 @@ func (S *SQLRepository) DequeueTaskEvents(ctx context.Context, limit int) ([]*mo
 			LIMIT $1
 			FOR UPDATE SKIP LOCKED
 		)
-		RETURNING job_id, event_id, event_type, worker_name, worker_queue, event_time, ip, notification_type, status, message
+		RETURNING job_id, event_id, event_type, worker_name, worker_queue, worker_state, event_time, ip, notification_type, status, message, progress, capabilities
 	`, limit)
 	if err != nil {
 		return nil, err
@@ -798,7 +1154,14 @@ func (S *SQLRepository) DequeueTaskEvents(ctx context.Context, limit int) ([]*mo
 	for rows.Next() {
 		var event model.TaskEvent
 		var jobID string
-		if err := rows.Scan(&jobID, &event.EventID, &event.EventType, &event.WorkerName, &event.WorkerQueue, &event.EventTime, &event.IP, &event.NotificationType, &event.Status, &event.Message); err != nil {
+		var progress, capabilities sql.NullString
+		if err := rows.Scan(&jobID, &event.EventID, &event.EventType, &event.WorkerName, &event.WorkerQueue, &event.WorkerState, &event.EventTime, &event.IP, &event.NotificationType, &event.Status, &event.Message, &progress, &capabilities); err != nil {
+			return nil, err
+		}
+		if event.Capabilities, err = parseWorkerCapabilities(capabilities); err != nil {
+			return nil, err
+		}
+		if event.Progress, err = model.ParseEncodeProgress([]byte(progress.String)); err != nil {
 			return nil, err
 		}
 		event.Id, err = uuid.Parse(jobID)
@@ -859,6 +1222,21 @@ func (S *SQLRepository) DequeueJobActions(ctx context.Context, workerName string
 	return actions, nil
 }
 
+// DeletePendingEncodeJob removes the task of a job no worker took yet,
+// returning false when it was already dequeued.
+func (S *SQLRepository) DeletePendingEncodeJob(ctx context.Context, jobID string) (bool, error) {
+	conn, err := S.getConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+	result, err := conn.ExecContext(ctx, "DELETE FROM encode_queue WHERE job_id = $1 AND status = 'pending'", jobID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
 func (S *SQLRepository) AddFileProcessing(ctx context.Context, fp *model.FileProcessing) error {
 	conn, err := S.getConnection(ctx)
 	if err != nil {
@@ -1093,7 +1471,7 @@ func (S *SQLRepository) GetScannedFile(ctx context.Context, path string) (*model
 		return nil, err
 	}
 	rows, err := conn.QueryContext(ctx,
-		`SELECT id, file_path, file_size, codec, last_scanned_at, queued, scan_id
+		`SELECT id, file_path, file_size, codec, last_scanned_at, queued, kept_original, scan_id
 		 FROM scanned_files WHERE file_path=$1`, path)
 	if err != nil {
 		return nil, err
@@ -1103,7 +1481,7 @@ func (S *SQLRepository) GetScannedFile(ctx context.Context, path string) (*model
 	if rows.Next() {
 		file := &model.ScannedFile{}
 		if err := rows.Scan(&file.Id, &file.FilePath, &file.FileSize, &file.Codec,
-			&file.LastScannedAt, &file.Queued, &file.ScanId); err != nil {
+			&file.LastScannedAt, &file.Queued, &file.KeptOriginal, &file.ScanId); err != nil {
 			return nil, err
 		}
 		return file, nil
@@ -1117,7 +1495,7 @@ func (S *SQLRepository) GetScannedFilesByScan(ctx context.Context, scanID string
 		return nil, err
 	}
 	rows, err := conn.QueryContext(ctx,
-		`SELECT id, file_path, file_size, codec, last_scanned_at, queued, scan_id
+		`SELECT id, file_path, file_size, codec, last_scanned_at, queued, kept_original, scan_id
 		 FROM scanned_files WHERE scan_id=$1 ORDER BY last_scanned_at DESC`, scanID)
 	if err != nil {
 		return nil, err
@@ -1128,7 +1506,7 @@ func (S *SQLRepository) GetScannedFilesByScan(ctx context.Context, scanID string
 	for rows.Next() {
 		file := &model.ScannedFile{}
 		if err := rows.Scan(&file.Id, &file.FilePath, &file.FileSize, &file.Codec,
-			&file.LastScannedAt, &file.Queued, &file.ScanId); err != nil {
+			&file.LastScannedAt, &file.Queued, &file.KeptOriginal, &file.ScanId); err != nil {
 			return nil, err
 		}
 		files = append(files, file)
@@ -1136,6 +1514,17 @@ func (S *SQLRepository) GetScannedFilesByScan(ctx context.Context, scanID string
 	return files, nil
 }
 
+// SetScannedFileKeptOriginal marks a scanned file as kept original, the scanner
+// skips it from then on. Files never scanned are ignored.
+func (S *SQLRepository) SetScannedFileKeptOriginal(ctx context.Context, path string) error {
+	conn, err := S.getConnection(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `UPDATE scanned_files SET kept_original = true WHERE file_path = $1`, path)
+	return err
+}
+
 func (S *SQLRepository) CreateWebhookEvent(ctx context.Context, event *model.WebhookEvent) error {
 	conn, err := S.getConnection(ctx)
 	if err != nil {
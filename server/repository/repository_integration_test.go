@@ -4,6 +4,7 @@ import (
 	"context"
 	"gearr/model"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -33,7 +34,7 @@ func TestEnqueueDequeueEncodeJob(t *testing.T) {
 		t.Fatalf("EnqueueEncodeJob failed: %v", err)
 	}
 
-	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker")
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("DequeueEncodeJob failed: %v", err)
 	}
@@ -60,7 +61,7 @@ func TestDequeueEncodeJobEmpty(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
 
-	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker")
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("DequeueEncodeJob failed: %v", err)
 	}
@@ -233,6 +234,87 @@ func TestEnqueueDequeueTaskEvent(t *testing.T) {
 	}
 }
 
+func TestPingEventWorkerState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ping := &model.TaskEvent{
+		EventType:   model.PingEvent,
+		WorkerName:  "test-worker",
+		WorkerQueue: "test-queue",
+		WorkerState: model.DrainingWorkerState,
+		EventTime:   time.Now(),
+	}
+	if err := repo.EnqueueTaskEvent(ctx, ping); err != nil {
+		t.Fatalf("EnqueueTaskEvent failed: %v", err)
+	}
+	events, err := repo.DequeueTaskEvents(ctx, 10)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("DequeueTaskEvents = %d events, %v, want 1", len(events), err)
+	}
+	if err := repo.ProcessEvent(ctx, events[0]); err != nil {
+		t.Fatalf("ProcessEvent failed: %v", err)
+	}
+
+	worker, err := repo.GetWorker(ctx, "test-worker")
+	if err != nil {
+		t.Fatalf("GetWorker failed: %v", err)
+	}
+	if worker.State != model.DrainingWorkerState || worker.QueueName != "test-queue" {
+		t.Errorf("worker = %+v, want draining on test-queue", worker)
+	}
+}
+
+func TestPingEventWorkerCapabilities(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	capabilities := &model.WorkerCapabilities{
+		Version:       "v1.0.0",
+		Threads:       16,
+		AcceptedJobs:  []model.JobType{model.EncodeJobType},
+		EncodeJobs:    2,
+		Encoders:      []string{"libx265", "libfdk_aac"},
+		FreeTempSpace: 1 << 40,
+		Labels:        map[string]string{"pool": "big"},
+	}
+	ping := &model.TaskEvent{
+		EventType:    model.PingEvent,
+		WorkerName:   "test-worker",
+		WorkerQueue:  "test-queue",
+		EventTime:    time.Now(),
+		Capabilities: capabilities,
+	}
+	if err := repo.EnqueueTaskEvent(ctx, ping); err != nil {
+		t.Fatalf("EnqueueTaskEvent failed: %v", err)
+	}
+	events, err := repo.DequeueTaskEvents(ctx, 10)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("DequeueTaskEvents = %d events, %v, want 1", len(events), err)
+	}
+	if err := repo.ProcessEvent(ctx, events[0]); err != nil {
+		t.Fatalf("ProcessEvent failed: %v", err)
+	}
+
+	workers, err := repo.GetWorkers(ctx)
+	if err != nil || len(*workers) != 1 {
+		t.Fatalf("GetWorkers = %v, %v, want 1 worker", workers, err)
+	}
+	if !reflect.DeepEqual((*workers)[0].Capabilities, capabilities) {
+		t.Errorf("Capabilities = %+v, want %+v", (*workers)[0].Capabilities, capabilities)
+	}
+}
+
 func TestDequeueTaskEventsMultiple(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -363,7 +445,7 @@ func TestEncodeJobSkipLocked(t *testing.T) {
 		t.Fatalf("EnqueueEncodeJob failed: %v", err)
 	}
 
-	dequeued1, err := repo.DequeueEncodeJob(ctx, "worker-1")
+	dequeued1, err := repo.DequeueEncodeJob(ctx, "worker-1", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("First DequeueEncodeJob failed: %v", err)
 	}
@@ -372,7 +454,7 @@ func TestEncodeJobSkipLocked(t *testing.T) {
 		t.Fatal("First dequeue should return the job")
 	}
 
-	dequeued2, err := repo.DequeueEncodeJob(ctx, "worker-2")
+	dequeued2, err := repo.DequeueEncodeJob(ctx, "worker-2", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("Second DequeueEncodeJob failed: %v", err)
 	}
@@ -409,7 +491,7 @@ func TestEncodeJobFIFO(t *testing.T) {
 	}
 
 	for i, expectedID := range jobIDs {
-		dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker")
+		dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, nil)
 		if err != nil {
 			t.Fatalf("DequeueEncodeJob %d failed: %v", i, err)
 		}
@@ -523,7 +605,7 @@ func TestDequeueEncodeJobWithPriority(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 	repo.EnqueueEncodeJob(ctx, taskHigh)
 
-	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker")
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("DequeueEncodeJob failed: %v", err)
 	}
@@ -572,7 +654,7 @@ func TestDequeueEncodeJobPriorityThenFIFO(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 	repo.EnqueueEncodeJob(ctx, task2)
 
-	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker")
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, nil)
 	if err != nil {
 		t.Fatalf("DequeueEncodeJob failed: %v", err)
 	}
@@ -635,3 +717,276 @@ func getEnvOrDefault(key, defaultVal string) string {
 func getEnv(key string) string {
 	return os.Getenv(key)
 }
+
+func TestDequeueEncodeJobByJobType(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jobID := uuid.New()
+	db := repo.GetDB()
+	db.ExecContext(ctx, "INSERT INTO jobs (id, source_path, destination_path) VALUES ($1, '/test/segment.mkv', '/test/segment-out.mkv')", jobID.String())
+
+	task := &model.TaskEncode{
+		Id:          jobID,
+		DownloadURL: "http://example.com/segment.mkv",
+		UploadURL:   "http://example.com/upload",
+		ChecksumURL: "http://example.com/checksum",
+		EventID:     1,
+		Segment:     &model.Segment{Index: 2, Start: 600.5, End: 901.2},
+		Analysis:    &model.SourceAnalysis{Crop: "crop=1920:800:0:140", Size: 4 << 30, Duration: 5400.5},
+	}
+	if err := repo.EnqueueEncodeJob(ctx, task); err != nil {
+		t.Fatalf("EnqueueEncodeJob failed: %v", err)
+	}
+
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", []model.JobType{model.EncodeJobType}, nil)
+	if err != nil {
+		t.Fatalf("DequeueEncodeJob failed: %v", err)
+	}
+	if dequeued != nil {
+		t.Fatalf("Expected segment task to be skipped by encode only worker, got %+v", dequeued)
+	}
+
+	dequeued, err = repo.DequeueEncodeJob(ctx, "test-worker", []model.JobType{model.EncodeSegmentJobType}, nil)
+	if err != nil {
+		t.Fatalf("DequeueEncodeJob failed: %v", err)
+	}
+	if dequeued == nil || dequeued.Segment == nil {
+		t.Fatalf("Expected segment task, got %+v", dequeued)
+	}
+	if *dequeued.Segment != *task.Segment {
+		t.Errorf("Segment mismatch: got %+v, want %+v", *dequeued.Segment, *task.Segment)
+	}
+	if dequeued.Analysis == nil || *dequeued.Analysis != *task.Analysis {
+		t.Errorf("Analysis mismatch: got %+v, want %+v", dequeued.Analysis, *task.Analysis)
+	}
+}
+
+func TestDequeueEncodeJobByEncoders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jobID := uuid.New()
+	db := repo.GetDB()
+	db.ExecContext(ctx, "INSERT INTO jobs (id, source_path, destination_path) VALUES ($1, '/test/av1.mkv', '/test/av1-out.mkv')", jobID.String())
+
+	task := &model.TaskEncode{
+		Id:          jobID,
+		DownloadURL: "http://example.com/av1.mkv",
+		UploadURL:   "http://example.com/upload",
+		ChecksumURL: "http://example.com/checksum",
+		EventID:     1,
+		Profile:     &model.EncodingProfile{Name: "av1", VideoCodec: "libsvtav1", AudioCodec: "libopus"},
+	}
+	if err := repo.EnqueueEncodeJob(ctx, task); err != nil {
+		t.Fatalf("EnqueueEncodeJob failed: %v", err)
+	}
+
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, &model.WorkerCapabilities{Encoders: []string{"libx265", "libopus"}})
+	if err != nil {
+		t.Fatalf("DequeueEncodeJob failed: %v", err)
+	}
+	if dequeued != nil {
+		t.Fatalf("Expected av1 task to be skipped by worker without libsvtav1, got %+v", dequeued)
+	}
+
+	dequeued, err = repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, &model.WorkerCapabilities{Encoders: []string{"libx265", "libsvtav1", "libopus"}})
+	if err != nil {
+		t.Fatalf("DequeueEncodeJob failed: %v", err)
+	}
+	if dequeued == nil || dequeued.Id != jobID {
+		t.Fatalf("Expected av1 task, got %+v", dequeued)
+	}
+}
+
+func TestDequeueEncodeJobByLabels(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jobID := uuid.New()
+	db := repo.GetDB()
+	db.ExecContext(ctx, "INSERT INTO jobs (id, source_path, destination_path) VALUES ($1, '/test/big.mkv', '/test/big-out.mkv')", jobID.String())
+
+	task := &model.TaskEncode{
+		Id:          jobID,
+		DownloadURL: "http://example.com/big.mkv",
+		UploadURL:   "http://example.com/upload",
+		ChecksumURL: "http://example.com/checksum",
+		EventID:     1,
+		Labels:      map[string]string{"pool": "big"},
+	}
+	if err := repo.EnqueueEncodeJob(ctx, task); err != nil {
+		t.Fatalf("EnqueueEncodeJob failed: %v", err)
+	}
+
+	for _, capabilities := range []*model.WorkerCapabilities{nil, {Labels: map[string]string{"pool": "nas"}}} {
+		dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, capabilities)
+		if err != nil {
+			t.Fatalf("DequeueEncodeJob failed: %v", err)
+		}
+		if dequeued != nil {
+			t.Fatalf("Expected routed task to be skipped by worker %+v, got %+v", capabilities, dequeued)
+		}
+	}
+
+	dequeued, err := repo.DequeueEncodeJob(ctx, "test-worker", model.EncodeJobTypes, &model.WorkerCapabilities{Labels: map[string]string{"pool": "big", "gpu": "none"}})
+	if err != nil {
+		t.Fatalf("DequeueEncodeJob failed: %v", err)
+	}
+	if dequeued == nil || dequeued.Id != jobID {
+		t.Fatalf("Expected routed task, got %+v", dequeued)
+	}
+}
+
+func TestQualityEventUpdatesJob(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jobID := uuid.New()
+	job := &model.Job{
+		Id:              jobID,
+		SourcePath:      "/test/source.mkv",
+		DestinationPath: "/test/dest.mkv",
+	}
+	if err := repo.AddJob(ctx, job); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	result := model.QualityResult{Metric: model.QualityMetricVMAF, Score: 95.5, CRF: 21, Passed: true}
+	events := []*model.TaskEvent{
+		{Id: jobID, EventID: 0, EventType: model.NotificationEvent, NotificationType: model.JobNotification, Status: model.QueuedNotificationStatus},
+		{Id: jobID, EventID: 1, EventType: model.NotificationEvent, NotificationType: model.QualityNotification, Status: model.ProgressingNotificationStatus},
+		{Id: jobID, EventID: 2, EventType: model.NotificationEvent, NotificationType: model.QualityNotification, Status: model.CompletedNotificationStatus, Message: result.ToJson()},
+	}
+	for _, event := range events {
+		if err := repo.AddNewTaskEvent(ctx, event); err != nil {
+			t.Fatalf("AddNewTaskEvent failed: %v", err)
+		}
+	}
+
+	updatedJob, err := repo.GetJob(ctx, jobID.String())
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if updatedJob.QualityMetric != model.QualityMetricVMAF || updatedJob.QualityScore == nil || *updatedJob.QualityScore != 95.5 {
+		t.Errorf("Quality mismatch: got %s %v, want vmaf 95.5", updatedJob.QualityMetric, updatedJob.QualityScore)
+	}
+}
+
+func TestSampleJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	jobID := uuid.New()
+	job := &model.Job{
+		Id:              jobID,
+		SourcePath:      "/test/source.mkv",
+		DestinationPath: "/test/dest.mkv",
+		Sample:          &model.Sample{Clips: 3, ClipSeconds: 20},
+	}
+	if err := repo.AddJob(ctx, job); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	queued := job.AddEvent(model.NotificationEvent, model.JobNotification, model.QueuedNotificationStatus)
+	if err := repo.AddNewTaskEvent(ctx, queued); err != nil {
+		t.Fatalf("AddNewTaskEvent failed: %v", err)
+	}
+
+	sampleJob, err := repo.GetJob(ctx, jobID.String())
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if sampleJob.Sample == nil || *sampleJob.Sample != *job.Sample {
+		t.Errorf("Sample mismatch: got %+v, want %+v", sampleJob.Sample, job.Sample)
+	}
+
+	byPath, err := repo.GetJobByPath(ctx, job.SourcePath)
+	if err != nil {
+		t.Fatalf("GetJobByPath failed: %v", err)
+	}
+	if byPath != nil {
+		t.Errorf("Expected sample job to be ignored by GetJobByPath, got %+v", byPath)
+	}
+
+	expired, err := repo.GetExpiredSampleJobs(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("GetExpiredSampleJobs failed: %v", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("Expected no expired sample jobs, got %d", len(expired))
+	}
+	expired, err = repo.GetExpiredSampleJobs(ctx, -time.Hour)
+	if err != nil {
+		t.Fatalf("GetExpiredSampleJobs failed: %v", err)
+	}
+	if len(expired) != 1 || expired[0].Id != jobID {
+		t.Errorf("Expected sample job %s to be expired, got %+v", jobID, expired)
+	}
+}
+
+func TestScannedFileKeptOriginal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	scan := &model.LibraryScan{Id: uuid.New().String(), StartedAt: time.Now(), Status: model.ScanRunning}
+	if err := repo.CreateScan(ctx, scan); err != nil {
+		t.Fatalf("CreateScan failed: %v", err)
+	}
+	file := &model.ScannedFile{
+		Id:            uuid.New().String(),
+		FilePath:      "/test/source.mkv",
+		FileSize:      1000,
+		Codec:         "h264",
+		LastScannedAt: time.Now(),
+		Queued:        true,
+		ScanId:        scan.Id,
+	}
+	if err := repo.UpsertScannedFile(ctx, file); err != nil {
+		t.Fatalf("UpsertScannedFile failed: %v", err)
+	}
+
+	if err := repo.SetScannedFileKeptOriginal(ctx, file.FilePath); err != nil {
+		t.Fatalf("SetScannedFileKeptOriginal failed: %v", err)
+	}
+	if err := repo.UpsertScannedFile(ctx, file); err != nil {
+		t.Fatalf("UpsertScannedFile failed: %v", err)
+	}
+
+	scanned, err := repo.GetScannedFile(ctx, file.FilePath)
+	if err != nil {
+		t.Fatalf("GetScannedFile failed: %v", err)
+	}
+	if !scanned.KeptOriginal {
+		t.Error("Expected scanned file to stay kept original after being scanned again")
+	}
+}
@@ -0,0 +1,7 @@
+-- Add encoding profile support
+-- jobs.profile keeps the profile name chosen when the job was scheduled
+-- encode_queue.profile carries the resolved profile settings to the worker
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS profile VARCHAR(100) NOT NULL DEFAULT 'default';
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS profile JSONB;
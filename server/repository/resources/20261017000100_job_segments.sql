@@ -0,0 +1,12 @@
+-- Add chunked encoding support
+-- A chunked job owns one child job per segment, encoded by any free worker
+-- encode_queue.job_type lets workers dequeue only the task kinds they accept
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS parent_id varchar(255) REFERENCES jobs(id) ON DELETE CASCADE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS segment JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_parent_id ON jobs (parent_id) WHERE parent_id IS NOT NULL;
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS job_type varchar(20) NOT NULL DEFAULT 'encode';
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS segment JSONB;
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS segment_urls JSONB;
@@ -0,0 +1,5 @@
+-- Add perceptual quality score to jobs
+-- Filled from the Quality events sent by workers after each encode
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS quality_metric varchar(10);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS quality_score double precision;
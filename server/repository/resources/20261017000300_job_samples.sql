@@ -0,0 +1,7 @@
+-- Add sample jobs
+-- A sample job encodes a few short clips of the source, one child job per clip,
+-- stored apart from the upload path and removed after a retention period
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS sample JSONB;
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS sample boolean NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+-- Add kept original files
+-- Sources whose encode was larger are kept as they are and never queued again
+-- by the library scanner
+
+ALTER TABLE scanned_files ADD COLUMN IF NOT EXISTS kept_original boolean NOT NULL DEFAULT false;
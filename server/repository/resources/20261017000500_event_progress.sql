@@ -0,0 +1,34 @@
+-- Add structured encode progress to events
+-- FFMPEG progressing events carry frame, fps, bitrate, output size, speed and
+-- ETA read from the ffmpeg -progress output instead of a progress message
+
+ALTER TABLE task_event_queue ADD COLUMN IF NOT EXISTS progress JSONB;
+
+ALTER TABLE job_events ADD COLUMN IF NOT EXISTS progress JSONB;
+
+ALTER TABLE job_status ADD COLUMN IF NOT EXISTS progress JSONB;
+
+-- The progress of FFMPEG events is kept next to the latest status. It is a
+-- trigger of its own, database.sql replaces fn_trigger_job_status_update on
+-- every start, and it is named to run after event_insert_job_status_update.
+CREATE
+OR REPLACE FUNCTION fn_trigger_job_status_progress() RETURNS TRIGGER SECURITY DEFINER LANGUAGE plpgsql AS $$ BEGIN
+UPDATE
+    job_status
+SET
+    progress = NEW.progress
+WHERE
+    job_id = NEW.job_id;
+
+RETURN NEW;
+
+END;
+
+$$;
+
+DROP TRIGGER IF EXISTS event_insert_job_status_update_progress ON job_events;
+
+CREATE TRIGGER event_insert_job_status_update_progress
+AFTER
+INSERT
+    ON job_events FOR EACH ROW EXECUTE PROCEDURE fn_trigger_job_status_progress();
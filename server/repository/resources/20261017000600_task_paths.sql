@@ -0,0 +1,6 @@
+-- Add the server paths of the source and the destination to encode tasks
+-- Workers mounting the same storage read and write the files directly through
+-- their path mappings instead of the download and upload endpoints
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS source_path text NOT NULL DEFAULT '';
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS destination_path text NOT NULL DEFAULT '';
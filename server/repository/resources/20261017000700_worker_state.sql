@@ -0,0 +1,6 @@
+-- Add the state of the workers, reported with their pings
+-- Workers are drained, paused and resumed from the API, the state shows whether
+-- they take new jobs
+
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS state varchar(50) NOT NULL DEFAULT 'active';
+ALTER TABLE task_event_queue ADD COLUMN IF NOT EXISTS worker_state varchar(50) NOT NULL DEFAULT '';
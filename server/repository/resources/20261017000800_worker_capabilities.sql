@@ -0,0 +1,9 @@
+-- Add the capabilities of the workers, reported with their pings
+-- Workers register their version, jobs, encoders, free temporal space and
+-- labels, encode jobs are only dispatched to workers having the encoders of
+-- their profile
+
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS capabilities JSONB;
+ALTER TABLE task_event_queue ADD COLUMN IF NOT EXISTS capabilities JSONB;
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS required_encoders text[] NOT NULL DEFAULT '{}';
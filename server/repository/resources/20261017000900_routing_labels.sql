@@ -0,0 +1,5 @@
+-- Add the labels routing encode jobs to worker pools
+-- Jobs matching a routing rule of the scheduler are only dequeued by workers
+-- having all its labels
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS required_labels JSONB NOT NULL DEFAULT '{}';
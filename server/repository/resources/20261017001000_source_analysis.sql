@@ -0,0 +1,5 @@
+-- Add the source analysis sent with the segments of chunked and sample jobs
+-- The server detects crop and interlacing once on the whole source, segments
+-- only get their own cut of it
+
+ALTER TABLE encode_queue ADD COLUMN IF NOT EXISTS analysis JSONB;
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"gearr/helper"
+	"gearr/helper/codec"
 	"gearr/model"
 	"gearr/server/repository"
 	"io/fs"
@@ -31,6 +32,7 @@ type Scanner struct {
 
 type Scheduler interface {
 	ScheduleJobRequest(ctx context.Context, jobRequest *model.JobRequest) (*model.Job, error)
+	GetTargetCodec() codec.Target
 }
 
 func NewScanner(config model.ScannerConfig, repo repository.Repository, scheduler Scheduler) *Scanner {
@@ -202,6 +204,14 @@ func (s *Scanner) scanDirectory(ctx context.Context, scan *model.LibraryScan, ro
 			return nil
 		}
 
+		if existingFile != nil && existingFile.KeptOriginal {
+			s.mu.Lock()
+			scan.FilesSkippedExist++
+			s.mu.Unlock()
+			helper.Debugf("skipping file kept original: %s", path)
+			return nil
+		}
+
 		if existingFile != nil && existingFile.Queued {
 			s.mu.Lock()
 			scan.FilesSkippedExist++
@@ -223,17 +233,17 @@ func (s *Scanner) scanDirectory(ctx context.Context, scan *model.LibraryScan, ro
 			return nil
 		}
 
-		codec, err := helper.DetectCodec(path)
+		videoCodec, err := helper.DetectCodec(path)
 		if err != nil {
 			helper.Warnf("failed to detect codec for %s: %v", path, err)
 			return nil
 		}
 
-		if codec == "hevc" || codec == "x265" {
+		if target := s.scheduler.GetTargetCodec(); target.IsTargetCodec(videoCodec) {
 			s.mu.Lock()
 			scan.FilesSkippedCodec++
 			s.mu.Unlock()
-			helper.Debugf("skipping already x265/hevc file: %s", path)
+			helper.Debugf("skipping file already in %s: %s", target.Name, path)
 			return nil
 		}
 
@@ -241,7 +251,7 @@ func (s *Scanner) scanDirectory(ctx context.Context, scan *model.LibraryScan, ro
 			Id:            uuid.New().String(),
 			FilePath:      path,
 			FileSize:      fileInfo.Size(),
-			Codec:         codec,
+			Codec:         videoCodec,
 			LastScannedAt: time.Now(),
 			Queued:        false,
 			ScanId:        scan.Id,
@@ -273,7 +283,7 @@ func (s *Scanner) scanDirectory(ctx context.Context, scan *model.LibraryScan, ro
 		scan.FilesQueued++
 		s.mu.Unlock()
 
-		helper.Infof("queued file for transcoding: %s (codec: %s, size: %d)", path, codec, fileInfo.Size())
+		helper.Infof("queued file for transcoding: %s (codec: %s, size: %d)", path, videoCodec, fileInfo.Size())
 		return nil
 	})
 
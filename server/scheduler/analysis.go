@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gearr/helper"
+	"gearr/helper/codec"
+	"gearr/helper/detect"
+	"gearr/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// analyzeSource detects the black bars and the interlacing of the source of a
+// chunked or sample job once. Its segments only get their own cut of the
+// source and encode it the same way.
+func (R *RuntimeScheduler) analyzeSource(ctx context.Context, sourcePath string, profile *model.EncodingProfile) (*model.SourceAnalysis, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	filePath := filepath.Join(R.config.DownloadPath, sourcePath)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ffprobe.ProbeURL(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe file %s: %w", filePath, err)
+	}
+	video := data.FirstVideoStream()
+	if video == nil {
+		return nil, errors.New("source has no video stream")
+	}
+	duration := data.Format.DurationSeconds
+	analysis := &model.SourceAnalysis{Size: info.Size(), Duration: duration}
+
+	if profile.Deinterlacer != model.DeinterlacerNone && codec.MayBeInterlaced(video.CodecName, video.FieldOrder) {
+		var result detect.IdetResult
+		for _, start := range detect.IdetSamples(duration) {
+			output, err := runAnalysis(ctx, detect.IdetArguments(filePath, video.Index, start))
+			if err != nil {
+				return nil, err
+			}
+			window, err := detect.ParseIdet(output)
+			if err != nil {
+				return nil, err
+			}
+			result.Add(window)
+		}
+		if result.Interlaced() {
+			analysis.Deinterlace = detect.DeinterlaceFilter(profile.Deinterlacer, result.Parity())
+		}
+	}
+
+	if !profile.DisableCrop {
+		var crops []*detect.Crop
+		for _, start := range detect.CropSamples(duration) {
+			output, err := runAnalysis(ctx, detect.CropDetectArguments(filePath, video.Index, start))
+			if err != nil {
+				return nil, err
+			}
+			if crop := detect.ParseCrop(output); crop != nil {
+				crops = append(crops, crop)
+			}
+		}
+		if crop := detect.MergeCrops(crops, video.Width, video.Height); crop != nil {
+			analysis.Crop = crop.Filter()
+		}
+	}
+	helper.Infof("%s analysed, crop: %q, deinterlace: %q", sourcePath, analysis.Crop, analysis.Deinterlace)
+	return analysis, nil
+}
+
+// runAnalysis runs an ffmpeg analysis pass, the filters log to stderr.
+func runAnalysis(ctx context.Context, arguments []string) (string, error) {
+	output, err := exec.CommandContext(ctx, "ffmpeg", arguments...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg analysis failed: %w: %s", err, output)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+)
+
+// CancelJob stops a queued or running job. Queued tasks are removed from the
+// queue and canceled right away, running ones get a cancel action on the
+// queue of the worker holding them, which reports them canceled. Chunked jobs
+// cancel their segments too.
+func (R *RuntimeScheduler) CancelJob(ctx context.Context, uuid string) error {
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if !isCancelable(job) {
+		return fmt.Errorf("%w: job is in status %s", ErrorInvalidStatus, job.Events.GetStatus())
+	}
+	segments, err := R.getSegmentsWithEvents(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if !isCancelable(segment) {
+			continue
+		}
+		if err := R.cancelTask(ctx, segment, false); err != nil {
+			helper.Errorf("error canceling segment %s of job %s: %s", segment.Id.String(), uuid, err)
+		}
+	}
+	return R.cancelTask(ctx, job, len(segments) > 0)
+}
+
+// getSegmentsWithEvents returns the segments of a chunked job with their
+// events, which GetJobSegments does not load.
+func (R *RuntimeScheduler) getSegmentsWithEvents(ctx context.Context, uuid string) ([]*model.Job, error) {
+	segments, err := R.repo.GetJobSegments(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	for i, segment := range segments {
+		if segments[i], err = R.repo.GetJob(ctx, segment.Id.String()); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+func isQueued(job *model.Job) bool {
+	status := job.Events.GetStatus()
+	return status == model.QueuedNotificationStatus || status == model.ReQueuedNotificationStatus
+}
+
+func isCancelable(job *model.Job) bool {
+	status := job.Events.GetStatus()
+	return isQueued(job) || status == model.ProgressingNotificationStatus || status == model.PausedNotificationStatus
+}
+
+// cancelTask cancels a single job, through its worker when one is running it.
+// Chunked jobs have no task of their own until their join is queued.
+func (R *RuntimeScheduler) cancelTask(ctx context.Context, job *model.Job, chunked bool) error {
+	deleted, err := R.repo.DeletePendingEncodeJob(ctx, job.Id.String())
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return R.addJobEvent(ctx, job, model.JobNotification, model.CanceledNotificationStatus, "")
+	}
+
+	workerName := job.Events.GetWorkerName()
+	hasTask := !chunked || job.Events.GetLatestPerNotificationType(model.JoinNotification) != nil
+	if workerName == "" && hasTask {
+		// a worker took the task after the job was loaded
+		if job, err = R.repo.GetJob(ctx, job.Id.String()); err != nil {
+			return err
+		}
+		if workerName = job.Events.GetWorkerName(); workerName == "" {
+			return fmt.Errorf("%w: job %s is being assigned to a worker, retry the cancel", ErrorInvalidStatus, job.Id.String())
+		}
+	}
+	// chunked jobs run on the workers of their segments until the join
+	if workerName == "" {
+		return R.addJobEvent(ctx, job, model.JobNotification, model.CanceledNotificationStatus, "")
+	}
+	return R.publishJobAction(ctx, job, model.CancelJobAction)
+}
+
+// publishJobAction sends an action to the queue of the worker running the job.
+func (R *RuntimeScheduler) publishJobAction(ctx context.Context, job *model.Job, action model.JobAction) error {
+	workerName := job.Events.GetWorkerName()
+	worker, err := R.repo.GetWorker(ctx, workerName)
+	if err != nil {
+		return err
+	}
+	helper.Infof("sending %s of job %s to worker %s", action, job.Id.String(), workerName)
+	R.queue.PublishJobEvent(&model.JobEvent{Id: job.Id, Action: action}, worker.QueueName)
+	return nil
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"context"
+	"gearr/model"
+	"gearr/server/repository"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// cancelRepository keeps the jobs and the pending tasks the cancel path uses
+// in memory.
+type cancelRepository struct {
+	repository.Repository
+	jobs     map[string]*model.Job
+	segments map[string][]*model.Job
+	pending  map[string]bool
+}
+
+func (r *cancelRepository) GetJob(_ context.Context, id string) (*model.Job, error) {
+	job, found := r.jobs[id]
+	if !found {
+		return nil, ErrorJobNotFound
+	}
+	copied := *job
+	copied.Events = append(model.TaskEvents(nil), job.Events...)
+	return &copied, nil
+}
+
+func (r *cancelRepository) GetJobSegments(_ context.Context, parentID string) ([]*model.Job, error) {
+	return r.segments[parentID], nil
+}
+
+func (r *cancelRepository) DeletePendingEncodeJob(_ context.Context, jobID string) (bool, error) {
+	deleted := r.pending[jobID]
+	delete(r.pending, jobID)
+	return deleted, nil
+}
+
+func (r *cancelRepository) AddNewTaskEvent(_ context.Context, event *model.TaskEvent) error {
+	job := r.jobs[event.Id.String()]
+	job.Events = append(job.Events, event)
+	return nil
+}
+
+func (r *cancelRepository) addJob(parent *model.Job, pending bool) *model.Job {
+	job := &model.Job{Id: uuid.New()}
+	job.AddEvent(model.NotificationEvent, model.JobNotification, model.QueuedNotificationStatus)
+	r.jobs[job.Id.String()] = job
+	r.pending[job.Id.String()] = pending
+	if parent != nil {
+		job.ParentId = &parent.Id
+		r.segments[parent.Id.String()] = append(r.segments[parent.Id.String()], job)
+	}
+	return job
+}
+
+func TestCancelJob_QueuedChunkedJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		joinQueued    bool
+		segmentStatus model.NotificationStatus
+	}{
+		{"segments queued", false, model.CanceledNotificationStatus},
+		{"join queued", true, model.CompletedNotificationStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &cancelRepository{jobs: map[string]*model.Job{}, segments: map[string][]*model.Job{}, pending: map[string]bool{}}
+			parent := repo.addJob(nil, tt.joinQueued)
+			if tt.joinQueued {
+				parent.AddEvent(model.NotificationEvent, model.JoinNotification, model.QueuedNotificationStatus)
+			}
+			segments := []*model.Job{repo.addJob(parent, !tt.joinQueued), repo.addJob(parent, !tt.joinQueued)}
+			if tt.joinQueued {
+				for _, segment := range segments {
+					segment.AddEvent(model.NotificationEvent, model.JobNotification, model.CompletedNotificationStatus)
+				}
+			}
+			rs := &RuntimeScheduler{repo: repo}
+
+			if err := rs.CancelJob(context.Background(), parent.Id.String()); err != nil {
+				t.Fatalf("CancelJob() error = %v", err)
+			}
+			if status := repo.jobs[parent.Id.String()].Events.GetStatus(); status != model.CanceledNotificationStatus {
+				t.Errorf("job status = %s, want %s", status, model.CanceledNotificationStatus)
+			}
+			for _, segment := range segments {
+				if status := repo.jobs[segment.Id.String()].Events.GetStatus(); status != tt.segmentStatus {
+					t.Errorf("segment status = %s, want %s", status, tt.segmentStatus)
+				}
+			}
+			for id, pending := range repo.pending {
+				if pending {
+					t.Errorf("task of job %s still queued", id)
+				}
+			}
+		})
+	}
+}
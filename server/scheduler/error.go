@@ -9,4 +9,7 @@ var (
 	ErrorStreamNotAllowed = errors.New("upload not allowed")
 	ErrorInvalidStatus    = errors.New("job invalid status")
 	ErrorFileSkipped      = errors.New("path skipped")
+	ErrorUploadOffset     = errors.New("upload offset mismatch")
+	ErrorUploadChecksum   = errors.New("upload checksum mismatch")
+	ErrorSourcePending    = errors.New("source not ready yet")
 )
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// logsDir keeps the ffmpeg, mkvextract and PGS conversion logs uploaded by
+// the workers, one directory per job under the upload path.
+const logsDir = ".logs"
+
+func (R *RuntimeScheduler) jobLogsPath(jobID string) string {
+	return filepath.Join(R.config.UploadPath, logsDir, jobID)
+}
+
+// validLogName rejects paths out of the logs directory and the .upload files
+// of uploads in progress.
+func validLogName(name string) bool {
+	return sidecarNameRegex.MatchString(name) && !strings.Contains(name, "..") && !strings.HasSuffix(name, ".upload")
+}
+
+// GetUploadLogWriter returns the writer of a log of the job, uploaded by the
+// worker before reporting the job result.
+func (R *RuntimeScheduler) GetUploadLogWriter(ctx context.Context, uuid string, name string) (*UploadJobStream, error) {
+	if !validLogName(name) {
+		return nil, fmt.Errorf("%w: invalid log name %s", ErrorStreamNotAllowed, name)
+	}
+	job, err := R.isValidStremeableJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return newUploadJobStream(job, filepath.Join(R.jobLogsPath(job.Id.String()), name))
+}
+
+// GetJobLogs lists the logs uploaded for a job, sorted by name.
+func (R *RuntimeScheduler) GetJobLogs(ctx context.Context, uuid string) ([]model.JobLog, error) {
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(R.jobLogsPath(job.Id.String()))
+	if os.IsNotExist(err) {
+		return []model.JobLog{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	logs := []model.JobLog{}
+	for _, entry := range entries {
+		if entry.IsDir() || !validLogName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, model.JobLog{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].Name < logs[j].Name
+	})
+	return logs, nil
+}
+
+// GetJobLogPath returns the file of a log of the job.
+func (R *RuntimeScheduler) GetJobLogPath(ctx context.Context, uuid string, name string) (string, error) {
+	if !validLogName(name) {
+		return "", fmt.Errorf("%w: invalid log name %s", ErrorStreamNotAllowed, name)
+	}
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return "", err
+	}
+	logPath := filepath.Join(R.jobLogsPath(job.Id.String()), name)
+	if _, err := os.Stat(logPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: log %s of job %s", ErrorJobNotFound, name, uuid)
+	} else if err != nil {
+		return "", err
+	}
+	return logPath, nil
+}
+
+// removeJobLogs deletes the logs of a job and of its segments.
+func (R *RuntimeScheduler) removeJobLogs(ctx context.Context, job *model.Job) {
+	jobIDs := []string{job.Id.String()}
+	segments, err := R.repo.GetJobSegments(ctx, job.Id.String())
+	if err != nil {
+		helper.Error(err)
+	}
+	for _, segment := range segments {
+		jobIDs = append(jobIDs, segment.Id.String())
+	}
+	for _, jobID := range jobIDs {
+		if err := os.RemoveAll(R.jobLogsPath(jobID)); err != nil {
+			helper.Error(err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gearr/model"
+)
+
+// PauseJob holds a running job on its worker, which suspends its commands and
+// transfers and reports it paused. Chunked jobs pause their running segments.
+func (R *RuntimeScheduler) PauseJob(ctx context.Context, uuid string) error {
+	return R.sendRunningJobAction(ctx, uuid, model.PauseJobAction, model.ProgressingNotificationStatus)
+}
+
+// ResumeJob continues a job held by PauseJob where it stopped.
+func (R *RuntimeScheduler) ResumeJob(ctx context.Context, uuid string) error {
+	return R.sendRunningJobAction(ctx, uuid, model.ResumeJobAction, model.PausedNotificationStatus)
+}
+
+// sendRunningJobAction sends the action to the worker of the job, or to the
+// workers of the segments of a chunked job, when they are in the given status.
+func (R *RuntimeScheduler) sendRunningJobAction(ctx context.Context, uuid string, action model.JobAction, status model.NotificationStatus) error {
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if job.Events.GetWorkerName() != "" {
+		if job.Events.GetStatus() != status {
+			return fmt.Errorf("%w: job is in status %s", ErrorInvalidStatus, job.Events.GetStatus())
+		}
+		return R.publishJobAction(ctx, job, action)
+	}
+
+	// chunked jobs stay progressing while their segments run
+	if job.Events.GetStatus() != model.ProgressingNotificationStatus {
+		return fmt.Errorf("%w: job is in status %s", ErrorInvalidStatus, job.Events.GetStatus())
+	}
+	segments, err := R.getSegmentsWithEvents(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	sent := false
+	for _, segment := range segments {
+		if segment.Events.GetStatus() != status || segment.Events.GetWorkerName() == "" {
+			continue
+		}
+		if err := R.publishJobAction(ctx, segment, action); err != nil {
+			return err
+		}
+		sent = true
+	}
+	if !sent {
+		return fmt.Errorf("%w: job has no segment in status %s", ErrorInvalidStatus, status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// RoutingRule sends the jobs it matches only to the workers having all its
+// labels. Every condition set must match: sources of at least MinSizeMB,
+// source paths matching the Pattern regular expression and jobs encoded with
+// the Profile. A rule without conditions matches every job.
+type RoutingRule struct {
+	MinSizeMB int64             `mapstructure:"minSizeMB"`
+	Pattern   string            `mapstructure:"pattern"`
+	Profile   string            `mapstructure:"profile"`
+	Labels    map[string]string `mapstructure:"labels"`
+	pattern   *regexp.Regexp
+}
+
+// RoutingRules are checked in order, the first matching rule routes the job.
+// Jobs matching no rule are taken by any worker.
+type RoutingRules []RoutingRule
+
+// Validate checks the rules and compiles their patterns, rules are only
+// matched once validated.
+func (r RoutingRules) Validate(profiles model.EncodingProfiles) error {
+	for i := range r {
+		rule := &r[i]
+		if rule.MinSizeMB < 0 {
+			return &model.CustomError{Message: fmt.Sprintf("routing rule %d has negative minimum size %d", i, rule.MinSizeMB)}
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return &model.CustomError{Message: fmt.Sprintf("routing rule %d has invalid pattern %s", i, rule.Pattern), Cause: err}
+			}
+			rule.pattern = pattern
+		}
+		if rule.Profile != "" {
+			if _, err := profiles.Get(rule.Profile); err != nil {
+				return &model.CustomError{Message: fmt.Sprintf("routing rule %d has unknown profile %s", i, rule.Profile), Cause: err}
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether a source of the given size in bytes, encoded with
+// the profile, is routed by the rule.
+func (r RoutingRule) matches(sourcePath string, size int64, profile string) bool {
+	if r.MinSizeMB > 0 && size < r.MinSizeMB*1024*1024 {
+		return false
+	}
+	// the pattern is compiled by Validate
+	if r.pattern != nil && !r.pattern.MatchString(sourcePath) {
+		return false
+	}
+	return r.Profile == "" || r.Profile == profile
+}
+
+// labels returns the labels of the first rule matching the job, none when no
+// rule matches.
+func (r RoutingRules) labels(sourcePath string, size int64, profile string) map[string]string {
+	for _, rule := range r {
+		if rule.matches(sourcePath, size, profile) {
+			return rule.Labels
+		}
+	}
+	return nil
+}
+
+// routingLabels returns the labels a worker needs to take the tasks of the job,
+// the segments and the join of a chunked job go to the same workers.
+func (R *RuntimeScheduler) routingLabels(job *model.Job, profile *model.EncodingProfile) map[string]string {
+	if len(R.config.Routing) == 0 {
+		return nil
+	}
+	var size int64
+	info, err := os.Stat(filepath.Join(R.config.DownloadPath, job.SourcePath))
+	if err != nil {
+		helper.Warnf("failed to get size of %s for routing: %v", job.SourcePath, err)
+	} else {
+		size = info.Size()
+	}
+	return R.config.Routing.labels(job.SourcePath, size, profile.Name)
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"gearr/model"
+	"reflect"
+	"testing"
+)
+
+func TestRoutingRules_Labels(t *testing.T) {
+	rules := RoutingRules{
+		{MinSizeMB: 20 * 1024, Labels: map[string]string{"pool": "big"}},
+		{Pattern: `(?i)/anime/`, Labels: map[string]string{"pool": "anime"}},
+		{Profile: "archival", Pattern: `^movies/`, Labels: map[string]string{"pool": "big", "gpu": "none"}},
+	}
+	if err := rules.Validate(model.EncodingProfiles{{Name: "archival", VideoCodec: "libx265", AudioCodec: "aac"}}); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		sourcePath string
+		size       int64
+		profile    string
+		expected   map[string]string
+	}{
+		{"large source", "tv/show/episode.mkv", 25 << 30, "default", map[string]string{"pool": "big"}},
+		{"first matching rule wins", "tv/Anime/episode.mkv", 25 << 30, "default", map[string]string{"pool": "big"}},
+		{"path pattern", "tv/Anime/episode.mkv", 2 << 30, "default", map[string]string{"pool": "anime"}},
+		{"profile and pattern", "movies/film.mkv", 2 << 30, "archival", map[string]string{"pool": "big", "gpu": "none"}},
+		{"profile without pattern", "tv/show/episode.mkv", 2 << 30, "archival", nil},
+		{"no matching rule", "tv/show/episode.mkv", 2 << 30, "default", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := rules.labels(tt.sourcePath, tt.size, tt.profile)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("labels() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRoutingRules_Validate(t *testing.T) {
+	profiles := model.EncodingProfiles{{Name: "anime", VideoCodec: "libx265", AudioCodec: "aac"}}
+
+	tests := []struct {
+		name    string
+		rules   RoutingRules
+		wantErr bool
+	}{
+		{"valid rules", RoutingRules{{MinSizeMB: 20480, Labels: map[string]string{"pool": "big"}}, {Profile: "anime", Labels: map[string]string{"pool": "anime"}}}, false},
+		{"default profile", RoutingRules{{Profile: model.DefaultEncodingProfileName}}, false},
+		{"negative size", RoutingRules{{MinSizeMB: -1}}, true},
+		{"invalid pattern", RoutingRules{{Pattern: `(anime`}}, true},
+		{"unknown profile", RoutingRules{{Profile: "mobile"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate(profiles)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const (
+	maxSampleClips       = 20
+	maxSampleClipSeconds = 300
+)
+
+// SampleConfig controls sample jobs, short clips encoded to compare profiles
+// before encoding a whole library. Clips are stored under Path instead of the
+// upload path and removed after Retention.
+type SampleConfig struct {
+	Path         string        `mapstructure:"path"`
+	Retention    time.Duration `mapstructure:"retention"`
+	Clips        int           `mapstructure:"clips"`
+	ClipDuration time.Duration `mapstructure:"clipDuration"`
+}
+
+// sampleRequest fills the zero values of a sample request with the configured
+// defaults and checks its limits.
+func (c SampleConfig) sampleRequest(sample *model.Sample) (*model.Sample, error) {
+	if c.Path == "" {
+		return nil, &model.CustomError{Message: "sample jobs are disabled, scheduler.samples.path is not set"}
+	}
+	request := *sample
+	if request.Clips == 0 {
+		request.Clips = c.Clips
+	}
+	if request.ClipSeconds == 0 {
+		request.ClipSeconds = int(c.ClipDuration.Seconds())
+	}
+	if request.Clips < 1 || request.Clips > maxSampleClips {
+		return nil, &model.CustomError{Message: fmt.Sprintf("sample clips must be between 1 and %d", maxSampleClips)}
+	}
+	if request.ClipSeconds < 1 || request.ClipSeconds > maxSampleClipSeconds {
+		return nil, &model.CustomError{Message: fmt.Sprintf("sample clip duration must be between 1 and %d seconds", maxSampleClipSeconds)}
+	}
+	return &request, nil
+}
+
+// planSamples returns the clips of a sample job, spread evenly over the source.
+func (R *RuntimeScheduler) planSamples(ctx context.Context, sourcePath string, sample *model.Sample) ([]model.Segment, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	filePath := filepath.Join(R.config.DownloadPath, sourcePath)
+	data, err := ffprobe.ProbeURL(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe file %s: %w", filePath, err)
+	}
+	return sampleClips(data.Format.DurationSeconds, sample.Clips, float64(sample.ClipSeconds)), nil
+}
+
+// sampleClips splits the source in clips evenly spaced, leaving out the
+// beginning and the end that usually are credits. Sources shorter than all the
+// clips together are sampled whole.
+func sampleClips(duration float64, clips int, clipDuration float64) []model.Segment {
+	if float64(clips)*clipDuration >= duration {
+		return []model.Segment{{Index: 0}}
+	}
+	segments := make([]model.Segment, clips)
+	for i := range segments {
+		start := (duration - clipDuration) * float64(i+1) / float64(clips+1)
+		segments[i] = model.Segment{Index: i, Start: start, End: start + clipDuration}
+	}
+	return segments
+}
+
+// sampleClipPath is where a sample clip is uploaded, relative to the samples
+// path, in the container of the profile.
+func sampleClipPath(parentID uuid.UUID, index int, container string) string {
+	return filepath.Join(parentID.String(), fmt.Sprintf("clip-%02d.%s", index, container))
+}
+
+// setSampleSizes fills the size of the clips of a sample job already uploaded.
+func (R *RuntimeScheduler) setSampleSizes(job *model.Job) {
+	for _, clip := range job.Segments {
+		fileInfo, err := os.Stat(filepath.Join(R.config.Samples.Path, clip.DestinationPath))
+		if err != nil {
+			continue
+		}
+		clip.Size = fileInfo.Size()
+	}
+}
+
+// removeExpiredSamples deletes the sample jobs, and their clips, older than
+// the retention period.
+func (R *RuntimeScheduler) removeExpiredSamples(ctx context.Context) {
+	if R.config.Samples.Path == "" || R.config.Samples.Retention <= 0 {
+		return
+	}
+	jobs, err := R.repo.GetExpiredSampleJobs(ctx, R.config.Samples.Retention)
+	if err != nil {
+		helper.Error(err)
+		return
+	}
+	for _, job := range jobs {
+		helper.Infof("sample job %s expired, removing its clips", job.Id.String())
+		if err := os.RemoveAll(filepath.Join(R.config.Samples.Path, job.Id.String())); err != nil {
+			helper.Error(err)
+			continue
+		}
+		R.removeJobLogs(ctx, job)
+		if err := R.repo.DeleteJob(ctx, job.Id.String()); err != nil {
+			helper.Error(err)
+		}
+	}
+}
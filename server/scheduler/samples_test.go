@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"gearr/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleClips(t *testing.T) {
+	tests := []struct {
+		name         string
+		duration     float64
+		clips        int
+		clipDuration float64
+		expected     []model.Segment
+	}{
+		{
+			name:         "clips spread over the source",
+			duration:     3020,
+			clips:        3,
+			clipDuration: 20,
+			expected: []model.Segment{
+				{Index: 0, Start: 750, End: 770},
+				{Index: 1, Start: 1500, End: 1520},
+				{Index: 2, Start: 2250, End: 2270},
+			},
+		},
+		{
+			name:         "short source sampled whole",
+			duration:     50,
+			clips:        3,
+			clipDuration: 20,
+			expected:     []model.Segment{{Index: 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sampleClips(tt.duration, tt.clips, tt.clipDuration)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("sampleClips() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSampleConfig_SampleRequest(t *testing.T) {
+	config := SampleConfig{Path: "/data/samples", Clips: 3, ClipDuration: 20 * time.Second}
+
+	tests := []struct {
+		name     string
+		config   SampleConfig
+		sample   model.Sample
+		expected *model.Sample
+		wantErr  bool
+	}{
+		{"defaults", config, model.Sample{}, &model.Sample{Clips: 3, ClipSeconds: 20}, false},
+		{"requested", config, model.Sample{Clips: 5, ClipSeconds: 10}, &model.Sample{Clips: 5, ClipSeconds: 10}, false},
+		{"too many clips", config, model.Sample{Clips: 50}, nil, true},
+		{"clip too long", config, model.Sample{ClipSeconds: 3600}, nil, true},
+		{"disabled", SampleConfig{}, model.Sample{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.config.sampleRequest(&tt.sample)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sampleRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("sampleRequest() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -2,15 +2,19 @@ package scheduler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"gearr/helper"
 	"gearr/helper/codec"
 	"gearr/model"
 	"gearr/server/queue"
 	"gearr/server/repository"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +26,8 @@ const (
 	notificationSendTimeout = 5 * time.Second
 )
 
+var sidecarNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
 type Scheduler interface {
 	Run(wg *sync.WaitGroup, ctx context.Context)
 	ScheduleJobRequest(ctx context.Context, jobRequest *model.JobRequest) (*model.Job, error)
@@ -29,14 +35,27 @@ type Scheduler interface {
 	DeleteJob(ctx context.Context, uuid string) error
 	GetJobs(ctx context.Context) (*[]model.Job, error)
 	GetUploadJobWriter(ctx context.Context, uuid string) (*UploadJobStream, error)
+	GetUploadSidecarWriter(ctx context.Context, uuid string, name string) (*UploadJobStream, error)
+	GetUploadSessionWriter(ctx context.Context, uuid string) (*UploadJobStream, error)
+	CancelJob(ctx context.Context, uuid string) error
+	PauseJob(ctx context.Context, uuid string) error
+	ResumeJob(ctx context.Context, uuid string) error
+	GetUploadLogWriter(ctx context.Context, uuid string, name string) (*UploadJobStream, error)
+	GetJobLogs(ctx context.Context, uuid string) ([]model.JobLog, error)
+	GetJobLogPath(ctx context.Context, uuid string, name string) (string, error)
 	GetDownloadJobWriter(ctx context.Context, uuid string) (*DownloadJobStream, error)
+	GetSegmentDownloadJobWriter(ctx context.Context, uuid string, index int) (*DownloadJobStream, error)
+	GetSampleDownloadJobWriter(ctx context.Context, uuid string, index int) (*DownloadJobStream, error)
 	GetChecksum(ctx context.Context, uuid string) (string, error)
 	GetWorkers(ctx context.Context) (*[]model.Worker, error)
+	SendWorkerAction(ctx context.Context, name string, action model.JobAction) error
 	GetUpdateJobsChan(ctx context.Context) (uuid.UUID, chan *model.JobUpdateNotification)
 	CloseUpdateJobsChan(id uuid.UUID)
 	UpdateJobPriority(ctx context.Context, uuid string, priority int) error
 	GetWebhookEvents(ctx context.Context, limit int, source, eventType, status string) ([]*model.WebhookEvent, error)
 	GetWebhookEvent(ctx context.Context, id int64) (*model.WebhookEvent, error)
+	GetProfiles(ctx context.Context) model.EncodingProfiles
+	GetTargetCodec() codec.Target
 }
 
 type SchedulerConfig struct {
@@ -48,6 +67,11 @@ type SchedulerConfig struct {
 	MinFileSize     int64 `mapstructure:"minFileSize"`
 	DefaultPriority int   `mapstructure:"defaultPriority"`
 	PriorityConfig  *model.PriorityConfig
+	Profiles        model.EncodingProfiles `mapstructure:"profiles"`
+	DefaultProfile  string                 `mapstructure:"defaultProfile"`
+	Chunking        ChunkingConfig         `mapstructure:"chunking"`
+	Samples         SampleConfig           `mapstructure:"samples"`
+	Routing         RoutingRules           `mapstructure:"routing"`
 }
 
 type RuntimeScheduler struct {
@@ -57,7 +81,13 @@ type RuntimeScheduler struct {
 	checksumChan       chan PathChecksum
 	updateJobsChannels map[uuid.UUID]*jobSubscription
 	jobChannelsMutex   sync.Mutex
+	checksumMutex      sync.Mutex
 	pathChecksumMap    map[string]string
+	// pendingChecksums are the sources being hashed for a checksum request
+	pendingChecksums map[string]bool
+	cutMutex         sync.Mutex
+	// pendingCuts are the segments being cut out of their source
+	pendingCuts map[string]bool
 }
 
 type jobSubscription struct {
@@ -66,6 +96,19 @@ type jobSubscription struct {
 }
 
 func NewScheduler(config SchedulerConfig, repo repository.Repository, queue queue.BrokerServer) (*RuntimeScheduler, error) {
+	if err := config.Profiles.Validate(); err != nil {
+		return nil, err
+	}
+	if config.DefaultProfile == "" {
+		config.DefaultProfile = model.DefaultEncodingProfileName
+	}
+	if _, err := config.Profiles.Get(config.DefaultProfile); err != nil {
+		return nil, err
+	}
+	if err := config.Routing.Validate(config.Profiles); err != nil {
+		return nil, err
+	}
+
 	runtimeScheduler := &RuntimeScheduler{
 		config:             config,
 		repo:               repo,
@@ -73,6 +116,8 @@ func NewScheduler(config SchedulerConfig, repo repository.Repository, queue queu
 		checksumChan:       make(chan PathChecksum),
 		updateJobsChannels: make(map[uuid.UUID]*jobSubscription, 0),
 		pathChecksumMap:    make(map[string]string),
+		pendingChecksums:   make(map[string]bool),
+		pendingCuts:        make(map[string]bool),
 	}
 
 	return runtimeScheduler, nil
@@ -166,12 +211,23 @@ func (R *RuntimeScheduler) schedule(ctx context.Context) {
 				return
 			}
 
+			if jobEvent.EventType == model.NotificationEvent {
+				isSegment, err := R.handleSegmentEvent(ctx, jobEvent)
+				if err != nil {
+					helper.Error(err)
+				}
+				if isSegment {
+					continue
+				}
+			}
+
 			if jobEvent.EventType != model.PingEvent {
 				jobUpdateNotification := model.JobUpdateNotification{
 					Id:          jobEvent.Id,
 					Status:      jobEvent.Status,
 					StatusPhase: jobEvent.NotificationType,
 					Message:     jobEvent.Message,
+					Progress:    jobEvent.Progress,
 					EventTime:   jobEvent.EventTime,
 				}
 				R.sendUpdateJobsNotification(&jobUpdateNotification)
@@ -183,6 +239,7 @@ func (R *RuntimeScheduler) schedule(ctx context.Context) {
 					helper.Error(err)
 					continue
 				}
+				R.removeSegments(job)
 				sourcePath := filepath.Join(R.config.DownloadPath, job.SourcePath)
 				target := filepath.Join(R.config.DownloadPath, job.DestinationPath)
 				if _, err := os.Stat(target); err != nil {
@@ -195,9 +252,13 @@ func (R *RuntimeScheduler) schedule(ctx context.Context) {
 					helper.Error(err)
 				}
 			}
+			if jobEvent.EventType == model.NotificationEvent && jobEvent.NotificationType == model.JobNotification && jobEvent.Status == model.KeptOriginalNotificationStatus {
+				R.keepOriginal(ctx, jobEvent)
+			}
 		case checksumPath := <-R.checksumChan:
-			R.pathChecksumMap[checksumPath.path] = checksumPath.checksum
+			R.setChecksum(checksumPath.path, checksumPath.checksum)
 		case <-time.After(R.config.ScheduleTime):
+			R.removeExpiredSamples(ctx)
 			timeoutJobs, err := R.repo.GetTimeoutJobs(ctx, R.config.JobTimeout)
 			if err != nil {
 				helper.Error(err)
@@ -208,6 +269,7 @@ func (R *RuntimeScheduler) schedule(ctx context.Context) {
 					jobRequest := &model.JobRequest{
 						SourcePath:      timeoutJob.SourcePath,
 						DestinationPath: timeoutJob.DestinationPath,
+						Profile:         timeoutJob.Profile,
 					}
 					_, err = R.scheduleJobRequest(ctx, jobRequest)
 					if err != nil {
@@ -220,14 +282,56 @@ func (R *RuntimeScheduler) schedule(ctx context.Context) {
 }
 
 func (R *RuntimeScheduler) scheduleJobRequest(ctx context.Context, jobRequest *model.JobRequest) (job *model.Job, err error) {
-	err = R.repo.WithTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
-		job, err = tx.GetJobByPath(ctx, jobRequest.SourcePath)
+	// duplicated requests are rejected before probing the source, the
+	// transaction checks it again
+	if jobRequest.Sample == nil {
+		job, err = R.repo.GetJobByPath(ctx, jobRequest.SourcePath)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		var eventsToAdd []*model.TaskEvent
 		if job != nil {
-			return fmt.Errorf("%w", model.ErrJobExists)
+			return nil, fmt.Errorf("%w", model.ErrJobExists)
+		}
+	}
+	profile, err := R.getProfile(jobRequest.Profile)
+	if err != nil {
+		return nil, err
+	}
+	var segments []model.Segment
+	if jobRequest.Sample != nil {
+		segments, err = R.planSamples(ctx, jobRequest.SourcePath, jobRequest.Sample)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		segments, err = R.planSegments(ctx, jobRequest.SourcePath)
+		if err != nil {
+			helper.Warnf("%s can not be split in segments, encoding it as a whole: %v", jobRequest.SourcePath, err)
+			segments = nil
+		}
+	}
+	var analysis *model.SourceAnalysis
+	if len(segments) > 0 {
+		analysis, err = R.analyzeSource(ctx, jobRequest.SourcePath, profile)
+		// sample clips can detect on their own, segments must be encoded alike
+		if err != nil && jobRequest.Sample == nil {
+			helper.Warnf("%s can not be analysed, encoding it as a whole: %v", jobRequest.SourcePath, err)
+			segments = nil
+		} else if err != nil {
+			helper.Warnf("%s can not be analysed, each clip is analysed on its own: %v", jobRequest.SourcePath, err)
+		}
+	}
+	err = R.repo.WithTransaction(ctx, func(ctx context.Context, tx repository.Repository) error {
+		var eventsToAdd []*model.TaskEvent
+		// samples do not produce the destination file, they can run next to the job
+		if jobRequest.Sample == nil {
+			job, err = tx.GetJobByPath(ctx, jobRequest.SourcePath)
+			if err != nil {
+				return err
+			}
+			if job != nil {
+				return fmt.Errorf("%w", model.ErrJobExists)
+			}
 		}
 		newUUID, _ := uuid.NewUUID()
 		priority := jobRequest.Priority
@@ -239,6 +343,8 @@ func (R *RuntimeScheduler) scheduleJobRequest(ctx context.Context, jobRequest *m
 			DestinationPath: jobRequest.DestinationPath,
 			Id:              newUUID,
 			Priority:        priority,
+			Profile:         profile.Name,
+			Sample:          jobRequest.Sample,
 		}
 		err = tx.AddJob(ctx, job)
 		if err != nil {
@@ -255,26 +361,257 @@ func (R *RuntimeScheduler) scheduleJobRequest(ctx context.Context, jobRequest *m
 			}
 		}
 
-		downloadURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/download", R.config.Domain.String(), job.Id.String()))
-		uploadURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/upload", R.config.Domain.String(), job.Id.String()))
-		checksumURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/checksum", R.config.Domain.String(), job.Id.String()))
-		latestEvent := job.Events.GetLatest()
-		if latestEvent == nil {
-			return fmt.Errorf("no events found for job %s", job.Id.String())
+		if len(segments) > 0 {
+			return R.scheduleSegments(ctx, tx, job, profile, segments, analysis)
 		}
-		task := &model.TaskEncode{
-			Id:          job.Id,
-			DownloadURL: downloadURL.String(),
-			UploadURL:   uploadURL.String(),
-			ChecksumURL: checksumURL.String(),
-			EventID:     latestEvent.EventID,
+		task, err := R.newTaskEncode(job, profile)
+		if err != nil {
+			return err
 		}
 		return R.queue.PublishJobRequest(task)
 	})
 	return job, err
 }
 
+func (R *RuntimeScheduler) newTaskEncode(job *model.Job, profile *model.EncodingProfile) (*model.TaskEncode, error) {
+	downloadURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/download", R.config.Domain.String(), job.Id.String()))
+	uploadURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/upload", R.config.Domain.String(), job.Id.String()))
+	checksumURL, _ := url.Parse(fmt.Sprintf("%s/api/v1/job/%s/checksum", R.config.Domain.String(), job.Id.String()))
+	latestEvent := job.Events.GetLatest()
+	if latestEvent == nil {
+		return nil, fmt.Errorf("no events found for job %s", job.Id.String())
+	}
+	return &model.TaskEncode{
+		Id:              job.Id,
+		DownloadURL:     downloadURL.String(),
+		UploadURL:       uploadURL.String(),
+		ChecksumURL:     checksumURL.String(),
+		EventID:         latestEvent.EventID,
+		Profile:         profile,
+		SourcePath:      R.sourceFile(job),
+		DestinationPath: filepath.Join(R.storagePath(job), job.DestinationPath),
+		Labels:          R.routingLabels(job, profile),
+	}, nil
+}
+
+// scheduleSegments adds a child job per segment of a chunked job, or per clip
+// of a sample job, and queues them, each one is encoded by any free worker
+// from its own cut of the source and uploaded to its own path.
+func (R *RuntimeScheduler) scheduleSegments(ctx context.Context, tx repository.Repository, job *model.Job, profile *model.EncodingProfile, segments []model.Segment, analysis *model.SourceAnalysis) error {
+	for _, segment := range segments {
+		newUUID, _ := uuid.NewUUID()
+		destinationPath := segmentPath(job.Id, segment.Index)
+		if job.Sample != nil {
+			destinationPath = sampleClipPath(job.Id, segment.Index, profile.OutputContainer())
+		}
+		segmentJob := &model.Job{
+			SourcePath:      job.SourcePath,
+			DestinationPath: destinationPath,
+			Id:              newUUID,
+			Priority:        job.Priority,
+			Profile:         job.Profile,
+			ParentId:        &job.Id,
+			Segment:         &segment,
+			Sample:          job.Sample,
+		}
+		if err := tx.AddJob(ctx, segmentJob); err != nil {
+			return err
+		}
+		queuedEvent := segmentJob.AddEvent(model.NotificationEvent, model.JobNotification, model.QueuedNotificationStatus)
+		if err := tx.AddNewTaskEvent(ctx, queuedEvent); err != nil {
+			return err
+		}
+		task, err := R.newTaskEncode(segmentJob, profile)
+		if err != nil {
+			return err
+		}
+		task.Segment = &segment
+		task.Sample = job.Sample != nil
+		task.Analysis = analysis
+		if err := R.queue.PublishJobRequest(task); err != nil {
+			return err
+		}
+		job.Segments = append(job.Segments, segmentJob)
+	}
+	return nil
+}
+
+// handleSegmentEvent rolls the events of a segment job up into its parent.
+// The parent is marked as progressing with the first segment, failed with the
+// first failed one, and queued for joining once every segment is completed.
+// Sample jobs are completed instead, their clips are not joined.
+// It reports whether the event belongs to a segment job.
+func (R *RuntimeScheduler) handleSegmentEvent(ctx context.Context, event *model.TaskEvent) (bool, error) {
+	segmentJob, err := R.repo.GetJob(ctx, event.Id.String())
+	if err != nil {
+		return false, err
+	}
+	if segmentJob.ParentId == nil {
+		return false, nil
+	}
+
+	if event.NotificationType != model.JobNotification && event.NotificationType != model.FFMPEGSNotification {
+		return true, nil
+	}
+	if event.NotificationType == model.JobNotification && (event.Status == model.CompletedNotificationStatus || event.Status == model.FailedNotificationStatus || event.Status == model.CanceledNotificationStatus) {
+		R.removeSegmentSource(segmentJob)
+	}
+
+	parent, err := R.repo.GetJob(ctx, segmentJob.ParentId.String())
+	if err != nil {
+		return true, err
+	}
+	parent.Segments, err = R.repo.GetJobSegments(ctx, parent.Id.String())
+	if err != nil {
+		return true, err
+	}
+	// the event may not be committed yet, its segment status comes from it
+	for _, segment := range parent.Segments {
+		if segment.Id == event.Id {
+			segment.Status = string(event.Status)
+			segment.StatusPhase = event.NotificationType
+			segment.StatusMessage = event.Message
+			segment.EncodeProgress = event.Progress
+		}
+	}
+
+	parentStatus := parent.Events.GetStatus()
+	if parentStatus != model.QueuedNotificationStatus && parentStatus != model.ProgressingNotificationStatus {
+		return true, nil
+	}
+	if parent.Events.GetLatestPerNotificationType(model.JoinNotification) != nil {
+		return true, nil
+	}
+
+	if event.NotificationType == model.JobNotification && (event.Status == model.FailedNotificationStatus || event.Status == model.CanceledNotificationStatus) {
+		message := fmt.Sprintf("segment %d %s", segmentJob.Segment.Index, event.Status)
+		if event.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, event.Message)
+		}
+		return true, R.addJobEvent(ctx, parent, model.JobNotification, model.FailedNotificationStatus, message)
+	}
+
+	if parentStatus == model.QueuedNotificationStatus {
+		if err := R.addJobEvent(ctx, parent, model.JobNotification, model.ProgressingNotificationStatus, ""); err != nil {
+			return true, err
+		}
+	}
+
+	if parent.SegmentsCompleted() {
+		if parent.Sample != nil {
+			R.removeSegments(parent)
+			return true, R.addJobEvent(ctx, parent, model.JobNotification, model.CompletedNotificationStatus, "")
+		}
+		return true, R.scheduleJoin(ctx, parent)
+	}
+
+	R.sendUpdateJobsNotification(&model.JobUpdateNotification{
+		Id:          parent.Id,
+		Status:      model.ProgressingNotificationStatus,
+		StatusPhase: model.FFMPEGSNotification,
+		Progress:    &model.EncodeProgress{Percent: parent.SegmentsProgress()},
+		EventTime:   event.EventTime,
+	})
+	return true, nil
+}
+
+// scheduleJoin queues the last task of a chunked job: a worker downloads the
+// source and the encoded segments, concatenates the video and muxes audio,
+// subtitles and metadata from the source, uploading it as a regular job.
+func (R *RuntimeScheduler) scheduleJoin(ctx context.Context, parent *model.Job) error {
+	profile, err := R.getProfile(parent.Profile)
+	if err != nil {
+		return err
+	}
+	if err := R.addJobEvent(ctx, parent, model.JoinNotification, model.QueuedNotificationStatus, ""); err != nil {
+		return err
+	}
+	task, err := R.newTaskEncode(parent, profile)
+	if err != nil {
+		return err
+	}
+	for _, segment := range parent.Segments {
+		task.SegmentURLs = append(task.SegmentURLs, fmt.Sprintf("%s/api/v1/job/%s/segment/%d", R.config.Domain.String(), parent.Id.String(), segment.Segment.Index))
+	}
+	helper.Infof("all segments of job %s encoded, queuing join", parent.Id.String())
+	return R.queue.PublishJobRequest(task)
+}
+
+// addJobEvent adds an event generated by the scheduler itself to the job and
+// notifies it.
+func (R *RuntimeScheduler) addJobEvent(ctx context.Context, job *model.Job, notificationType model.NotificationType, status model.NotificationStatus, message string) error {
+	event := job.AddEvent(model.NotificationEvent, notificationType, status)
+	event.Message = message
+	if err := R.repo.AddNewTaskEvent(ctx, event); err != nil {
+		return err
+	}
+	R.sendUpdateJobsNotification(&model.JobUpdateNotification{
+		Id:          job.Id,
+		Status:      status,
+		StatusPhase: notificationType,
+		Message:     message,
+		EventTime:   event.EventTime,
+	})
+	return nil
+}
+
+// removeSegments deletes the encoded segments and the cuts of the source of a
+// completed chunked or sample job.
+func (R *RuntimeScheduler) removeSegments(job *model.Job) {
+	segmentsPath := filepath.Join(R.config.UploadPath, segmentsDir, job.Id.String())
+	if _, err := os.Stat(segmentsPath); err != nil {
+		return
+	}
+	if err := os.RemoveAll(segmentsPath); err != nil {
+		helper.Error(err)
+	}
+}
+
+// keepOriginal records that the source of the job is kept as it is, so the
+// scanner and the watcher never queue it again.
+func (R *RuntimeScheduler) keepOriginal(ctx context.Context, jobEvent *model.TaskEvent) {
+	job, err := R.repo.GetJob(ctx, jobEvent.Id.String())
+	if err != nil {
+		helper.Error(err)
+		return
+	}
+	R.removeSegments(job)
+	helper.Infof("job %s kept the original file %s: %s", job.Id.String(), job.SourcePath, jobEvent.Message)
+
+	fileProcessing := &model.FileProcessing{
+		Path:       job.SourcePath,
+		DetectedAt: time.Now(),
+		Source:     model.EncoderSource,
+		Status:     model.KeptOriginalStatus,
+		Message:    jobEvent.Message,
+		JobId:      &job.Id,
+	}
+	detection, err := R.repo.GetFileProcessingByPath(ctx, job.SourcePath)
+	if err != nil {
+		helper.Error(err)
+	}
+	if detection != nil {
+		fileProcessing.DetectedAt = detection.DetectedAt
+		fileProcessing.Source = detection.Source
+	}
+	if err = R.repo.AddFileProcessing(ctx, fileProcessing); err != nil {
+		helper.Error(err)
+	}
+	if err = R.repo.SetScannedFileKeptOriginal(ctx, job.SourcePath); err != nil {
+		helper.Error(err)
+	}
+}
+
 func (R *RuntimeScheduler) ScheduleJobRequest(ctx context.Context, jobRequest *model.JobRequest) (*model.Job, error) {
+	profile, err := R.getProfile(jobRequest.Profile)
+	if err != nil {
+		return nil, &model.CustomError{Message: "invalid job request", Cause: err}
+	}
+	target, err := profile.Target()
+	if err != nil {
+		return nil, &model.CustomError{Message: "invalid job request", Cause: err}
+	}
+
 	filePath := filepath.Join(R.config.DownloadPath, jobRequest.SourcePath)
 	fileInfo, err := os.Stat(filePath)
 	if os.IsNotExist(err) {
@@ -302,16 +639,23 @@ func (R *RuntimeScheduler) ScheduleJobRequest(ctx context.Context, jobRequest *m
 		return nil, &model.CustomError{Message: errorMessage}
 	}
 
-	relativePathTarget := formatTargetName(relativePathSource)
+	relativePathTarget := target.FormatTargetName(relativePathSource, profile.OutputContainer())
 	if relativePathTarget == relativePathSource {
 		ext := filepath.Ext(relativePathTarget)
-		relativePathTarget = strings.Replace(relativePathTarget, ext, "_encoded.mkv", 1)
+		relativePathTarget = strings.Replace(relativePathTarget, ext, "_encoded."+profile.OutputContainer(), 1)
 	}
 
 	filteredJobRequest := &model.JobRequest{
 		SourcePath:      relativePathSource,
 		DestinationPath: relativePathTarget,
 		Priority:        jobRequest.Priority,
+		Profile:         jobRequest.Profile,
+	}
+	if jobRequest.Sample != nil {
+		filteredJobRequest.Sample, err = R.config.Samples.sampleRequest(jobRequest.Sample)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	job, err := R.scheduleJobRequest(ctx, filteredJobRequest)
@@ -333,10 +677,37 @@ func (R *RuntimeScheduler) ScheduleJobRequest(ctx context.Context, jobRequest *m
 }
 
 func (R *RuntimeScheduler) GetJob(ctx context.Context, uuid string) (*model.Job, error) {
-	return R.repo.GetJob(ctx, uuid)
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	job.Segments, err = R.repo.GetJobSegments(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	job.Progress = job.SegmentsProgress()
+	if job.Sample != nil {
+		R.setSampleSizes(job)
+	}
+	return job, nil
 }
 
 func (R *RuntimeScheduler) DeleteJob(ctx context.Context, uuid string) error {
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err == nil && isCancelable(job) {
+		// stop the worker before the job is gone
+		if err := R.CancelJob(ctx, uuid); err != nil {
+			helper.Error(err)
+		}
+	}
+	if err == nil && job.Sample != nil {
+		if err := os.RemoveAll(filepath.Join(R.config.Samples.Path, job.Id.String())); err != nil {
+			helper.Error(err)
+		}
+	}
+	if err == nil {
+		R.removeJobLogs(ctx, job)
+	}
 	return R.repo.DeleteJob(ctx, uuid)
 }
 
@@ -364,7 +735,54 @@ func (R *RuntimeScheduler) GetDownloadJobWriter(ctx context.Context, uuid string
 	if err != nil {
 		return nil, err
 	}
-	filePath := filepath.Join(R.config.DownloadPath, job.SourcePath)
+	filePath, err := R.prepareSourceFile(job)
+	if err != nil {
+		return nil, err
+	}
+	return R.newDownloadJobStream(job, filePath)
+}
+
+// GetSegmentDownloadJobWriter returns the reader of an encoded segment of a
+// chunked job, used by the worker joining them.
+func (R *RuntimeScheduler) GetSegmentDownloadJobWriter(ctx context.Context, uuid string, index int) (*DownloadJobStream, error) {
+	job, err := R.isValidStremeableJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := R.repo.GetJobSegments(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		if segment.Segment != nil && segment.Segment.Index == index {
+			return R.newDownloadJobStream(job, filepath.Join(R.config.UploadPath, segment.DestinationPath))
+		}
+	}
+	return nil, fmt.Errorf("%w: segment %d of job %s", ErrorJobNotFound, index, uuid)
+}
+
+// GetSampleDownloadJobWriter returns the reader of an encoded clip of a sample job.
+func (R *RuntimeScheduler) GetSampleDownloadJobWriter(ctx context.Context, uuid string, index int) (*DownloadJobStream, error) {
+	job, err := R.repo.GetJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if job.Sample == nil {
+		return nil, fmt.Errorf("%w: job %s is not a sample", ErrorStreamNotAllowed, uuid)
+	}
+	clips, err := R.repo.GetJobSegments(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	for _, clip := range clips {
+		if clip.Segment != nil && clip.Segment.Index == index && clip.Status == string(model.CompletedNotificationStatus) {
+			return R.newDownloadJobStream(job, filepath.Join(R.config.Samples.Path, clip.DestinationPath))
+		}
+	}
+	return nil, fmt.Errorf("%w: clip %d of job %s", ErrorJobNotFound, index, uuid)
+}
+
+func (R *RuntimeScheduler) newDownloadJobStream(job *model.Job, filePath string) (*DownloadJobStream, error) {
 	downloadFile, err := os.Open(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -390,6 +808,7 @@ func (R *RuntimeScheduler) GetDownloadJobWriter(ctx context.Context, uuid string
 		},
 		FileSize: dfStat.Size(),
 		FileName: dfStat.Name(),
+		ModTime:  dfStat.ModTime(),
 	}, nil
 
 }
@@ -400,15 +819,42 @@ func (R *RuntimeScheduler) GetUploadJobWriter(ctx context.Context, uuid string)
 		return nil, err
 	}
 
-	filePath := filepath.Join(R.config.UploadPath, job.DestinationPath)
-	err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	return newUploadJobStream(job, filepath.Join(R.storagePath(job), job.DestinationPath))
+}
+
+// storagePath is the directory where the job output is uploaded to.
+func (R *RuntimeScheduler) storagePath(job *model.Job) string {
+	if job.Sample != nil {
+		return R.config.Samples.Path
+	}
+	return R.config.UploadPath
+}
+
+// GetUploadSidecarWriter returns the writer of an extra artifact of the job,
+// like a subtitle, stored next to the destination path as <destination>.<name>.
+func (R *RuntimeScheduler) GetUploadSidecarWriter(ctx context.Context, uuid string, name string) (*UploadJobStream, error) {
+	if !sidecarNameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return nil, fmt.Errorf("%w: invalid sidecar name %s", ErrorStreamNotAllowed, name)
+	}
+	job, err := R.isValidStremeableJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	destinationPath := filepath.Join(R.config.UploadPath, job.DestinationPath)
+	filePath := strings.TrimSuffix(destinationPath, filepath.Ext(destinationPath)) + "." + name
+	return newUploadJobStream(job, filePath)
+}
+
+func newUploadJobStream(job *model.Job, filePath string) (*UploadJobStream, error) {
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
 	temporalPath := filePath + ".upload"
 	uploadFile, err := os.OpenFile(temporalPath, os.O_TRUNC|os.O_CREATE|os.O_RDWR, os.ModePerm)
 	return &UploadJobStream{
-		&JobStream{
+		JobStream: &JobStream{
 			job:          job,
 			file:         uploadFile,
 			path:         filePath,
@@ -422,18 +868,85 @@ func (R *RuntimeScheduler) GetChecksum(ctx context.Context, uuid string) (string
 	if err != nil {
 		return "", err
 	}
-	filePath := filepath.Join(R.config.DownloadPath, job.SourcePath)
-	checksum := R.pathChecksumMap[filePath]
-	if checksum == "" {
-		return "", fmt.Errorf("%w: Checksum not found for %s", ErrorJobNotFound, filePath)
+	filePath, err := R.prepareSourceFile(job)
+	if err != nil {
+		return "", err
+	}
+	return R.sourceChecksum(filePath)
+}
+
+// sourceChecksum returns the checksum of a downloaded source, or
+// ErrorSourcePending while it is hashed in the background.
+func (R *RuntimeScheduler) sourceChecksum(filePath string) (string, error) {
+	R.checksumMutex.Lock()
+	defer R.checksumMutex.Unlock()
+	if checksum, found := R.pathChecksumMap[filePath]; found {
+		return checksum, nil
+	}
+	// resumed downloads are served by ranges and never hash the whole file,
+	// the source is hashed in the background while workers retry
+	if !R.pendingChecksums[filePath] {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: Checksum not found for %s", ErrorJobNotFound, filePath)
+		} else if err != nil {
+			return "", err
+		}
+		R.pendingChecksums[filePath] = true
+		go R.hashSource(filePath)
+	}
+	return "", fmt.Errorf("%w: %s", ErrorSourcePending, filePath)
+}
+
+// hashSource calculates the checksum of a source requested before any full
+// download hashed it.
+func (R *RuntimeScheduler) hashSource(filePath string) {
+	checksum, err := fileChecksum(filePath)
+	if err != nil {
+		helper.Errorf("failed to calculate checksum of %s: %v", filePath, err)
+		R.checksumMutex.Lock()
+		defer R.checksumMutex.Unlock()
+		delete(R.pendingChecksums, filePath)
+		return
+	}
+	R.setChecksum(filePath, checksum)
+}
+
+func (R *RuntimeScheduler) setChecksum(filePath string, checksum string) {
+	R.checksumMutex.Lock()
+	defer R.checksumMutex.Unlock()
+	R.pathChecksumMap[filePath] = checksum
+	delete(R.pendingChecksums, filePath)
+}
+
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	sha := sha256.New()
+	if _, err := io.Copy(sha, file); err != nil {
+		return "", err
 	}
-	return checksum, nil
+	return hex.EncodeToString(sha.Sum(nil)), nil
 }
 
 func (R *RuntimeScheduler) GetWorkers(ctx context.Context) (*[]model.Worker, error) {
 	return R.repo.GetWorkers(ctx)
 }
 
+// SendWorkerAction drains, pauses or resumes a worker through its queue. The
+// worker reports its new state with its next ping.
+func (R *RuntimeScheduler) SendWorkerAction(ctx context.Context, name string, action model.JobAction) error {
+	worker, err := R.repo.GetWorker(ctx, name)
+	if err != nil {
+		return err
+	}
+	helper.Infof("sending %s to worker %s", action, name)
+	R.queue.PublishJobEvent(&model.JobEvent{Action: action}, worker.QueueName)
+	return nil
+}
+
 func (R *RuntimeScheduler) UpdateJobPriority(ctx context.Context, uuid string, priority int) error {
 	return R.repo.UpdateJobPriority(ctx, uuid, priority)
 }
@@ -446,8 +959,33 @@ func (R *RuntimeScheduler) GetWebhookEvent(ctx context.Context, id int64) (*mode
 	return R.repo.GetWebhookEvent(ctx, id)
 }
 
-func (S *RuntimeScheduler) stop() {
+func (R *RuntimeScheduler) GetProfiles(ctx context.Context) model.EncodingProfiles {
+	return R.config.Profiles.All()
+}
+
+// GetTargetCodec returns the codec produced by the default profile, used to
+// skip files that are already encoded in it.
+func (R *RuntimeScheduler) GetTargetCodec() codec.Target {
+	profile, err := R.getProfile("")
+	if err != nil {
+		return codec.X265
+	}
+	target, err := profile.Target()
+	if err != nil {
+		return codec.X265
+	}
+	return target
 }
-func formatTargetName(path string) string {
-	return codec.FormatTargetName(path)
+
+func (R *RuntimeScheduler) getProfile(name string) (*model.EncodingProfile, error) {
+	if name == "" {
+		name = R.config.DefaultProfile
+	}
+	if name == "" {
+		name = model.DefaultEncodingProfileName
+	}
+	return R.config.Profiles.Get(name)
+}
+
+func (S *RuntimeScheduler) stop() {
 }
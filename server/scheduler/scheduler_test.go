@@ -2,7 +2,10 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"gearr/model"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -310,3 +313,65 @@ func TestSendUpdateJobsNotification_ChannelBufferFull(t *testing.T) {
 
 	rs.CloseUpdateJobsChan(id)
 }
+
+func TestGetUploadSidecarWriter_InvalidName(t *testing.T) {
+	rs := &RuntimeScheduler{}
+	for _, name := range []string{"", "../eng.srt", ".eng.srt", "eng/../../x.srt", "eng..srt"} {
+		t.Run(name, func(t *testing.T) {
+			_, err := rs.GetUploadSidecarWriter(context.Background(), uuid.New().String(), name)
+			if !errors.Is(err, ErrorStreamNotAllowed) {
+				t.Errorf("GetUploadSidecarWriter(%q) error = %v, want ErrorStreamNotAllowed", name, err)
+			}
+		})
+	}
+}
+
+func TestGetJobLogs_InvalidName(t *testing.T) {
+	rs := &RuntimeScheduler{}
+	for _, name := range []string{"", "../ffmpeg.log", ".ffmpeg.log", "logs/../../x.log", "ffmpeg..log", "ffmpeg.log.upload"} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := rs.GetUploadLogWriter(context.Background(), uuid.New().String(), name); !errors.Is(err, ErrorStreamNotAllowed) {
+				t.Errorf("GetUploadLogWriter(%q) error = %v, want ErrorStreamNotAllowed", name, err)
+			}
+			if _, err := rs.GetJobLogPath(context.Background(), uuid.New().String(), name); !errors.Is(err, ErrorStreamNotAllowed) {
+				t.Errorf("GetJobLogPath(%q) error = %v, want ErrorStreamNotAllowed", name, err)
+			}
+		})
+	}
+}
+
+func TestSourceChecksum_Background(t *testing.T) {
+	rs := &RuntimeScheduler{
+		pathChecksumMap:  make(map[string]string),
+		pendingChecksums: make(map[string]bool),
+	}
+	if _, err := rs.sourceChecksum(filepath.Join(t.TempDir(), "missing.mkv")); !errors.Is(err, ErrorJobNotFound) {
+		t.Fatalf("sourceChecksum(missing) error = %v, want ErrorJobNotFound", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "source.mkv")
+	if err := os.WriteFile(filePath, []byte("source"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.sourceChecksum(filePath); !errors.Is(err, ErrorSourcePending) {
+		t.Fatalf("sourceChecksum() error = %v, want ErrorSourcePending", err)
+	}
+	want := sha256Hex([]byte("source"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checksum, err := rs.sourceChecksum(filePath)
+		if err == nil {
+			if checksum != want {
+				t.Errorf("sourceChecksum() = %s, want %s", checksum, want)
+			}
+			return
+		}
+		if !errors.Is(err, ErrorSourcePending) {
+			t.Fatalf("sourceChecksum() error = %v, want ErrorSourcePending", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("checksum not calculated in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const (
+	segmentsDir = ".segments"
+	// keyframeSearchWindow is how far after each cut point keyframes are looked
+	// for, long enough for the GOP size of any sane encode.
+	keyframeSearchWindow = 30 * time.Second
+	probeTimeout         = 5 * time.Minute
+	// cutTimeout bounds copying the video of a segment, a fraction of the
+	// source read from disk.
+	cutTimeout = time.Hour
+)
+
+// ChunkingConfig controls splitting long sources into segments encoded in
+// parallel by different workers.
+type ChunkingConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	SegmentDuration time.Duration `mapstructure:"segmentDuration"`
+	MinDuration     time.Duration `mapstructure:"minDuration"`
+}
+
+// planSegments splits the source at keyframes every SegmentDuration. It
+// returns no segments when chunking is disabled or the source is too short to
+// be worth it, the job is then encoded as a whole by a single worker.
+func (R *RuntimeScheduler) planSegments(ctx context.Context, sourcePath string) ([]model.Segment, error) {
+	config := R.config.Chunking
+	if !config.Enabled || config.SegmentDuration <= 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	filePath := filepath.Join(R.config.DownloadPath, sourcePath)
+	data, err := ffprobe.ProbeURL(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe file %s: %w", filePath, err)
+	}
+	duration := data.Format.DurationSeconds
+	if duration < config.MinDuration.Seconds() {
+		return nil, nil
+	}
+
+	segmentDuration := config.SegmentDuration.Seconds()
+	cutPoints := segmentCutPoints(duration, segmentDuration)
+	if len(cutPoints) == 0 {
+		return nil, nil
+	}
+	keyframes, err := probeKeyframes(ctx, filePath, cutPoints)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keyframes {
+		keyframes[i] -= data.Format.StartTimeSeconds
+	}
+
+	segments := splitSegments(duration, segmentDuration, keyframes)
+	if len(segments) < 2 {
+		return nil, nil
+	}
+	helper.Infof("%s will be encoded in %d segments", sourcePath, len(segments))
+	return segments, nil
+}
+
+// segmentCutPoints returns the ideal positions of the cuts, leaving the last
+// segment at least half a segment long.
+func segmentCutPoints(duration float64, segmentDuration float64) []float64 {
+	var cutPoints []float64
+	for cutPoint := segmentDuration; cutPoint < duration-segmentDuration/2; cutPoint += segmentDuration {
+		cutPoints = append(cutPoints, cutPoint)
+	}
+	return cutPoints
+}
+
+// probeKeyframes reads the video packets around each cut point and returns
+// the timestamps of the keyframes found, reading only a small window of the
+// file per cut.
+func probeKeyframes(ctx context.Context, filePath string, cutPoints []float64) ([]float64, error) {
+	intervals := make([]string, len(cutPoints))
+	for i, cutPoint := range cutPoints {
+		intervals[i] = fmt.Sprintf("%.3f%%+%.0f", cutPoint, keyframeSearchWindow.Seconds())
+	}
+
+	output, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-read_intervals", strings.Join(intervals, ","),
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		filePath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyframes of %s: %w", filePath, err)
+	}
+	return parseKeyframes(output), nil
+}
+
+// parseKeyframes parses the "pts_time,flags" csv lines of ffprobe and keeps
+// the packets flagged as keyframes.
+func parseKeyframes(output []byte) []float64 {
+	var keyframes []float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 2 || !strings.Contains(fields[1], "K") {
+			continue
+		}
+		pts, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		keyframes = append(keyframes, pts)
+	}
+	return keyframes
+}
+
+// splitSegments cuts the source at the first keyframe after every cut point.
+// Cut points without a keyframe nearby are skipped, merging both segments.
+func splitSegments(duration float64, segmentDuration float64, keyframes []float64) []model.Segment {
+	sort.Float64s(keyframes)
+	maxCut := duration - segmentDuration/2
+
+	var cuts []float64
+	last := 0.0
+	for _, cutPoint := range segmentCutPoints(duration, segmentDuration) {
+		for _, keyframe := range keyframes {
+			if keyframe >= cutPoint && keyframe > last && keyframe < maxCut {
+				cuts = append(cuts, keyframe)
+				last = keyframe
+				break
+			}
+		}
+	}
+
+	segments := make([]model.Segment, 0, len(cuts)+1)
+	start := 0.0
+	for i, cut := range cuts {
+		segments = append(segments, model.Segment{Index: i, Start: start, End: cut})
+		start = cut
+	}
+	return append(segments, model.Segment{Index: len(cuts), Start: start})
+}
+
+// segmentPath is where the encoded segment is uploaded, relative to the
+// upload path.
+func segmentPath(parentID uuid.UUID, index int) string {
+	return filepath.Join(segmentsDir, parentID.String(), fmt.Sprintf("%04d.mkv", index))
+}
+
+// segmentSourcePath is where the part of the source encoded by a segment, or
+// a sample clip, is cut, relative to the upload path.
+func segmentSourcePath(parentID uuid.UUID, index int) string {
+	return filepath.Join(segmentsDir, parentID.String(), fmt.Sprintf("source-%04d.mkv", index))
+}
+
+// sourceFile is the file the workers of the job read: the source, or the cut
+// of it of a segment.
+func (R *RuntimeScheduler) sourceFile(job *model.Job) string {
+	if job.ParentId != nil && job.Segment != nil {
+		return filepath.Join(R.config.UploadPath, segmentSourcePath(*job.ParentId, job.Segment.Index))
+	}
+	return filepath.Join(R.config.DownloadPath, job.SourcePath)
+}
+
+// prepareSourceFile returns the source file of the job. The cut of a segment
+// is made in the background the first time it is asked for, returning
+// ErrorSourcePending meanwhile.
+func (R *RuntimeScheduler) prepareSourceFile(job *model.Job) (string, error) {
+	filePath := R.sourceFile(job)
+	if job.ParentId == nil || job.Segment == nil {
+		return filePath, nil
+	}
+	R.cutMutex.Lock()
+	defer R.cutMutex.Unlock()
+	if !R.pendingCuts[filePath] {
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		sourcePath := filepath.Join(R.config.DownloadPath, job.SourcePath)
+		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: source %s not found", ErrorJobNotFound, sourcePath)
+		} else if err != nil {
+			return "", err
+		}
+		R.pendingCuts[filePath] = true
+		go R.cutSegment(sourcePath, filePath, *job.Segment)
+	}
+	return "", fmt.Errorf("%w: %s", ErrorSourcePending, filePath)
+}
+
+// cutSegment copies the video of the segment out of the source, from its
+// starting keyframe. Segments carry only the video, audio and subtitles are
+// muxed on join.
+func (R *RuntimeScheduler) cutSegment(sourcePath string, filePath string, segment model.Segment) {
+	defer func() {
+		R.cutMutex.Lock()
+		defer R.cutMutex.Unlock()
+		delete(R.pendingCuts, filePath)
+	}()
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		helper.Errorf("failed to cut segment %s: %v", filePath, err)
+		return
+	}
+	temporalPath := filePath + ".part"
+	ctx, cancel := context.WithTimeout(context.Background(), cutTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "ffmpeg", cutArguments(sourcePath, temporalPath, segment)...).CombinedOutput()
+	if err == nil {
+		err = os.Rename(temporalPath, filePath)
+	}
+	if err != nil {
+		os.Remove(temporalPath)
+		helper.Errorf("failed to cut segment %s: %v: %s", filePath, err, output)
+	}
+}
+
+// cutArguments are the ffmpeg arguments copying the video of the segment.
+func cutArguments(sourcePath string, filePath string, segment model.Segment) []string {
+	arguments := []string{"-hide_banner", "-nostats", "-v", "error"}
+	if segment.Start > 0 {
+		arguments = append(arguments, "-ss", fmt.Sprintf("%.6f", segment.Start))
+	}
+	arguments = append(arguments, "-i", sourcePath)
+	if segment.End > 0 {
+		arguments = append(arguments, "-t", fmt.Sprintf("%.6f", segment.End-segment.Start))
+	}
+	return append(arguments, "-map", "0:v:0", "-c", "copy", "-f", "matroska", "-y", filePath)
+}
+
+// removeSegmentSource deletes the cut of the source of a finished segment.
+func (R *RuntimeScheduler) removeSegmentSource(job *model.Job) {
+	filePath := R.sourceFile(job)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		helper.Error(err)
+	}
+	R.checksumMutex.Lock()
+	defer R.checksumMutex.Unlock()
+	delete(R.pathChecksumMap, filePath)
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"errors"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSplitSegments(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  float64
+		keyframes []float64
+		expected  []model.Segment
+	}{
+		{
+			name:      "cut at first keyframe after each cut point",
+			duration:  1000,
+			keyframes: []float64{299.5, 302.1, 310, 601.7, 605},
+			expected: []model.Segment{
+				{Index: 0, Start: 0, End: 302.1},
+				{Index: 1, Start: 302.1, End: 601.7},
+				{Index: 2, Start: 601.7},
+			},
+		},
+		{
+			name:      "cut point without keyframe merges segments",
+			duration:  1000,
+			keyframes: []float64{601.7},
+			expected: []model.Segment{
+				{Index: 0, Start: 0, End: 601.7},
+				{Index: 1, Start: 601.7},
+			},
+		},
+		{
+			name:      "short last segment is merged",
+			duration:  700,
+			keyframes: []float64{300.2, 600.4},
+			expected: []model.Segment{
+				{Index: 0, Start: 0, End: 300.2},
+				{Index: 1, Start: 300.2},
+			},
+		},
+		{
+			name:      "no keyframes",
+			duration:  1000,
+			keyframes: nil,
+			expected:  []model.Segment{{Index: 0, Start: 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := splitSegments(tt.duration, 300, tt.keyframes)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("splitSegments() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseKeyframes(t *testing.T) {
+	output := []byte("300.041000,K__\n300.083000,___\nN/A,K__\n302.127000,K_\n\n")
+	expected := []float64{300.041, 302.127}
+	if result := parseKeyframes(output); !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseKeyframes() = %v, want %v", result, expected)
+	}
+}
+
+func TestCutArguments(t *testing.T) {
+	tests := []struct {
+		name     string
+		segment  model.Segment
+		expected []string
+	}{
+		{
+			name:     "first segment",
+			segment:  model.Segment{Index: 0, End: 300.2},
+			expected: []string{"-hide_banner", "-nostats", "-v", "error", "-i", "source.mkv", "-t", "300.200000", "-map", "0:v:0", "-c", "copy", "-f", "matroska", "-y", "cut.mkv"},
+		},
+		{
+			name:     "middle segment",
+			segment:  model.Segment{Index: 1, Start: 300.2, End: 600.25},
+			expected: []string{"-hide_banner", "-nostats", "-v", "error", "-ss", "300.200000", "-i", "source.mkv", "-t", "300.050000", "-map", "0:v:0", "-c", "copy", "-f", "matroska", "-y", "cut.mkv"},
+		},
+		{
+			name:     "last segment",
+			segment:  model.Segment{Index: 2, Start: 600.25},
+			expected: []string{"-hide_banner", "-nostats", "-v", "error", "-ss", "600.250000", "-i", "source.mkv", "-map", "0:v:0", "-c", "copy", "-f", "matroska", "-y", "cut.mkv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := cutArguments("source.mkv", "cut.mkv", tt.segment); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("cutArguments() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrepareSourceFile(t *testing.T) {
+	rs := &RuntimeScheduler{
+		config:      SchedulerConfig{DownloadPath: t.TempDir(), UploadPath: t.TempDir()},
+		pendingCuts: make(map[string]bool),
+	}
+	parentID := uuid.New()
+	segment := &model.Job{Id: uuid.New(), SourcePath: "movie.mkv", ParentId: &parentID, Segment: &model.Segment{Index: 1, Start: 300}}
+
+	if _, err := rs.prepareSourceFile(segment); !errors.Is(err, ErrorJobNotFound) {
+		t.Fatalf("prepareSourceFile() without source error = %v, want ErrorJobNotFound", err)
+	}
+	if err := os.WriteFile(filepath.Join(rs.config.DownloadPath, "movie.mkv"), []byte("source"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the cut runs in the background
+	rs.pendingCuts[rs.sourceFile(segment)] = true
+	if _, err := rs.prepareSourceFile(segment); !errors.Is(err, ErrorSourcePending) {
+		t.Fatalf("prepareSourceFile() while cutting error = %v, want ErrorSourcePending", err)
+	}
+	delete(rs.pendingCuts, rs.sourceFile(segment))
+
+	cutPath := filepath.Join(rs.config.UploadPath, segmentSourcePath(parentID, 1))
+	if err := os.MkdirAll(filepath.Dir(cutPath), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cutPath, []byte("cut"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if filePath, err := rs.prepareSourceFile(segment); err != nil || filePath != cutPath {
+		t.Errorf("prepareSourceFile() = %s, %v, want %s", filePath, err, cutPath)
+	}
+
+	job := &model.Job{Id: uuid.New(), SourcePath: "movie.mkv"}
+	if filePath, err := rs.prepareSourceFile(job); err != nil || filePath != filepath.Join(rs.config.DownloadPath, "movie.mkv") {
+		t.Errorf("prepareSourceFile() of a whole job = %s, %v", filePath, err)
+	}
+}
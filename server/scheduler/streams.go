@@ -3,9 +3,11 @@ package scheduler
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"gearr/model"
 	"hash"
 	"os"
+	"time"
 )
 
 type PathChecksum struct {
@@ -23,12 +25,18 @@ type JobStream struct {
 
 type UploadJobStream struct {
 	*JobStream
+	// session streams are chunked uploads, kept between requests until
+	// Finalize moves them to the destination
+	session bool
 }
 
 type DownloadJobStream struct {
 	*JobStream
 	FileSize int64
 	FileName string
+	ModTime  time.Time
+	// seeked streams serve ranges, their hash is not the file checksum
+	seeked bool
 }
 
 func (U *JobStream) hash(p []byte) (err error) {
@@ -52,10 +60,26 @@ func (D *DownloadJobStream) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return readed, err
 	}
-	D.hash(p[0:readed])
+	if !D.seeked {
+		D.hash(p[0:readed])
+	}
 	return readed, err
 }
 
+// Seek moves the read offset to serve a range of the file. A seeked stream
+// does not push its checksum.
+func (D *DownloadJobStream) Seek(offset int64, whence int) (int64, error) {
+	D.seeked = true
+	D.hasher = nil
+	return D.file.Seek(offset, whence)
+}
+
+// ETag identifies the version of the file, so a resumed download does not mix
+// ranges of a replaced source.
+func (D *DownloadJobStream) ETag() string {
+	return fmt.Sprintf("\"%x-%x\"", D.FileSize, D.ModTime.UnixNano())
+}
+
 func (D *DownloadJobStream) Size() int64 {
 	return D.FileSize
 }
@@ -65,6 +89,9 @@ func (D *DownloadJobStream) Name() string {
 }
 
 func (U *UploadJobStream) Close(pushChecksum bool) error {
+	if U.session {
+		return U.file.Close()
+	}
 	U.file.Sync()
 	U.file.Close()
 	return os.Rename(U.temporalPath, U.path)
@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gearr/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Chunked uploads keep the job result in the .upload file of the destination
+// between requests, next to a .session file with the announced size, so a
+// worker resumes an interrupted upload from the offset the server stored.
+const uploadSessionExt = ".session"
+
+// GetUploadSessionWriter returns the writer of the chunked upload of the job
+// result, keeping the chunks already received.
+func (R *RuntimeScheduler) GetUploadSessionWriter(ctx context.Context, uuid string) (*UploadJobStream, error) {
+	job, err := R.isValidStremeableJob(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return newUploadSessionStream(job, filepath.Join(R.storagePath(job), job.DestinationPath))
+}
+
+func newUploadSessionStream(job *model.Job, filePath string) (*UploadJobStream, error) {
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	temporalPath := filePath + ".upload"
+	uploadFile, err := os.OpenFile(temporalPath, os.O_CREATE|os.O_RDWR, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadJobStream{
+		JobStream: &JobStream{
+			job:          job,
+			file:         uploadFile,
+			path:         filePath,
+			temporalPath: temporalPath,
+		},
+		session: true,
+	}, nil
+}
+
+func (U *UploadJobStream) sessionPath() string {
+	return U.temporalPath + uploadSessionExt
+}
+
+// StartSession begins the upload of a file of the given size, or resumes the
+// session already started for the same size.
+func (U *UploadJobStream) StartSession(size int64) (*model.UploadSession, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("%w: invalid upload size %d", ErrorUploadOffset, size)
+	}
+	session, err := U.Session()
+	if err == nil && session.Size == size && session.Offset <= size {
+		return session, nil
+	}
+	if err != nil && !errors.Is(err, ErrorJobNotFound) {
+		return nil, err
+	}
+	if err := U.file.Truncate(0); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(U.sessionPath(), []byte(strconv.FormatInt(size, 10)), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &model.UploadSession{Size: size}, nil
+}
+
+// Session returns the state of the upload, ErrorJobNotFound when the session
+// was not started.
+func (U *UploadJobStream) Session() (*model.UploadSession, error) {
+	sizeBytes, err := os.ReadFile(U.sessionPath())
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: upload session of job %s not started", ErrorJobNotFound, U.job.Id.String())
+	} else if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(string(sizeBytes), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	info, err := U.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &model.UploadSession{Size: size, Offset: info.Size()}, nil
+}
+
+// WriteChunk stores a chunk starting at the offset of the session. A chunk
+// shorter than its length or with a different sha256 is discarded.
+func (U *UploadJobStream) WriteChunk(start int64, length int64, checksum string, reader io.Reader) (*model.UploadSession, error) {
+	session, err := U.Session()
+	if err != nil {
+		return nil, err
+	}
+	if start != session.Offset {
+		return session, fmt.Errorf("%w: chunk starts at %d, the upload is at %d", ErrorUploadOffset, start, session.Offset)
+	}
+	if length <= 0 || start+length > session.Size {
+		return session, fmt.Errorf("%w: chunk of %d bytes at %d exceeds the size %d", ErrorUploadOffset, length, start, session.Size)
+	}
+
+	sha := sha256.New()
+	written, err := io.Copy(io.NewOffsetWriter(U.file, start), io.TeeReader(io.LimitReader(reader, length), sha))
+	if err == nil && written != length {
+		err = fmt.Errorf("%w: received %d bytes of a %d bytes chunk", ErrorUploadChecksum, written, length)
+	}
+	if chunkChecksum := hex.EncodeToString(sha.Sum(nil)); err == nil && chunkChecksum != checksum {
+		err = fmt.Errorf("%w: chunk at %d, received %s, calculated %s", ErrorUploadChecksum, start, checksum, chunkChecksum)
+	}
+	if err != nil {
+		if truncateErr := U.file.Truncate(start); truncateErr != nil {
+			return session, truncateErr
+		}
+		return session, err
+	}
+
+	session.Offset = start + length
+	return session, U.file.Sync()
+}
+
+// Finalize checks the whole upload against its sha256 and moves it to the
+// destination. A file with a different checksum restarts the session.
+func (U *UploadJobStream) Finalize(checksum string) error {
+	session, err := U.Session()
+	if err != nil {
+		return err
+	}
+	if session.Offset != session.Size {
+		return fmt.Errorf("%w: received %d bytes of %d", ErrorUploadOffset, session.Offset, session.Size)
+	}
+
+	sha := sha256.New()
+	if _, err := io.Copy(sha, io.NewSectionReader(U.file, 0, session.Size)); err != nil {
+		return err
+	}
+	if uploadChecksum := hex.EncodeToString(sha.Sum(nil)); uploadChecksum != checksum {
+		if err := U.file.Truncate(0); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: received %s, calculated %s", ErrorUploadChecksum, checksum, uploadChecksum)
+	}
+
+	if err := U.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(U.temporalPath, U.path); err != nil {
+		return err
+	}
+	return os.Remove(U.sessionPath())
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func sha256Hex(data []byte) string {
+	sha := sha256.Sum256(data)
+	return hex.EncodeToString(sha[:])
+}
+
+func openUploadSession(t *testing.T, job *model.Job, filePath string) *UploadJobStream {
+	stream, err := newUploadSessionStream(job, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stream.Close(false) })
+	return stream
+}
+
+func TestUploadSession_Resume(t *testing.T) {
+	job := &model.Job{Id: uuid.New()}
+	filePath := filepath.Join(t.TempDir(), "movie.mkv")
+	content := []byte("0123456789abcdefghij")
+
+	stream := openUploadSession(t, job, filePath)
+	if _, err := stream.StartSession(int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.WriteChunk(0, 8, sha256Hex(content[:8]), bytes.NewReader(content[:8])); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+	stream.Close(false)
+
+	// a new request resumes the session at the stored offset
+	stream = openUploadSession(t, job, filePath)
+	session, err := stream.StartSession(int64(len(content)))
+	if err != nil || session.Offset != 8 {
+		t.Fatalf("StartSession() = %+v, %v, want offset 8", session, err)
+	}
+	if _, err := stream.WriteChunk(4, 4, sha256Hex(content[4:8]), bytes.NewReader(content[4:8])); !errors.Is(err, ErrorUploadOffset) {
+		t.Errorf("WriteChunk() at an old offset error = %v, want ErrorUploadOffset", err)
+	}
+	if _, err := stream.WriteChunk(8, 12, sha256Hex(content[8:]), bytes.NewReader([]byte("corrupted!!!"))); !errors.Is(err, ErrorUploadChecksum) {
+		t.Errorf("WriteChunk() of a corrupted chunk error = %v, want ErrorUploadChecksum", err)
+	}
+	if _, err := stream.WriteChunk(8, 12, sha256Hex(content[8:]), bytes.NewReader(content[8:10])); !errors.Is(err, ErrorUploadChecksum) {
+		t.Errorf("WriteChunk() of a short chunk error = %v, want ErrorUploadChecksum", err)
+	}
+	if session, _ := stream.Session(); session.Offset != 8 {
+		t.Errorf("offset after rejected chunks = %d, want 8", session.Offset)
+	}
+	if err := stream.Finalize(sha256Hex(content)); !errors.Is(err, ErrorUploadOffset) {
+		t.Errorf("Finalize() of an incomplete upload error = %v, want ErrorUploadOffset", err)
+	}
+	if _, err := stream.WriteChunk(8, 12, sha256Hex(content[8:]), bytes.NewReader(content[8:])); err != nil {
+		t.Fatalf("WriteChunk() error = %v", err)
+	}
+	if err := stream.Finalize(sha256Hex(content)); err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+
+	uploaded, err := os.ReadFile(filePath)
+	if err != nil || !bytes.Equal(uploaded, content) {
+		t.Errorf("uploaded file = %q, %v, want %q", uploaded, err, content)
+	}
+	if _, err := os.Stat(filePath + ".upload" + uploadSessionExt); !os.IsNotExist(err) {
+		t.Errorf("session file was not removed")
+	}
+}
+
+func TestUploadSession_FinalizeChecksumMismatch(t *testing.T) {
+	job := &model.Job{Id: uuid.New()}
+	filePath := filepath.Join(t.TempDir(), "movie.mkv")
+	content := []byte("0123456789")
+
+	stream := openUploadSession(t, job, filePath)
+	if _, err := stream.StartSession(int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.WriteChunk(0, 10, sha256Hex(content), bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Finalize(sha256Hex([]byte("another file"))); !errors.Is(err, ErrorUploadChecksum) {
+		t.Errorf("Finalize() error = %v, want ErrorUploadChecksum", err)
+	}
+	if session, _ := stream.Session(); session.Offset != 0 {
+		t.Errorf("offset after a checksum mismatch = %d, want the upload restarted", session.Offset)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("destination written after a checksum mismatch")
+	}
+}
+
+func TestUploadSession_NotStarted(t *testing.T) {
+	stream := openUploadSession(t, &model.Job{Id: uuid.New()}, filepath.Join(t.TempDir(), "movie.mkv"))
+	if _, err := stream.Session(); !errors.Is(err, ErrorJobNotFound) {
+		t.Errorf("Session() error = %v, want ErrorJobNotFound", err)
+	}
+}
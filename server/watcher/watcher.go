@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"gearr/helper"
-	"gearr/helper/codec"
 	"gearr/model"
 	"gearr/server/repository"
 	"gearr/server/scheduler"
@@ -207,13 +206,14 @@ func (w *Watcher) processFile(filePath string) {
 		Source:     model.WatcherSource,
 	}
 
-	if !codec.NeedsTranscoding(relativePath) {
-		fp.Status = model.X265Status
-		fp.Message = "file already encoded in x265 or compatible codec"
+	target := w.scheduler.GetTargetCodec()
+	if !target.NeedsTranscoding(relativePath) {
+		fp.Status = model.TargetCodecStatus
+		fp.Message = fmt.Sprintf("file already encoded in %s", target.Name)
 		if err := w.repo.AddFileProcessing(w.ctx, fp); err != nil {
 			helper.Errorf("failed to record file processing for %s: %v", relativePath, err)
 		}
-		helper.Infof("watcher: file %s is already x265, skipping", relativePath)
+		helper.Infof("watcher: file %s is already %s, skipping", relativePath, target.Name)
 		return
 	}
 
@@ -221,6 +221,10 @@ func (w *Watcher) processFile(filePath string) {
 		helper.Debugf("file %s already queued, skipping", relativePath)
 		return
 	}
+	if existingDetection != nil && existingDetection.Status == model.KeptOriginalStatus {
+		helper.Debugf("file %s was kept original, skipping", relativePath)
+		return
+	}
 
 	jobRequest := &model.JobRequest{
 		SourcePath:      relativePath,
@@ -357,10 +361,6 @@ func (w *Watcher) ParseWatchPaths(pathsStr string) []string {
 	return paths
 }
 
-func GenerateTargetPath(sourcePath string) string {
-	return codec.FormatTargetName(sourcePath)
-}
-
 func NewUUID() uuid.UUID {
 	id, _ := uuid.NewUUID()
 	return id
@@ -75,6 +75,19 @@ export async function fetchWorkers(token: string) {
   return response.data;
 }
 
+export async function sendWorkerAction(token: string, name: string, action: 'drain' | 'pause' | 'resume'): Promise<void> {
+  try {
+    await axios.post(`/api/v1/workers/${encodeURIComponent(name)}/${action}`, null, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+  } catch (error) {
+    console.error(`Error sending ${action} to worker ${name}:`, error);
+    throw error;
+  }
+}
+
 export async function fetchScannerStatus(token: string): Promise<ScannerStatus> {
   scannerStore.setLoading();
   
@@ -137,6 +150,32 @@ export async function updateJobPriority(token: string, jobId: string, priority:
   }
 }
 
+export async function pauseJob(token: string, jobId: string): Promise<void> {
+  try {
+    await axios.post(`/api/v1/job/${jobId}/pause`, null, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+  } catch (error) {
+    console.error(`Error pausing job ${jobId}:`, error);
+    throw error;
+  }
+}
+
+export async function resumeJob(token: string, jobId: string): Promise<void> {
+  try {
+    await axios.post(`/api/v1/job/${jobId}/resume`, null, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+  } catch (error) {
+    console.error(`Error resuming job ${jobId}:`, error);
+    throw error;
+  }
+}
+
 export interface WebhookTestResult {
   success: boolean;
   message: string;
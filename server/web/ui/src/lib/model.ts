@@ -7,6 +7,19 @@ export interface Job {
   status_message: string;
   last_update: Date;
   priority: number;
+  encode_progress?: EncodeProgress;
+}
+
+export interface EncodeProgress {
+  percent: number;
+  frame: number;
+  fps: number;
+  bitrate: number;
+  output_size: number;
+  speed: number;
+  eta: number;
+  pass?: number;
+  passes?: number;
 }
 
 export function createJob(responseData: Partial<Job>): Job {
@@ -19,6 +32,7 @@ export function createJob(responseData: Partial<Job>): Job {
     status_message: responseData.status_message || '',
     last_update: new Date(responseData.last_update || Date.now()),
     priority: responseData.priority ?? 1,
+    encode_progress: responseData.encode_progress,
   };
 }
 
@@ -27,6 +41,7 @@ export interface JobUpdateNotification {
   status: string;
   status_phase: string;
   message: string;
+  progress?: EncodeProgress;
   event_time: Date;
   source_path: string;
   destination_path: string;
@@ -40,6 +55,7 @@ export function createJobUpdateNotification(
     status: responseData.status || '',
     status_phase: responseData.status_phase || '',
     message: responseData.message || '',
+    progress: responseData.progress,
     event_time: new Date(responseData.event_time || Date.now()),
     source_path: responseData.source_path || '',
     destination_path: responseData.destination_path || '',
@@ -51,4 +67,17 @@ export interface Worker {
   id: string;
   queue_name: string;
   last_seen: string;
+  state: 'active' | 'draining' | 'drained' | 'paused';
+  capabilities?: WorkerCapabilities;
+}
+
+export interface WorkerCapabilities {
+  version?: string;
+  threads: number;
+  accepted_jobs?: string[];
+  encode_jobs: number;
+  pgs_jobs: number;
+  encoders?: string[];
+  free_temp_space: number;
+  labels?: Record<string, string>;
 }
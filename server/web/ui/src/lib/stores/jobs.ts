@@ -39,6 +39,7 @@ function createJobStore() {
             status: notification.status,
             status_phase: notification.status_phase,
             status_message: notification.message,
+            encode_progress: notification.progress,
             last_update: notification.event_time,
           };
           return { ...state, loading: false, jobs: updatedJobs };
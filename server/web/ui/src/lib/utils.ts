@@ -1,6 +1,6 @@
-import type { Job } from './model';
+import type { EncodeProgress, Job } from './model';
 
-export const STATUS_FILTER_OPTIONS = ['progressing', 'queued', 'completed', 'failed'];
+export const STATUS_FILTER_OPTIONS = ['progressing', 'queued', 'completed', 'failed', 'kept_original', 'paused'];
 
 export const PRIORITY_FILTER_OPTIONS = [
   { value: '0', label: 'Low' },
@@ -20,6 +20,36 @@ export const DATE_FILTER_OPTIONS = [
   'Last 30 days',
 ];
 
+export const formatEncodeProgress = (progress: EncodeProgress): string => {
+  const parts = [`${progress.percent.toFixed(2)}%`];
+  if (progress.passes) {
+    parts.push(`pass ${progress.pass}/${progress.passes}`);
+  }
+  if (progress.fps > 0) {
+    parts.push(`${progress.fps.toFixed(1)} fps`);
+  }
+  if (progress.bitrate > 0) {
+    parts.push(`${Math.round(progress.bitrate)} kbps`);
+  }
+  if (progress.speed > 0) {
+    parts.push(`${progress.speed.toFixed(2)}x`);
+  }
+  if (progress.eta > 0) {
+    const hours = Math.floor(progress.eta / 3600);
+    const minutes = Math.floor((progress.eta % 3600) / 60);
+    parts.push(`ETA ${hours > 0 ? `${hours}h ` : ''}${minutes}m`);
+  }
+  return parts.join(' · ');
+};
+
+export const formatBytes = (bytes: number): string => {
+  if (bytes === 0) return '0 B';
+  const k = 1024;
+  const sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+  const i = Math.floor(Math.log(bytes) / Math.log(k));
+  return parseFloat((bytes / Math.pow(k, i)).toFixed(1)) + ' ' + sizes[i];
+};
+
 const formatDate = (date: Date, options: Intl.DateTimeFormatOptions): string => {
   if (date == null) {
     return '';
@@ -55,6 +55,10 @@ func (w *WebServer) addJob(c *gin.Context) {
 			c.Status(http.StatusConflict)
 			return
 		}
+		if errors.Is(err, model.ErrProfileNotFound) {
+			webError(c, err, http.StatusBadRequest)
+			return
+		}
 		c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -104,6 +108,42 @@ func (w *WebServer) deleteJob(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+func (w *WebServer) cancelJob(c *gin.Context) {
+	w.jobAction(c, w.scheduler.CancelJob)
+}
+
+func (w *WebServer) pauseJob(c *gin.Context) {
+	w.jobAction(c, w.scheduler.PauseJob)
+}
+
+func (w *WebServer) resumeJob(c *gin.Context) {
+	w.jobAction(c, w.scheduler.ResumeJob)
+}
+
+// jobAction runs an action of a job, answering 409 when its status does not
+// allow it.
+func (w *WebServer) jobAction(c *gin.Context, action func(ctx context.Context, uuid string) error) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+
+	err := action(w.ctx, id)
+	switch {
+	case errors.Is(err, scheduler.ErrorJobNotFound), errors.Is(err, repository.ErrElementNotFound):
+		webError(c, err, 404)
+		return
+	case errors.Is(err, scheduler.ErrorInvalidStatus):
+		webError(c, err, http.StatusConflict)
+		return
+	case webError(c, err, http.StatusInternalServerError):
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
 func (w *WebServer) updateJobPriority(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -133,6 +173,10 @@ func (w *WebServer) updateJobPriority(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"id": id, "priority": req.Priority})
 }
 
+func (w *WebServer) getProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, w.scheduler.GetProfiles(w.ctx))
+}
+
 func (w *WebServer) getJobsUpdates(c *gin.Context) {
 	conn, err := w.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -168,6 +212,70 @@ func (w *WebServer) upload(c *gin.Context) {
 	}
 
 	uploadStream, err := w.scheduler.GetUploadJobWriter(c.Request.Context(), id)
+	w.receiveUpload(c, uploadStream, err)
+}
+
+func (w *WebServer) uploadSidecar(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+
+	uploadStream, err := w.scheduler.GetUploadSidecarWriter(c.Request.Context(), id, c.Param("name"))
+	w.receiveUpload(c, uploadStream, err)
+}
+
+func (w *WebServer) uploadLog(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+
+	uploadStream, err := w.scheduler.GetUploadLogWriter(c.Request.Context(), id, c.Param("name"))
+	w.receiveUpload(c, uploadStream, err)
+}
+
+func (w *WebServer) getJobLogs(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+
+	logs, err := w.scheduler.GetJobLogs(c.Request.Context(), id)
+	if err != nil {
+		webError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+func (w *WebServer) getJobLog(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+
+	logPath, err := w.scheduler.GetJobLogPath(c.Request.Context(), id, c.Param("name"))
+	if errors.Is(err, scheduler.ErrorStreamNotAllowed) {
+		webError(c, err, 403)
+		return
+	} else if errors.Is(err, scheduler.ErrorJobNotFound) {
+		webError(c, err, 404)
+		return
+	} else if webError(c, err, 500) {
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.File(logPath)
+}
+
+func (w *WebServer) receiveUpload(c *gin.Context, uploadStream *scheduler.UploadJobStream, err error) {
 	if errors.Is(err, scheduler.ErrorStreamNotAllowed) {
 		webError(c, err, 403)
 		return
@@ -218,6 +326,127 @@ loop:
 	c.Status(http.StatusCreated)
 }
 
+// uploadSession starts or resumes the chunked upload of the job result, the
+// body announces the size of the file.
+func (w *WebServer) uploadSession(c *gin.Context) {
+	uploadStream, ok := w.uploadSessionStream(c)
+	if !ok {
+		return
+	}
+	defer uploadStream.Close(false)
+
+	var request model.UploadSession
+	if err := c.ShouldBindJSON(&request); err != nil {
+		webError(c, err, http.StatusBadRequest)
+		return
+	}
+	session, err := uploadStream.StartSession(request.Size)
+	if uploadSessionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// getUploadSession returns the offset the worker resumes the upload from.
+func (w *WebServer) getUploadSession(c *gin.Context) {
+	uploadStream, ok := w.uploadSessionStream(c)
+	if !ok {
+		return
+	}
+	defer uploadStream.Close(false)
+
+	session, err := uploadStream.Session()
+	if uploadSessionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// uploadChunk stores the chunk of the Content-Range header, verified against
+// the sha256 of the checksum header.
+func (w *WebServer) uploadChunk(c *gin.Context) {
+	var start, end, size int64
+	if _, err := fmt.Sscanf(c.GetHeader("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err != nil || end < start {
+		webError(c, fmt.Errorf("invalid Content-Range %q", c.GetHeader("Content-Range")), http.StatusBadRequest)
+		return
+	}
+	checksum := c.GetHeader("checksum")
+	if checksum == "" {
+		webError(c, fmt.Errorf("checksum is mandatory in the headers"), 403)
+		return
+	}
+	uploadStream, ok := w.uploadSessionStream(c)
+	if !ok {
+		return
+	}
+	defer uploadStream.Close(false)
+
+	session, err := uploadStream.Session()
+	if uploadSessionError(c, err) {
+		return
+	}
+	if session.Size != size {
+		webError(c, fmt.Errorf("%w: chunk of a %d bytes file, the upload is %d bytes", scheduler.ErrorUploadOffset, size, session.Size), http.StatusConflict)
+		return
+	}
+	session, err = uploadStream.WriteChunk(start, end-start+1, checksum, c.Request.Body)
+	if uploadSessionError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// finalizeUpload checks the sha256 of the whole upload and moves it to the
+// destination of the job.
+func (w *WebServer) finalizeUpload(c *gin.Context) {
+	checksum := c.GetHeader("checksum")
+	if checksum == "" {
+		webError(c, fmt.Errorf("checksum is mandatory in the headers"), 403)
+		return
+	}
+	uploadStream, ok := w.uploadSessionStream(c)
+	if !ok {
+		return
+	}
+	defer uploadStream.Close(false)
+
+	if uploadSessionError(c, uploadStream.Finalize(checksum)) {
+		return
+	}
+	c.Status(http.StatusCreated)
+}
+
+func (w *WebServer) uploadSessionStream(c *gin.Context) (*scheduler.UploadJobStream, bool) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return nil, false
+	}
+
+	uploadStream, err := w.scheduler.GetUploadSessionWriter(c.Request.Context(), id)
+	if uploadSessionError(c, err) {
+		return nil, false
+	}
+	return uploadStream, true
+}
+
+func uploadSessionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, scheduler.ErrorStreamNotAllowed):
+		return webError(c, err, 403)
+	case errors.Is(err, scheduler.ErrorJobNotFound):
+		return webError(c, err, 404)
+	case errors.Is(err, scheduler.ErrorUploadOffset):
+		return webError(c, err, http.StatusConflict)
+	case errors.Is(err, scheduler.ErrorUploadChecksum):
+		return webError(c, err, http.StatusBadRequest)
+	default:
+		return webError(c, err, 500)
+	}
+}
+
 func (w *WebServer) download(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -226,7 +455,56 @@ func (w *WebServer) download(c *gin.Context) {
 	}
 
 	downloadStream, err := w.scheduler.GetDownloadJobWriter(c.Request.Context(), id)
-	if errors.Is(err, scheduler.ErrorStreamNotAllowed) {
+	w.sendDownload(c, downloadStream, err)
+}
+
+func (w *WebServer) downloadSegment(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		webError(c, fmt.Errorf("invalid segment index %s", c.Param("index")), 400)
+		return
+	}
+
+	downloadStream, err := w.scheduler.GetSegmentDownloadJobWriter(c.Request.Context(), id, index)
+	w.sendDownload(c, downloadStream, err)
+}
+
+func (w *WebServer) downloadSample(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		webError(c, fmt.Errorf("job ID parameter not found"), 404)
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		webError(c, fmt.Errorf("invalid clip index %s", c.Param("index")), 400)
+		return
+	}
+
+	downloadStream, err := w.scheduler.GetSampleDownloadJobWriter(c.Request.Context(), id, index)
+	w.sendDownload(c, downloadStream, err)
+}
+
+// sourcePending answers that the source is still being hashed or cut, the
+// workers ask again later.
+func sourcePending(c *gin.Context, err error) bool {
+	if !errors.Is(err, scheduler.ErrorSourcePending) {
+		return false
+	}
+	c.Header("Retry-After", "10")
+	webError(c, err, http.StatusServiceUnavailable)
+	return true
+}
+
+func (w *WebServer) sendDownload(c *gin.Context, downloadStream *scheduler.DownloadJobStream, err error) {
+	if sourcePending(c, err) {
+		return
+	} else if errors.Is(err, scheduler.ErrorStreamNotAllowed) {
 		webError(c, err, 403)
 		return
 	} else if errors.Is(err, scheduler.ErrorJobNotFound) {
@@ -235,10 +513,25 @@ func (w *WebServer) download(c *gin.Context) {
 	} else if webError(c, err, 500) {
 		return
 	}
-	defer downloadStream.Close(true)
+	// only a whole file read to the end pushes its checksum
+	completed := false
+	defer func() {
+		downloadStream.Close(completed)
+	}()
 
-	c.Header("Content-Length", strconv.FormatInt(downloadStream.Size(), 10))
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", url.QueryEscape(downloadStream.Name())))
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", downloadStream.ETag())
+	c.Header("Last-Modified", downloadStream.ModTime.UTC().Format(http.TimeFormat))
+
+	// workers resuming a download ask for the rest of the file, ServeContent
+	// answers the Range and If-Range headers
+	if c.GetHeader("Range") != "" {
+		http.ServeContent(c.Writer, c.Request, downloadStream.Name(), downloadStream.ModTime, downloadStream)
+		return
+	}
+
+	c.Header("Content-Length", strconv.FormatInt(downloadStream.Size(), 10))
 	c.Status(http.StatusOK)
 
 	b := make([]byte, constants.IOBufferSize)
@@ -251,6 +544,7 @@ loop:
 			readedBytes, err := downloadStream.Read(b)
 			c.Writer.Write(b[:readedBytes])
 			if err == io.EOF {
+				completed = true
 				break loop
 			}
 		}
@@ -267,6 +561,27 @@ func (w *WebServer) getWorkers(c *gin.Context) {
 	c.JSON(http.StatusOK, workers)
 }
 
+// workerAction sends a drain, pause or resume to the worker of the name param.
+func (w *WebServer) workerAction(action model.JobAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if name == "" {
+			webError(c, fmt.Errorf("worker name parameter not found"), 404)
+			return
+		}
+
+		err := w.scheduler.SendWorkerAction(w.ctx, name, action)
+		if errors.Is(err, repository.ErrElementNotFound) {
+			webError(c, err, 404)
+			return
+		} else if webError(c, err, http.StatusInternalServerError) {
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
 func (w *WebServer) checksum(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -275,7 +590,7 @@ func (w *WebServer) checksum(c *gin.Context) {
 	}
 
 	checksum, err := w.scheduler.GetChecksum(c.Request.Context(), id)
-	if webError(c, err, 404) {
+	if sourcePending(c, err) || webError(c, err, 404) {
 		return
 	}
 	c.Header("Content-Length", strconv.Itoa(len(checksum)))
@@ -331,14 +646,32 @@ func NewWebServer(config WebServerConfig, scheduler scheduler.Scheduler, w *watc
 	api.POST("/job/", webServer.addJob)
 	api.GET("/job/:id", webServer.getJobByID)
 	api.DELETE("/job/:id", webServer.deleteJob)
+	api.POST("/job/:id/cancel", webServer.cancelJob)
+	api.POST("/job/:id/pause", webServer.pauseJob)
+	api.POST("/job/:id/resume", webServer.resumeJob)
 	api.PATCH("/job/:id/priority", webServer.updateJobPriority)
+	api.GET("/job/:id/sample/:index", webServer.downloadSample)
+	api.GET("/job/:id/logs", webServer.getJobLogs)
+	api.GET("/job/:id/logs/:name", webServer.getJobLog)
 
 	workerAPI := r.Group("/api/v1/job")
 	workerAPI.GET("/:id/download", webServer.download)
+	workerAPI.GET("/:id/segment/:index", webServer.downloadSegment)
 	workerAPI.GET("/:id/checksum", webServer.checksum)
 	workerAPI.POST("/:id/upload", webServer.upload)
+	workerAPI.POST("/:id/upload/session", webServer.uploadSession)
+	workerAPI.GET("/:id/upload/session", webServer.getUploadSession)
+	workerAPI.PUT("/:id/upload/session", webServer.uploadChunk)
+	workerAPI.POST("/:id/upload/session/finalize", webServer.finalizeUpload)
+	workerAPI.POST("/:id/upload/sidecar/:name", webServer.uploadSidecar)
+	workerAPI.POST("/:id/upload/log/:name", webServer.uploadLog)
 
 	api.GET("/workers/", webServer.getWorkers)
+	api.POST("/workers/:name/drain", webServer.workerAction(model.DrainWorkerAction))
+	api.POST("/workers/:name/pause", webServer.workerAction(model.PauseWorkerAction))
+	api.POST("/workers/:name/resume", webServer.workerAction(model.ResumeWorkerAction))
+
+	api.GET("/profiles/", webServer.getProfiles)
 
 	api.GET("/watcher/status", webServer.getWatcherStatus)
 	api.GET("/watcher/detections", webServer.getWatcherDetections)
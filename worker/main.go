@@ -41,7 +41,7 @@ func init() {
 	pflag.String("worker.temporalPath", os.TempDir(), "Path used for temporal data")
 	pflag.String("worker.name", hostname, "Worker Name used for statistics")
 	pflag.Int("worker.threads", runtime.NumCPU(), "Worker Threads")
-	pflag.StringSlice("worker.acceptedJobs", []string{"encode"}, "type of jobs this Worker will accept: encode,pgstosrt")
+	pflag.StringSlice("worker.acceptedJobs", []string{"encode", "encodesegment", "joinsegments"}, "type of jobs this Worker will accept: encode,encodesegment,joinsegments,pgstosrt")
 	pflag.Int("worker.maxPrefetchJobs", 1, "Maximum number of jobs to prefetch")
 	pflag.Int("worker.encodeJobs", 1, "Worker Encode Jobs in parallel")
 	pflag.Int("worker.pgsJobs", 0, "Worker PGS Jobs in parallel")
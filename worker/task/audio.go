@@ -0,0 +1,130 @@
+package task
+
+import (
+	"gearr/model"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var commentaryRegex = regexp.MustCompile(`(?i)commentary`)
+
+var losslessAudioCodecs = []string{"flac", "truehd", "mlp", "alac"}
+
+func (A *Audio) isLossless() bool {
+	codecName := strings.ToLower(A.Codec)
+	if strings.HasPrefix(codecName, "pcm_") {
+		return true
+	}
+	for _, lossless := range losslessAudioCodecs {
+		if codecName == lossless {
+			return true
+		}
+	}
+	return codecName == "dts" && strings.Contains(A.Profile, "MA")
+}
+
+func (A *Audio) isAAC() bool {
+	return strings.ToLower(A.Codec) == "aac"
+}
+
+func (A *Audio) betterThan(other *Audio) bool {
+	return A.ChannelsNumber > other.ChannelsNumber || (A.ChannelsNumber == other.ChannelsNumber && A.Bitrate > other.Bitrate)
+}
+
+// selectAudioStreams applies the audio policy to the source audio streams. It
+// keeps the best track per language, commentary tracks when requested and
+// sorts them by preferred language. When the language filter leaves no track
+// the best tracks of every language are kept, a video without audio is never
+// the expected result.
+func selectAudioStreams(audios []*Audio, policy model.AudioPolicy) []*Audio {
+	originalLanguage := findOriginalLanguage(audios)
+
+	bestPerLanguage := make(map[string]*Audio)
+	var commentaries []*Audio
+	for _, audio := range audios {
+		if audio.Commentary {
+			commentaries = append(commentaries, audio)
+			continue
+		}
+		best := bestPerLanguage[audio.Language]
+		if best == nil || audio.betterThan(best) {
+			bestPerLanguage[audio.Language] = audio
+		}
+	}
+
+	allowed := func(audio *Audio) bool {
+		if len(policy.Languages) == 0 || containsLanguage(policy.Languages, audio.Language) {
+			return true
+		}
+		return policy.KeepOriginal && audio.Language == originalLanguage
+	}
+
+	var selected []*Audio
+	for _, audio := range bestPerLanguage {
+		if allowed(audio) {
+			selected = append(selected, audio)
+		}
+	}
+	if len(selected) == 0 {
+		for _, audio := range bestPerLanguage {
+			selected = append(selected, audio)
+		}
+	}
+	sortAudios(selected, policy.PreferredLanguages)
+
+	var selectedCommentaries []*Audio
+	for _, audio := range commentaries {
+		if (policy.KeepCommentary && allowed(audio)) || len(selected) == 0 {
+			selectedCommentaries = append(selectedCommentaries, audio)
+		}
+	}
+	sortAudios(selectedCommentaries, policy.PreferredLanguages)
+
+	return append(selected, selectedCommentaries...)
+}
+
+// findOriginalLanguage returns the language of the track flagged as original,
+// falling back to the first audio track of the source.
+func findOriginalLanguage(audios []*Audio) string {
+	var first *Audio
+	for _, audio := range audios {
+		if audio.Original {
+			return audio.Language
+		}
+		if first == nil || audio.Id < first.Id {
+			first = audio
+		}
+	}
+	if first == nil {
+		return ""
+	}
+	return first.Language
+}
+
+func sortAudios(audios []*Audio, preferredLanguages []string) {
+	priority := func(audio *Audio) int {
+		for index, language := range preferredLanguages {
+			if strings.EqualFold(language, audio.Language) {
+				return index
+			}
+		}
+		return len(preferredLanguages)
+	}
+	sort.SliceStable(audios, func(i, j int) bool {
+		pi, pj := priority(audios[i]), priority(audios[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return audios[i].Id < audios[j].Id
+	})
+}
+
+func containsLanguage(languages []string, language string) bool {
+	for _, l := range languages {
+		if strings.EqualFold(l, language) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package task
+
+import (
+	"gearr/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testAudios() []*Audio {
+	return []*Audio{
+		{Id: 1, Language: "jpn", ChannelsNumber: 2, Bitrate: 192000, Codec: "aac"},
+		{Id: 2, Language: "eng", ChannelsNumber: 6, Bitrate: 640000, Codec: "ac3"},
+		{Id: 3, Language: "eng", ChannelsNumber: 8, Bitrate: 0, Codec: "truehd"},
+		{Id: 4, Language: "spa", ChannelsNumber: 2, Bitrate: 192000, Codec: "ac3"},
+		{Id: 5, Language: "eng", ChannelsNumber: 2, Bitrate: 128000, Codec: "aac", Commentary: true, Title: "Director's Commentary"},
+	}
+}
+
+func audioIds(audios []*Audio) []uint8 {
+	var ids []uint8
+	for _, audio := range audios {
+		ids = append(ids, audio.Id)
+	}
+	return ids
+}
+
+func TestSelectAudioStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   model.AudioPolicy
+		expected []uint8
+	}{
+		{
+			name:     "zero policy keeps best track per language",
+			policy:   model.AudioPolicy{},
+			expected: []uint8{1, 3, 4},
+		},
+		{
+			name:     "allowed languages",
+			policy:   model.AudioPolicy{Languages: []string{"eng"}},
+			expected: []uint8{3},
+		},
+		{
+			name:     "keep original language",
+			policy:   model.AudioPolicy{Languages: []string{"eng"}, KeepOriginal: true},
+			expected: []uint8{1, 3},
+		},
+		{
+			name:     "preferred languages first",
+			policy:   model.AudioPolicy{PreferredLanguages: []string{"spa", "eng"}},
+			expected: []uint8{4, 3, 1},
+		},
+		{
+			name:     "keep commentary",
+			policy:   model.AudioPolicy{Languages: []string{"eng"}, KeepCommentary: true},
+			expected: []uint8{3, 5},
+		},
+		{
+			name:     "no allowed language keeps everything",
+			policy:   model.AudioPolicy{Languages: []string{"fre"}},
+			expected: []uint8{1, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := audioIds(selectAudioStreams(testAudios(), tt.policy))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("selectAudioStreams() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindOriginalLanguage(t *testing.T) {
+	audios := testAudios()
+	if language := findOriginalLanguage(audios); language != "jpn" {
+		t.Errorf("findOriginalLanguage() = %s, want jpn", language)
+	}
+	audios[3].Original = true
+	if language := findOriginalLanguage(audios); language != "spa" {
+		t.Errorf("findOriginalLanguage() = %s, want spa", language)
+	}
+}
+
+func TestAudioIsLossless(t *testing.T) {
+	tests := []struct {
+		audio    Audio
+		expected bool
+	}{
+		{Audio{Codec: "truehd"}, true},
+		{Audio{Codec: "flac"}, true},
+		{Audio{Codec: "pcm_s24le"}, true},
+		{Audio{Codec: "dts", Profile: "DTS-HD MA"}, true},
+		{Audio{Codec: "dts", Profile: "DTS"}, false},
+		{Audio{Codec: "ac3"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.audio.Codec+tt.audio.Profile, func(t *testing.T) {
+			if result := tt.audio.isLossless(); result != tt.expected {
+				t.Errorf("isLossless() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFFMPEGGenerator_setAudioFiltersPolicy(t *testing.T) {
+	profile := model.DefaultEncodingProfile()
+	profile.Audio = model.AudioPolicy{
+		PreferredLanguages:  []string{"eng"},
+		PassthroughLossless: true,
+		StereoDownmix:       true,
+	}
+	container := &ContainerData{
+		Audios: []*Audio{
+			{Id: 3, Language: "eng", ChannelsNumber: 8, ChannelLayour: "7.1", Codec: "truehd"},
+			{Id: 1, Language: "jpn", ChannelsNumber: 2, ChannelLayour: "stereo", Codec: "ac3"},
+		},
+	}
+
+	ffmpeg := NewFFMPEGGenerator(&profile)
+	ffmpeg.setAudioFilters(container)
+	if len(ffmpeg.AudioFilter) != 3 {
+		t.Fatalf("len(AudioFilter) = %d, want 3: %v", len(ffmpeg.AudioFilter), ffmpeg.AudioFilter)
+	}
+
+	expected := [][]string{
+		{"-map 0:3", "-disposition:a:0 default", "-c:a:0 copy"},
+		{"-map 0:3", "-ac:a:1 2", "-c:a:1 libfdk_aac", "(stereo)"},
+		{"-map 0:1", "-disposition:a:2 0", "-c:a:2 libfdk_aac"},
+	}
+	for i, contains := range expected {
+		for _, c := range contains {
+			if !strings.Contains(joinArguments(ffmpeg.AudioFilter[i]), c) {
+				t.Errorf("AudioFilter[%d] = %q does not contain %q", i, ffmpeg.AudioFilter[i], c)
+			}
+		}
+	}
+}
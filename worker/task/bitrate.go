@@ -0,0 +1,47 @@
+package task
+
+import (
+	"gearr/model"
+	"sort"
+)
+
+// passLogName is the prefix of the statistics written by the first pass of a
+// two-pass encode, inside the job work dir.
+const passLogName = "ffmpeg2pass"
+
+// targetBitrate picks the average video bitrate, in kbps, of a two-pass encode
+// from the source size and duration and the width of the output.
+func targetBitrate(policy model.BitratePolicy, sourceSize int64, duration float64, width int) int {
+	kbps := 0
+	if policy.SourcePercent > 0 && duration > 0 {
+		sourceKbps := float64(sourceSize) * 8 / duration / 1000
+		kbps = int(sourceKbps * float64(policy.SourcePercent) / 100)
+	}
+	if len(policy.Resolutions) > 0 {
+		resolutions := make([]model.ResolutionBitrate, len(policy.Resolutions))
+		copy(resolutions, policy.Resolutions)
+		sort.Slice(resolutions, func(i, j int) bool {
+			return resolutions[i].MaxWidth < resolutions[j].MaxWidth
+		})
+		resolutionKbps := resolutions[len(resolutions)-1].Kbps
+		for _, resolution := range resolutions {
+			if width <= resolution.MaxWidth {
+				resolutionKbps = resolution.Kbps
+				break
+			}
+		}
+		if kbps == 0 || resolutionKbps < kbps {
+			kbps = resolutionKbps
+		}
+	}
+	return kbps
+}
+
+// outputWidth is the width of the encoded video, the source one limited by the
+// profile scale.
+func outputWidth(maxWidth int, sourceWidth int) int {
+	if maxWidth > 0 && sourceWidth > maxWidth {
+		return maxWidth
+	}
+	return sourceWidth
+}
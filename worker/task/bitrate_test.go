@@ -0,0 +1,54 @@
+package task
+
+import (
+	"gearr/model"
+	"testing"
+)
+
+func TestTargetBitrate(t *testing.T) {
+	resolutions := []model.ResolutionBitrate{{MaxWidth: 3840, Kbps: 12000}, {MaxWidth: 1280, Kbps: 2500}, {MaxWidth: 1920, Kbps: 5000}}
+
+	tests := []struct {
+		name       string
+		policy     model.BitratePolicy
+		sourceSize int64
+		duration   float64
+		width      int
+		want       int
+	}{
+		// 1 GB over 1000 seconds is 8000 kbps
+		{"source percent", model.BitratePolicy{SourcePercent: 50}, 1000000000, 1000, 1920, 4000},
+		{"resolution table", model.BitratePolicy{Resolutions: resolutions}, 1000000000, 1000, 1920, 5000},
+		{"smaller resolution", model.BitratePolicy{Resolutions: resolutions}, 1000000000, 1000, 720, 2500},
+		{"wider than every entry", model.BitratePolicy{Resolutions: resolutions}, 1000000000, 1000, 7680, 12000},
+		{"lowest of both", model.BitratePolicy{SourcePercent: 40, Resolutions: resolutions}, 1000000000, 1000, 1920, 3200},
+		{"resolution caps percent", model.BitratePolicy{SourcePercent: 90, Resolutions: resolutions}, 1000000000, 1000, 1280, 2500},
+		{"unknown duration", model.BitratePolicy{SourcePercent: 50}, 1000000000, 0, 1920, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := targetBitrate(tt.policy, tt.sourceSize, tt.duration, tt.width); got != tt.want {
+				t.Errorf("targetBitrate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputWidth(t *testing.T) {
+	tests := []struct {
+		maxWidth    int
+		sourceWidth int
+		want        int
+	}{
+		{1920, 3840, 1920},
+		{1920, 1280, 1280},
+		{0, 3840, 3840},
+	}
+
+	for _, tt := range tests {
+		if got := outputWidth(tt.maxWidth, tt.sourceWidth); got != tt.want {
+			t.Errorf("outputWidth(%d, %d) = %d, want %d", tt.maxWidth, tt.sourceWidth, got, tt.want)
+		}
+	}
+}
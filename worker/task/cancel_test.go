@@ -0,0 +1,87 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"gearr/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type eventRecorder struct {
+	mockManager
+	mu     sync.Mutex
+	events []model.TaskEvent
+}
+
+func (e *eventRecorder) EventNotification(event model.TaskEvent) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	return nil
+}
+
+func TestEncodeWorker_CancelJob(t *testing.T) {
+	// the source never ends, only the cancel stops the download
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Header().Set("Content-Disposition", "attachment; filename=movie.mkv")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	printer := NewConsoleWorkerPrinter()
+	recorder := &eventRecorder{}
+	worker := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir()}, "worker", printer)
+	worker.Manager = recorder
+	newJob := func() *model.WorkTaskEncode {
+		job := &model.WorkTaskEncode{
+			TaskEncode: &model.TaskEncode{Id: uuid.New(), DownloadURL: server.URL},
+			WorkDir:    t.TempDir(),
+		}
+		worker.startJob(job)
+		return job
+	}
+	canceled, other := newJob(), newJob()
+
+	downloadErr := make(chan error, 1)
+	go func() {
+		downloadErr <- worker.downloadFile(canceled, printer.AddTask(canceled.TaskEncode.Id.String(), DownloadJobStepType))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if worker.CancelJob(uuid.New()) {
+		t.Error("CancelJob() of an unknown job = true, want false")
+	}
+	if !worker.CancelJob(canceled.TaskEncode.Id) {
+		t.Fatal("CancelJob() = false, want true")
+	}
+	var err error
+	select {
+	case err = <-downloadErr:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download not stopped by the cancel")
+	}
+	if other.Context().Err() != nil {
+		t.Error("cancel stopped another job of the worker")
+	}
+
+	worker.errorJob(canceled, printer.AddTask(canceled.TaskEncode.Id.String(), DownloadJobStepType), err)
+	if last := recorder.events[len(recorder.events)-1]; last.Status != model.CanceledNotificationStatus {
+		t.Errorf("last event = %s, want %s", last.Status, model.CanceledNotificationStatus)
+	}
+	if _, err := os.Stat(canceled.WorkDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("work directory of the canceled job not cleaned")
+	}
+	if _, ok := worker.jobs.Get(canceled.TaskEncode.Id.String()); ok {
+		t.Errorf("canceled job still registered on the worker")
+	}
+}
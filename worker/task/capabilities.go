@@ -0,0 +1,99 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"gearr/helper"
+	"gearr/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+const encodersListTimeout = 30 * time.Second
+
+// detectEncoders lists the encoders and the filters available in the ffmpeg
+// of the worker, profiles require both by name.
+func detectEncoders(ctx context.Context) ([]string, error) {
+	output, err := listFFmpeg(ctx, "-encoders")
+	if err != nil {
+		return nil, err
+	}
+	encoders := parseEncoders(output)
+	if output, err = listFFmpeg(ctx, "-filters"); err != nil {
+		return nil, err
+	}
+	return append(encoders, parseFilters(output)...), nil
+}
+
+// listFFmpeg returns the output of an ffmpeg listing option.
+func listFFmpeg(ctx context.Context, option string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, encodersListTimeout)
+	defer cancel()
+
+	listCommand := exec.CommandContext(ctx, helper.GetFFmpegPath(), "-hide_banner", option)
+	if runtime.GOOS == "linux" {
+		listCommand.Env = append(os.Environ(), fmt.Sprintf("LD_LIBRARY_PATH=%s", filepath.Dir(helper.GetFFmpegPath())))
+	}
+	output, err := listCommand.Output()
+	return string(output), err
+}
+
+// parseEncoders reads the encoder names from the ffmpeg -encoders output, one
+// per line after the legend closed by a dashed line:
+//
+//	V....D libx265              libx265 H.265 / HEVC (codec hevc)
+func parseEncoders(output string) []string {
+	encoders := []string{}
+	legend := true
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if legend {
+			legend = len(fields) == 0 || !strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		encoders = append(encoders, fields[1])
+	}
+	return encoders
+}
+
+// parseFilters reads the filter names from the ffmpeg -filters output, the
+// lines with their inputs and outputs after the legend:
+//
+//	... libvmaf           VV->V      Calculate the VMAF between two video streams.
+func parseFilters(output string) []string {
+	filters := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.Contains(fields[2], "->") {
+			continue
+		}
+		filters = append(filters, fields[1])
+	}
+	return filters
+}
+
+// capabilities returns what the worker registers with its pings.
+func (p *PostgresClient) capabilities() *model.WorkerCapabilities {
+	freeSpace, err := freeSpace(p.workerConfig.TemporalPath)
+	if err != nil {
+		helper.Debugf("failed to get free space of %s: %v", p.workerConfig.TemporalPath, err)
+	}
+	return &model.WorkerCapabilities{
+		Version:       helper.Version(),
+		Threads:       p.workerConfig.Threads,
+		AcceptedJobs:  p.workerConfig.Jobs.Accepted(slices.Concat(model.EncodeJobTypes, []model.JobType{model.PGSToSrtJobType})),
+		EncodeJobs:    p.workerConfig.EncodeJobs,
+		PGSJobs:       p.workerConfig.PgsJobs,
+		Encoders:      p.encoders,
+		FreeTempSpace: freeSpace,
+		Labels:        p.workerConfig.Labels,
+	}
+}
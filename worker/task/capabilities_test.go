@@ -0,0 +1,82 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+
+	"gearr/model"
+)
+
+func TestParseEncoders(t *testing.T) {
+	output := `Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ .F.... = Frame-level multithreading
+ ------
+ V....D libx265              libx265 H.265 / HEVC (codec hevc)
+ V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)
+ A....D libfdk_aac           Fraunhofer FDK AAC (codec aac)
+ S..... mov_text             3GPP Timed Text subtitle
+`
+	want := []string{"libx265", "libsvtav1", "libfdk_aac", "mov_text"}
+	if got := parseEncoders(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEncoders() = %v, want %v", got, want)
+	}
+	if got := parseEncoders(""); len(got) != 0 {
+		t.Errorf("parseEncoders(empty) = %v, want none", got)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	output := `Filters:
+  T.. = Timeline support
+  .S. = Slice threading
+  ..C = Command support
+  A = Audio input/output
+  V = Video input/output
+  N = Dynamic number and/or type of input/output
+  | = Source or sink filter
+ ... abench            A->A       Benchmark part of a filtergraph.
+ TS. libvmaf           VV->V      Calculate the VMAF between two video streams.
+ T.C cropdetect        V->V       Auto-detect crop size.
+ ... nullsink          V->|       Do absolutely nothing with the input video.
+`
+	want := []string{"abench", "libvmaf", "cropdetect", "nullsink"}
+	if got := parseFilters(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFilters() = %v, want %v", got, want)
+	}
+	if got := parseFilters(""); len(got) != 0 {
+		t.Errorf("parseFilters(empty) = %v, want none", got)
+	}
+}
+
+func TestPostgresClient_Capabilities(t *testing.T) {
+	client := &PostgresClient{
+		workerConfig: Config{
+			TemporalPath: t.TempDir(),
+			Threads:      8,
+			Jobs:         AcceptedJobs{model.EncodeJobType},
+			EncodeJobs:   2,
+			Labels:       map[string]string{"pool": "big"},
+		},
+		encoders: []string{"libx265", "libfdk_aac"},
+	}
+
+	capabilities := client.capabilities()
+	if capabilities.Threads != 8 || capabilities.EncodeJobs != 2 {
+		t.Errorf("capabilities() = %+v, want 8 threads and 2 encode jobs", capabilities)
+	}
+	if !reflect.DeepEqual(capabilities.AcceptedJobs, model.EncodeJobTypes) {
+		t.Errorf("AcceptedJobs = %v, want %v", capabilities.AcceptedJobs, model.EncodeJobTypes)
+	}
+	if !reflect.DeepEqual(capabilities.Encoders, client.encoders) {
+		t.Errorf("Encoders = %v, want %v", capabilities.Encoders, client.encoders)
+	}
+	if capabilities.Labels["pool"] != "big" {
+		t.Errorf("Labels = %v, want pool=big", capabilities.Labels)
+	}
+	if capabilities.FreeTempSpace == 0 {
+		t.Error("FreeTempSpace = 0, want the free space of the temporal path")
+	}
+}
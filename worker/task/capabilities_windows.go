@@ -0,0 +1,12 @@
+//go:build windows
+
+package task
+
+import "errors"
+
+var errFreeSpaceNotSupported = errors.New("free space is not reported on windows")
+
+// Windows workers report no free space.
+func freeSpace(path string) (uint64, error) {
+	return 0, errFreeSpaceNotSupported
+}
@@ -12,6 +12,9 @@ import (
 
 type AcceptedJobs []model.JobType
 
+// IsAccepted reports whether the worker takes jobs of the given type. Workers
+// accepting encode jobs also take the segments and joins of chunked jobs, so
+// configurations older than chunking do not leave them queued.
 func (A AcceptedJobs) IsAccepted(jobType model.JobType) bool {
 	helper.Debugf("accepted jobs: %+v", A)
 
@@ -20,10 +23,24 @@ func (A AcceptedJobs) IsAccepted(jobType model.JobType) bool {
 		if j == jobType {
 			return true
 		}
+		if j == model.EncodeJobType && (jobType == model.EncodeSegmentJobType || jobType == model.JoinSegmentsJobType) {
+			return true
+		}
 	}
 	return false
 }
 
+// Accepted returns the given job types accepted by the worker.
+func (A AcceptedJobs) Accepted(jobTypes []model.JobType) []model.JobType {
+	var accepted []model.JobType
+	for _, jobType := range jobTypes {
+		if A.IsAccepted(jobType) {
+			accepted = append(accepted, jobType)
+		}
+	}
+	return accepted
+}
+
 type TimeHourMinute struct {
 	Hour   int
 	Minute int
@@ -55,20 +72,27 @@ func (t *TimeHourMinute) Set(value string) error {
 }
 
 type Config struct {
-	UpdateMode        bool           `mapstructure:"updateMode"`
-	TemporalPath      string         `mapstructure:"temporalPath"`
-	Name              string         `mapstructure:"name"`
-	Threads           int            `mapstructure:"threads"`
-	MaxPrefetchJobs   int            `mapstructure:"maxPrefetchJobs"`
-	Jobs              AcceptedJobs   `mapstructure:"acceptedJobs"`
-	EncodeJobs        int            `mapstructure:"encodeJobs"`
-	PgsJobs           int            `mapstructure:"pgsJobs"`
-	StartAfter        TimeHourMinute `mapstructure:"startAfter"`
-	StopAfter         TimeHourMinute `mapstructure:"stopAfter"`
+	UpdateMode      bool           `mapstructure:"updateMode"`
+	TemporalPath    string         `mapstructure:"temporalPath"`
+	Name            string         `mapstructure:"name"`
+	Threads         int            `mapstructure:"threads"`
+	MaxPrefetchJobs int            `mapstructure:"maxPrefetchJobs"`
+	Jobs            AcceptedJobs   `mapstructure:"acceptedJobs"`
+	EncodeJobs      int            `mapstructure:"encodeJobs"`
+	PgsJobs         int            `mapstructure:"pgsJobs"`
+	StartAfter      TimeHourMinute `mapstructure:"startAfter"`
+	StopAfter       TimeHourMinute `mapstructure:"stopAfter"`
+	// Paused starts the worker paused, it takes no jobs until it is resumed
+	// from the API.
 	Paused            bool
 	PGSTOSrtDLLPath   string `mapstructure:"pgsToSrtDLLPath"`
 	TesseractDataPath string `mapstructure:"tesseractDataPath"`
 	DotnetPath        string `mapstructure:"dotnetPath"`
+	// PathMappings enable the shared storage mode, sources and results under a
+	// mapped directory are read and written in place instead of over HTTP.
+	PathMappings []PathMapping `mapstructure:"pathMappings"`
+	// Labels are free-form key values registered with the worker capabilities.
+	Labels map[string]string `mapstructure:"labels"`
 }
 
 func (c Config) HaveSetPeriodTime() bool {
@@ -49,6 +49,24 @@ func TestAcceptedJobs_IsAccepted(t *testing.T) {
 			jobType:  model.PGSToSrtJobType,
 			expected: false,
 		},
+		{
+			name:     "encode accepts segments",
+			jobs:     AcceptedJobs{model.EncodeJobType},
+			jobType:  model.EncodeSegmentJobType,
+			expected: true,
+		},
+		{
+			name:     "encode accepts joins",
+			jobs:     AcceptedJobs{model.EncodeJobType},
+			jobType:  model.JoinSegmentsJobType,
+			expected: true,
+		},
+		{
+			name:     "segments only",
+			jobs:     AcceptedJobs{model.EncodeSegmentJobType},
+			jobType:  model.EncodeJobType,
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -261,3 +279,11 @@ func TestConfig_Fields(t *testing.T) {
 		t.Errorf("config.TemporalPath = %q, want %q", config.TemporalPath, "/tmp/gearr")
 	}
 }
+
+func TestAcceptedJobs_Accepted(t *testing.T) {
+	jobs := AcceptedJobs{model.EncodeSegmentJobType, model.PGSToSrtJobType}
+	accepted := jobs.Accepted(model.EncodeJobTypes)
+	if len(accepted) != 1 || accepted[0] != model.EncodeSegmentJobType {
+		t.Errorf("Accepted() = %v, want [%s]", accepted, model.EncodeSegmentJobType)
+	}
+}
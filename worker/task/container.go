@@ -0,0 +1,58 @@
+package task
+
+import (
+	"gearr/model"
+	"strings"
+)
+
+// mp4TextSubtitleCodecs are the text subtitles converted to mov_text, the only
+// subtitle format MP4 players read.
+var mp4TextSubtitleCodecs = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// outputContainer is the container of the encoded file. Chunk segments are
+// always mkv, they are only joined, while joins and sample clips follow the
+// profile.
+func outputContainer(task *model.TaskEncode) string {
+	if task.Segment != nil && !task.Sample {
+		return model.ContainerMKV
+	}
+	if task.Profile == nil {
+		return model.ContainerMKV
+	}
+	return task.Profile.OutputContainer()
+}
+
+func (S *Subtitle) isTextSubtitle() bool {
+	codecName := strings.ToLower(S.Format)
+	for _, textCodec := range mp4TextSubtitleCodecs {
+		if codecName == textCodec {
+			return true
+		}
+	}
+	return false
+}
+
+// containerSubtitles drops the subtitles the container can not hold. MP4 keeps
+// text subtitles and the PGS ones, converted to text by OCR.
+func containerSubtitles(subtitles []*Subtitle, container string) []*Subtitle {
+	if container != model.ContainerMP4 {
+		return subtitles
+	}
+	var supported []*Subtitle
+	for _, subtitle := range subtitles {
+		if subtitle.isTextSubtitle() || subtitle.isImageTypeSubtitle() {
+			supported = append(supported, subtitle)
+		}
+	}
+	return supported
+}
+
+// passthroughSupported reports whether the audio stream can be copied to the
+// container, MP4 does not take TrueHD nor PCM.
+func passthroughSupported(audio *Audio, container string) bool {
+	if container != model.ContainerMP4 {
+		return true
+	}
+	codecName := strings.ToLower(audio.Codec)
+	return codecName != "truehd" && codecName != "mlp" && !strings.HasPrefix(codecName, "pcm_")
+}
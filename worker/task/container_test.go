@@ -0,0 +1,77 @@
+package task
+
+import (
+	"gearr/model"
+	"strings"
+	"testing"
+)
+
+func TestOutputContainer(t *testing.T) {
+	mp4 := &model.EncodingProfile{Name: "mobile", VideoCodec: "libx264", AudioCodec: "aac", Container: "mp4"}
+	tests := []struct {
+		name string
+		task *model.TaskEncode
+		want string
+	}{
+		{"default profile", &model.TaskEncode{}, "mkv"},
+		{"mp4 profile", &model.TaskEncode{Profile: mp4}, "mp4"},
+		{"chunk segment", &model.TaskEncode{Profile: mp4, Segment: &model.Segment{Index: 1}}, "mkv"},
+		{"sample clip", &model.TaskEncode{Profile: mp4, Segment: &model.Segment{Index: 1}, Sample: true}, "mp4"},
+		{"join", &model.TaskEncode{Profile: mp4, SegmentURLs: []string{"http://server/0000.mkv"}}, "mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputContainer(tt.task); got != tt.want {
+				t.Errorf("outputContainer() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerSubtitles(t *testing.T) {
+	subtitles := []*Subtitle{
+		{Id: 2, Format: "subrip"},
+		{Id: 3, Format: "hdmv_pgs_subtitle"},
+		{Id: 4, Format: "dvd_subtitle"},
+		{Id: 5, Format: "ass"},
+	}
+
+	if got := containerSubtitles(subtitles, model.ContainerMKV); len(got) != 4 {
+		t.Errorf("containerSubtitles(mkv) kept %d subtitles, want 4", len(got))
+	}
+	got := containerSubtitles(subtitles, model.ContainerMP4)
+	if len(got) != 3 || got[0].Id != 2 || got[1].Id != 3 || got[2].Id != 5 {
+		t.Errorf("containerSubtitles(mp4) = %v, want the text and PGS subtitles", got)
+	}
+}
+
+func TestFFMPEGGenerator_mp4(t *testing.T) {
+	profile := model.DefaultEncodingProfile()
+	profile.Container = model.ContainerMP4
+	profile.Audio.PassthroughLossless = true
+	container := &ContainerData{
+		Video:    &Video{Id: 0},
+		Audios:   []*Audio{{Id: 1, Language: "eng", Codec: "truehd"}, {Id: 2, Language: "jpn", Codec: "flac"}},
+		Subtitle: []*Subtitle{{Id: 3, Format: "hdmv_pgs_subtitle"}, {Id: 4, Format: "subrip"}},
+	}
+
+	ffmpeg := NewFFMPEGGenerator(&profile)
+	ffmpeg.setInputFilters(container, "source.mkv", "/tmp")
+	ffmpeg.setVideoFilters(container)
+	ffmpeg.setAudioFilters(container)
+	ffmpeg.setSubtFilters(container)
+	ffmpeg.setMetadata(container)
+	arguments := joinArguments(ffmpeg.buildArguments(4, "encoded.mp4"))
+
+	for _, c := range []string{"-c:a:0 libfdk_aac", "-c:a:1 copy", "-map 1 -c:s:0 mov_text", "-map 0:4 -c:s:1 mov_text", "-movflags +faststart+use_metadata_tags -y encoded.mp4"} {
+		if !strings.Contains(arguments, c) {
+			t.Errorf("arguments %q do not contain %q", arguments, c)
+		}
+	}
+	for _, e := range []string{"0:t?", "-c:s:0 srt", "-c:s:1 copy"} {
+		if strings.Contains(arguments, e) {
+			t.Errorf("arguments %q should not contain %q", arguments, e)
+		}
+	}
+}
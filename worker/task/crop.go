@@ -0,0 +1,60 @@
+package task
+
+import (
+	"gearr/helper/detect"
+	"gearr/model"
+)
+
+// frameWidth is the width of the video once cropped.
+func (V *Video) frameWidth() int {
+	if V.Crop != nil {
+		return V.Crop.Width
+	}
+	return V.Width
+}
+
+// sourceFilters are the filters applied to the source frames before scaling
+// them: deinterlacing and cropping.
+func (V *Video) sourceFilters() []string {
+	var filters []string
+	if V.Deinterlace != "" {
+		filters = append(filters, V.Deinterlace)
+	}
+	if V.Crop != nil {
+		filters = append(filters, V.Crop.Filter())
+	}
+	return filters
+}
+
+// detectCrop looks for black bars in windows sampled over the whole source.
+// Segments take the rectangle the server detected on the whole source, and
+// joins copy the already cropped video.
+func (J *EncodeWorker) detectCrop(job *model.WorkTaskEncode, track *TaskTracks, container *ContainerData, duration float64) error {
+	profile := job.TaskEncode.Profile
+	if (profile != nil && profile.DisableCrop) || len(job.TaskEncode.SegmentURLs) > 0 || job.TaskEncode.Analysis != nil {
+		return nil
+	}
+
+	J.updateTaskStatus(job, model.CropDetectNotification, model.ProgressingNotificationStatus, "")
+	track.Message(string(model.CropDetectNotification))
+	var crops []*detect.Crop
+	for _, start := range detect.CropSamples(duration) {
+		output, err := J.analyzeVideo(job, detect.CropDetectArguments(job.SourceFilePath, int(container.Video.Id), start))
+		if err != nil {
+			J.updateTaskStatus(job, model.CropDetectNotification, model.FailedNotificationStatus, err.Error())
+			return err
+		}
+		if crop := detect.ParseCrop(output); crop != nil {
+			crops = append(crops, crop)
+		}
+	}
+
+	container.Video.Crop = detect.MergeCrops(crops, container.Video.Width, container.Video.Height)
+	message := ""
+	if container.Video.Crop != nil {
+		message = container.Video.Crop.Filter()
+		J.terminal.Log("[%s] cropping black bars with %s", job.TaskEncode.Id.String(), message)
+	}
+	J.updateTaskStatus(job, model.CropDetectNotification, model.CompletedNotificationStatus, message)
+	return nil
+}
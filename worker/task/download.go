@@ -0,0 +1,85 @@
+package task
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sources are downloaded to a partial file of the work dir next to the
+// validator of the served file, its ETag or Last-Modified. Retries and workers
+// restarted by resumeJobs ask for the rest of the file with Range, and If-Range
+// makes the server send the whole file again when the source changed.
+const (
+	partialDownloadExt = ".part"
+	validatorExt       = ".validator"
+)
+
+func partialDownloadPath(workDir string, id string) string {
+	return filepath.Join(workDir, id+partialDownloadExt)
+}
+
+// partialDownload returns the size and the validator of a partial download,
+// zero when there is nothing to resume.
+func partialDownload(partialPath string) (int64, string) {
+	validator, err := os.ReadFile(partialPath + validatorExt)
+	if err != nil || len(validator) == 0 {
+		return 0, ""
+	}
+	info, err := os.Stat(partialPath)
+	if err != nil {
+		return 0, ""
+	}
+	return info.Size(), string(validator)
+}
+
+// responseValidator is the validator sent back in If-Range, the strong ETag
+// of the file or else its modification date.
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && etag[0] == '"' {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// resumeRequest adds the Range headers to continue a partial download.
+func resumeRequest(req *http.Request, offset int64, validator string) {
+	if offset <= 0 || validator == "" {
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	req.Header.Set("If-Range", validator)
+}
+
+// hashPartialDownload feeds the bytes already downloaded to the checksum of
+// the whole file.
+func hashPartialDownload(partialPath string, offset int64, sha hash.Hash) error {
+	partialFile, err := os.Open(partialPath)
+	if err != nil {
+		return err
+	}
+	defer partialFile.Close()
+	_, err = io.CopyN(sha, partialFile, offset)
+	return err
+}
+
+// unsatisfiedRangeSize reads the size of the source from the Content-Range
+// of a 416 response, "bytes */<size>".
+func unsatisfiedRangeSize(resp *http.Response) (int64, bool) {
+	size, found := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	if !found {
+		return 0, false
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	return total, err == nil
+}
+
+func removePartialDownload(partialPath string) {
+	os.Remove(partialPath)
+	os.Remove(partialPath + validatorExt)
+}
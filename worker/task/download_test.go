@@ -0,0 +1,89 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gearr/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newDownloadServer(t *testing.T, content []byte, ranges *[]string) *httptest.Server {
+	sha := sha256.Sum256(content)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Disposition", "attachment; filename=movie.mkv")
+		w.Header().Set("ETag", `"source-v2"`)
+		http.ServeContent(w, r, "movie.mkv", time.Unix(1700000000, 0), bytes.NewReader(content))
+	})
+	mux.HandleFunc("/checksum", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hex.EncodeToString(sha[:])))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	tests := []struct {
+		name      string
+		partial   []byte
+		validator string
+		wantRange string
+	}{
+		{"new download", nil, "", ""},
+		{"resume partial download", content[:4321], `"source-v2"`, "bytes=4321-"},
+		{"partial download of a replaced source", []byte("stale bytes"), `"source-v1"`, "bytes=11-"},
+		{"complete download with a failed checksum", content, `"source-v2"`, "bytes=10000-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			server := newDownloadServer(t, content, &ranges)
+			job := &model.WorkTaskEncode{
+				TaskEncode: &model.TaskEncode{
+					Id:          uuid.New(),
+					DownloadURL: server.URL + "/download",
+					ChecksumURL: server.URL + "/checksum",
+				},
+				WorkDir: t.TempDir(),
+			}
+			partialPath := partialDownloadPath(job.WorkDir, job.TaskEncode.Id.String())
+			if tt.partial != nil {
+				os.WriteFile(partialPath, tt.partial, os.ModePerm)
+				os.WriteFile(partialPath+validatorExt, []byte(tt.validator), os.ModePerm)
+			}
+
+			printer := NewConsoleWorkerPrinter()
+			worker := &EncodeWorker{ctx: context.Background(), terminal: printer}
+			if err := worker.downloadFile(job, printer.AddTask(job.TaskEncode.Id.String(), DownloadJobStepType)); err != nil {
+				t.Fatalf("downloadFile() error = %v", err)
+			}
+
+			if len(ranges) != 1 || ranges[0] != tt.wantRange {
+				t.Errorf("requested ranges = %q, want %q", ranges, tt.wantRange)
+			}
+			if want := filepath.Join(job.WorkDir, job.TaskEncode.Id.String()+".mkv"); job.SourceFilePath != want {
+				t.Errorf("SourceFilePath = %s, want %s", job.SourceFilePath, want)
+			}
+			downloaded, _ := os.ReadFile(job.SourceFilePath)
+			if !bytes.Equal(downloaded, content) {
+				t.Errorf("downloaded %d bytes, want the %d bytes of the source", len(downloaded), len(content))
+			}
+			if _, err := os.Stat(partialPath + validatorExt); !os.IsNotExist(err) {
+				t.Errorf("validator of the partial download was not removed")
+			}
+		})
+	}
+}
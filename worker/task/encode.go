@@ -1,6 +1,7 @@
 package task
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -9,15 +10,17 @@ import (
 	"fmt"
 	"gearr/helper"
 	"gearr/helper/command"
+	"gearr/helper/concurrent"
+	"gearr/helper/detect"
 	"gearr/internal/constants"
 	"gearr/model"
 	"hash"
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -26,29 +29,24 @@ import (
 	"time"
 
 	"github.com/avast/retry-go/v5"
+	"github.com/google/uuid"
 	"gopkg.in/vansante/go-ffprobe.v2"
 )
 
 const RESET_LINE = "\r\033[K"
 
 const (
-	pgsConversionTimeout         = 90 * time.Minute
-	encodeProgressUpdateInterval = 10.0
-	durationToleranceSeconds     = 60
-	uploadRetryAttempts          = 17280
-	downloadRetryAttempts        = 180
-	checksumRetryAttempts        = 10
+	pgsConversionTimeout     = 90 * time.Minute
+	durationToleranceSeconds = 60
+	uploadRetryAttempts      = 17280
+	downloadRetryAttempts    = 180
+	checksumRetryAttempts    = 10
+	sourcePendingDelay       = 10 * time.Second
+	segmentListName          = "segments.txt"
 )
 
-var ffmpegSpeedRegex = regexp.MustCompile(`speed=(\d*\.?\d+)x`)
 var ErrorJobNotFound = errors.New("job Not found")
 
-type FFMPEGProgress struct {
-	duration int
-	speed    float64
-	percent  float64
-}
-
 type EncodeWorker struct {
 	model.Manager
 	name            string
@@ -66,6 +64,9 @@ type EncodeWorker struct {
 	terminal        *ConsoleWorkerPrinter
 	ctxStopQueues   context.Context
 	stopQueues      context.CancelFunc
+	jobs            *concurrent.Map[string, *model.WorkTaskEncode]
+	pauses          *concurrent.Map[string, *jobPause]
+	workerPaused    *concurrent.Map[string, *model.WorkTaskEncode]
 }
 
 func ensureDirectoryExists(path string) {
@@ -94,47 +95,12 @@ func NewEncodeWorker(ctx context.Context, workerConfig Config, workerName string
 		terminal:        printer,
 		maxPrefetchJobs: uint32(workerConfig.MaxPrefetchJobs),
 		prefetchJobs:    0,
+		jobs:            concurrent.NewMap[string, *model.WorkTaskEncode](),
+		pauses:          concurrent.NewMap[string, *jobPause](),
+		workerPaused:    concurrent.NewMap[string, *model.WorkTaskEncode](),
 	}
 }
 
-func durToSec(dur string) (sec int) {
-	durAry := strings.Split(dur, ":")
-	if len(durAry) == 3 {
-		hr, _ := strconv.Atoi(durAry[0])
-		sec = hr * (60 * 60)
-		min, _ := strconv.Atoi(durAry[1])
-		sec += min * 60
-		second, _ := strconv.Atoi(durAry[2])
-		sec += second
-	}
-	return
-}
-
-func getSpeed(res string) float64 {
-	rs := ffmpegSpeedRegex.FindStringSubmatch(res)
-	if len(rs) == 0 {
-		return -1
-	}
-	speed, err := strconv.ParseFloat(rs[1], 64)
-	if err != nil {
-		return -1
-	}
-	return speed
-}
-
-func getDuration(res string) int {
-	i := strings.Index(res, "time=")
-	if i >= 0 {
-		time := res[i+5:]
-		if len(time) > 8 {
-			time = time[0:8]
-			sec := durToSec(time)
-			return sec
-		}
-	}
-	return -1
-}
-
 func (E *EncodeWorker) Initialize() {
 	E.resumeJobs()
 	go E.terminal.Render()
@@ -154,6 +120,8 @@ func (E *EncodeWorker) resumeJobs() {
 		}
 
 		taskEncode := E.readTaskStatusFromDiskByPath(path)
+		E.startJob(taskEncode.Task)
+		E.restorePause(taskEncode)
 
 		switch {
 		case taskEncode.LastState.IsDownloading():
@@ -176,15 +144,38 @@ func (E *EncodeWorker) resumeJobs() {
 		panic(err)
 	}
 }
+
+// restorePause keeps a job paused before the worker restarted paused, as the
+// server still shows it. Jobs paused by a pause of the worker continue unless
+// the worker starts paused again, reporting they progress.
+func (E *EncodeWorker) restorePause(taskStatus *model.TaskStatus) {
+	job := taskStatus.Task
+	if !taskStatus.Paused {
+		return
+	}
+	if taskStatus.WorkerPaused && !E.workerConfig.Paused {
+		E.sendTaskEvent(job, model.JobNotification, model.ProgressingNotificationStatus, "", nil)
+	} else {
+		if taskStatus.WorkerPaused {
+			E.workerPaused.Set(job.TaskEncode.Id.String(), job)
+		}
+		// nothing runs yet, its commands are suspended as they start
+		E.jobPause(job).Pause()
+	}
+	E.saveTaskStatusDisk(taskStatus)
+}
+
 func (J *EncodeWorker) IsTypeAccepted(jobType string) bool {
-	return jobType == string(model.EncodeJobType)
+	for _, encodeJobType := range model.EncodeJobTypes {
+		if jobType == string(encodeJobType) {
+			return true
+		}
+	}
+	return false
 }
 
 func (J *EncodeWorker) AcceptJobs() bool {
 	now := time.Now()
-	if J.workerConfig.Paused {
-		return false
-	}
 	if J.workerConfig.HaveSetPeriodTime() {
 		startAfter := time.Date(now.Year(), now.Month(), now.Day(), J.workerConfig.StartAfter.Hour, J.workerConfig.StartAfter.Minute, 0, 0, now.Location())
 		stopAfter := time.Date(now.Year(), now.Month(), now.Day(), J.workerConfig.StopAfter.Hour, J.workerConfig.StopAfter.Minute, 0, 0, now.Location())
@@ -194,6 +185,15 @@ func (J *EncodeWorker) AcceptJobs() bool {
 }
 
 func (J *EncodeWorker) downloadFile(job *model.WorkTaskEncode, track *TaskTracks) error {
+	if sourcePath, ok := sharedPath(J.workerConfig.PathMappings, job.TaskEncode.SourcePath); ok {
+		err := J.openSharedSource(job, sourcePath, track)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return err
+		}
+		J.terminal.Warn("[%s] source not usable from the shared storage, downloading it: %s", job.TaskEncode.Id.String(), err.Error())
+	}
+
+	partialPath := partialDownloadPath(job.WorkDir, job.TaskEncode.Id.String())
 	err := retry.New(
 		retry.Delay(time.Second*5),
 		retry.Attempts(downloadRetryAttempts),
@@ -206,22 +206,47 @@ func (J *EncodeWorker) downloadFile(job *model.WorkTaskEncode, track *TaskTracks
 		}),
 	).Do(func() error {
 		track.UpdateValue(0)
-		resp, err := http.Get(job.TaskEncode.DownloadURL)
+		offset, validator := partialDownload(partialPath)
+		resp, err := doWhenReady(job.Context(), func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(job.Context(), http.MethodGet, job.TaskEncode.DownloadURL, nil)
+			if err == nil {
+				resumeRequest(req, offset, validator)
+			}
+			return req, err
+		})
 		if err != nil {
 			return err
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusNotFound {
+		// complete is a partial download already holding the whole source,
+		// left by an attempt failing on the checksum
+		complete := false
+		switch resp.StatusCode {
+		case http.StatusNotFound:
 			return ErrorJobNotFound
-		}
-		if resp.StatusCode != http.StatusOK {
+		case http.StatusPartialContent:
+			J.terminal.Log("[%s] resuming download at %d bytes", job.TaskEncode.Id.String(), offset)
+		case http.StatusOK:
+			// a new download or a source changed since the partial one
+			offset = 0
+		case http.StatusRequestedRangeNotSatisfiable:
+			if total, ok := unsatisfiedRangeSize(resp); !ok || total != offset {
+				removePartialDownload(partialPath)
+				return fmt.Errorf("partial download of %d bytes does not match the source", offset)
+			}
+			complete = true
+		default:
 			return fmt.Errorf("non-200 response in download code %d", resp.StatusCode)
 		}
 
-		size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
-		if err != nil {
-			return err
+		size := offset
+		if !complete {
+			length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+			if err != nil {
+				return err
+			}
+			size += length
 		}
 		track.SetTotal(size)
 
@@ -230,29 +255,51 @@ func (J *EncodeWorker) downloadFile(job *model.WorkTaskEncode, track *TaskTracks
 			return err
 		}
 
-		job.SourceFilePath = filepath.Join(job.WorkDir, fmt.Sprintf("%s%s", job.TaskEncode.Id.String(), filepath.Ext(params["filename"])))
-		downloadFile, err := os.Create(job.SourceFilePath)
-		if err != nil {
+		sha := sha256.New()
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if offset > 0 {
+			if err = hashPartialDownload(partialPath, offset, sha); err != nil {
+				return err
+			}
+			flags = os.O_WRONLY | os.O_APPEND
+		} else if err = os.WriteFile(partialPath+validatorExt, []byte(responseValidator(resp)), os.ModePerm); err != nil {
 			return err
 		}
-		defer downloadFile.Close()
+		track.UpdateValue(offset)
+		if !complete {
+			downloadFile, err := os.OpenFile(partialPath, flags, os.ModePerm)
+			if err != nil {
+				return err
+			}
+			defer downloadFile.Close()
 
-		reader := NewProgressTrackStream(track, resp.Body)
-		_, err = io.Copy(downloadFile, reader)
-		if err != nil {
-			return err
+			reader := NewProgressTrackStream(track, newPausableReader(job.Context(), J.jobPause(job), resp.Body))
+			_, err = io.Copy(downloadFile, io.TeeReader(reader, sha))
+			if err != nil {
+				return err
+			}
+			if err = downloadFile.Close(); err != nil {
+				return err
+			}
 		}
 
-		sha256String := hex.EncodeToString(reader.SumSha())
-		bodyString, checksumErr := J.calculateChecksum(job.TaskEncode.ChecksumURL)
+		sha256String := hex.EncodeToString(sha.Sum(nil))
+		bodyString, checksumErr := J.calculateChecksum(job.Context(), job.TaskEncode.ChecksumURL)
 		if checksumErr != nil {
 			return checksumErr
 		}
 
 		if sha256String != bodyString {
+			removePartialDownload(partialPath)
 			return fmt.Errorf("checksum error on download source:%s downloaded:%s", bodyString, sha256String)
 		}
 
+		job.SourceFilePath = filepath.Join(job.WorkDir, fmt.Sprintf("%s%s", job.TaskEncode.Id.String(), filepath.Ext(params["filename"])))
+		if err = os.Rename(partialPath, job.SourceFilePath); err != nil {
+			return err
+		}
+		os.Remove(partialPath + validatorExt)
+
 		track.UpdateValue(size)
 		return nil
 	})
@@ -260,10 +307,77 @@ func (J *EncodeWorker) downloadFile(job *model.WorkTaskEncode, track *TaskTracks
 	return err
 }
 
-func (J *EncodeWorker) calculateChecksum(checksumURL string) (string, error) {
+// downloadSegments fetches the encoded segments of a chunked job and writes
+// the concat list used to join them.
+func (J *EncodeWorker) downloadSegments(job *model.WorkTaskEncode, track *TaskTracks) error {
+	var list strings.Builder
+	for i, segmentURL := range job.TaskEncode.SegmentURLs {
+		name := fmt.Sprintf("segment-%04d.mkv", i)
+		if err := J.downloadSegment(job, segmentURL, filepath.Join(job.WorkDir, name), track); err != nil {
+			return fmt.Errorf("error downloading segment %d: %w", i, err)
+		}
+		fmt.Fprintf(&list, "file '%s'\n", name)
+	}
+	return os.WriteFile(filepath.Join(job.WorkDir, segmentListName), []byte(list.String()), os.ModePerm)
+}
+
+func (J *EncodeWorker) downloadSegment(job *model.WorkTaskEncode, segmentURL string, filePath string, track *TaskTracks) error {
+	return retry.New(
+		retry.Delay(time.Second*5),
+		retry.Attempts(downloadRetryAttempts),
+		retry.LastErrorOnly(true),
+		retry.OnRetry(func(n uint, err error) {
+			J.terminal.Error("error on downloading segment %s", err.Error())
+		}),
+		retry.RetryIf(func(err error) bool {
+			return !(errors.Is(err, context.Canceled) || errors.Is(err, ErrorJobNotFound))
+		}),
+	).Do(func() error {
+		track.UpdateValue(0)
+		req, err := http.NewRequestWithContext(job.Context(), http.MethodGet, segmentURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrorJobNotFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("non-200 response in download code %d", resp.StatusCode)
+		}
+
+		size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return err
+		}
+		track.SetTotal(size)
+
+		segmentFile, err := os.Create(filePath)
+		if err != nil {
+			return err
+		}
+		defer segmentFile.Close()
+
+		body := newPausableReader(job.Context(), J.jobPause(job), resp.Body)
+		if _, err = io.Copy(segmentFile, NewProgressTrackStream(track, body)); err != nil {
+			return err
+		}
+		track.UpdateValue(size)
+		return nil
+	})
+}
+
+// calculateChecksum fetches the checksum of the source from the server.
+func (J *EncodeWorker) calculateChecksum(ctx context.Context, checksumURL string) (string, error) {
 	var bodyString string
 
 	err := retry.New(
+		retry.Context(ctx),
 		retry.Delay(time.Second*5),
 		retry.Attempts(checksumRetryAttempts),
 		retry.LastErrorOnly(true),
@@ -274,7 +388,9 @@ func (J *EncodeWorker) calculateChecksum(checksumURL string) (string, error) {
 			return !errors.Is(err, context.Canceled)
 		}),
 	).Do(func() error {
-		respSha256, err := http.Get(checksumURL)
+		respSha256, err := doWhenReady(ctx, func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, checksumURL, nil)
+		})
 		if err != nil {
 			return err
 		}
@@ -299,7 +415,28 @@ func (J *EncodeWorker) calculateChecksum(checksumURL string) (string, error) {
 	return bodyString, nil
 }
 
-func (J *EncodeWorker) getVideoParameters(inputFile string) (data *ffprobe.ProbeData, size int64, err error) {
+// doWhenReady sends the request again, without using retry attempts, while
+// the server answers it is still hashing the source or cutting the segment.
+func doWhenReady(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, err
+		}
+		resp.Body.Close()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sourcePendingDelay):
+		}
+	}
+}
+
+func (J *EncodeWorker) getVideoParameters(ctx context.Context, inputFile string) (data *ffprobe.ProbeData, size int64, err error) {
 	fileReader, err := os.Open(inputFile)
 	if err != nil {
 		return nil, -1, fmt.Errorf("error opening file %s: %v", inputFile, err)
@@ -311,7 +448,7 @@ func (J *EncodeWorker) getVideoParameters(inputFile string) (data *ffprobe.Probe
 		return nil, 0, err
 	}
 
-	data, err = ffprobe.ProbeReader(J.ctx, fileReader)
+	data, err = ffprobe.ProbeReader(ctx, fileReader)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error getting data: %v", err)
 	}
@@ -342,7 +479,7 @@ func FFProbeFrameRate(FFProbeFrameRate string) (frameRate int, err error) {
 	return frameRatio / rate, nil
 }
 
-func (J *EncodeWorker) clearData(data *ffprobe.ProbeData) (*ContainerData, error) {
+func (J *EncodeWorker) clearData(data *ffprobe.ProbeData, profile *model.EncodingProfile) (*ContainerData, error) {
 	container := &ContainerData{}
 
 	videoStream := data.StreamType(ffprobe.StreamVideo)[0]
@@ -351,14 +488,23 @@ func (J *EncodeWorker) clearData(data *ffprobe.ProbeData) (*ContainerData, error
 		frameRate = 24
 	}
 
-	container.Video = &Video{
-		Id:        uint8(videoStream.Index),
-		Duration:  data.Format.Duration(),
-		FrameRate: frameRate,
+	hdr, err := parseHDRMetadata(&videoStream)
+	if err != nil {
+		return nil, err
 	}
 
-	betterAudioStreamPerLanguage := make(map[string]*Audio)
+	container.Video = &Video{
+		Id:         uint8(videoStream.Index),
+		Duration:   data.Format.Duration(),
+		FrameRate:  frameRate,
+		Codec:      videoStream.CodecName,
+		FieldOrder: videoStream.FieldOrder,
+		Width:      videoStream.Width,
+		Height:     videoStream.Height,
+		HDR:        hdr,
+	}
 
+	var audios []*Audio
 	for _, stream := range data.StreamType(ffprobe.StreamAudio) {
 		if stream.BitRate == "" {
 			stream.BitRate = "0"
@@ -378,20 +524,23 @@ func (J *EncodeWorker) clearData(data *ffprobe.ProbeData) (*ContainerData, error
 			Default:        stream.Disposition.Default == 1,
 			Bitrate:        uint(bitRateInt),
 			Title:          stream.Tags.Title,
+			Codec:          stream.CodecName,
+			Profile:        stream.Profile,
+			Original:       stream.Disposition.Original == 1,
+			Commentary:     stream.Disposition.Comment == 1 || commentaryRegex.MatchString(stream.Tags.Title),
 		}
-
-		betterAudio := betterAudioStreamPerLanguage[newAudio.Language]
-
-		if betterAudio != nil && (newAudio.ChannelsNumber > betterAudio.ChannelsNumber || (newAudio.ChannelsNumber == betterAudio.ChannelsNumber && newAudio.Bitrate > betterAudio.Bitrate)) {
-			betterAudioStreamPerLanguage[newAudio.Language] = newAudio
-		} else if betterAudio == nil {
-			betterAudioStreamPerLanguage[newAudio.Language] = newAudio
-		}
+		audios = append(audios, newAudio)
 	}
 
-	for _, audioStream := range betterAudioStreamPerLanguage {
-		container.Audios = append(container.Audios, audioStream)
+	audioPolicy := model.AudioPolicy{}
+	subtitlePolicy := model.SubtitlePolicy{}
+	outputContainer := model.ContainerMKV
+	if profile != nil {
+		audioPolicy = profile.Audio
+		subtitlePolicy = profile.Subtitle
+		outputContainer = profile.OutputContainer()
 	}
+	container.Audios = selectAudioStreams(audios, audioPolicy)
 
 	betterSubtitleStreamPerLanguage := make(map[string]*Subtitle)
 
@@ -403,6 +552,7 @@ func (J *EncodeWorker) clearData(data *ffprobe.ProbeData) (*ContainerData, error
 			Comment:  stream.Disposition.Comment == 1,
 			Format:   stream.CodecName,
 			Title:    stream.Tags.Title,
+			SDH:      stream.Disposition.HearingImpaired == 1 || sdhRegex.MatchString(stream.Tags.Title),
 		}
 
 		if newSubtitle.Forced || newSubtitle.Comment {
@@ -422,83 +572,120 @@ func (J *EncodeWorker) clearData(data *ffprobe.ProbeData) (*ContainerData, error
 	for _, value := range betterSubtitleStreamPerLanguage {
 		container.Subtitle = append(container.Subtitle, value)
 	}
+	container.Subtitle = containerSubtitles(selectSubtitleStreams(container.Subtitle, subtitlePolicy), outputContainer)
 
 	return container, nil
 }
 
 func (J *EncodeWorker) FFMPEG(job *model.WorkTaskEncode, videoContainer *ContainerData, ffmpegProgressChan chan<- FFMPEGProgress) error {
-	ffmpeg := &FFMPEGGenerator{}
+	container := outputContainer(job.TaskEncode)
+	ffmpeg := NewFFMPEGGenerator(job.TaskEncode.Profile)
+	ffmpeg.setContainer(container)
+	ffmpeg.setSegment(job.TaskEncode.Segment)
+	if len(job.TaskEncode.SegmentURLs) > 0 {
+		ffmpeg.setConcatInput(filepath.Join(job.WorkDir, segmentListName))
+	}
+	ffmpeg.setBitrate(videoContainer.Video.TargetBitrate, filepath.Join(job.WorkDir, passLogName))
 	ffmpeg.setInputFilters(videoContainer, job.SourceFilePath, job.WorkDir)
-	ffmpeg.setVideoFilters(videoContainer)
 	ffmpeg.setAudioFilters(videoContainer)
 	ffmpeg.setSubtFilters(videoContainer)
 	ffmpeg.setMetadata(videoContainer)
 
-	ffmpegErrLog := ""
-	ffmpegOutLog := ""
+	sourceFileName := filepath.Base(job.SourceFilePath)
+	encodedFilePath := fmt.Sprintf("%s-encoded.%s", strings.TrimSuffix(sourceFileName, filepath.Ext(sourceFileName)), container)
+	job.TargetFilePath = filepath.Join(job.WorkDir, encodedFilePath)
 
-	sendObj := FFMPEGProgress{
-		duration: -1,
-		speed:    -1,
+	passes := ffmpeg.passes()
+	for pass := 1; pass <= passes; pass++ {
+		if passes > 1 {
+			ffmpeg.setPass(pass)
+		}
+		ffmpeg.setVideoFilters(videoContainer)
+		if err := J.runFFMPEG(job, videoContainer, ffmpeg.buildArguments(uint8(J.workerConfig.Threads), job.TargetFilePath), pass, ffmpegProgressChan); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// runFFMPEG runs a single ffmpeg pass, reporting its progress.
+func (J *EncodeWorker) runFFMPEG(job *model.WorkTaskEncode, videoContainer *ContainerData, ffmpegArguments []string, pass int, ffmpegProgressChan chan<- FFMPEGProgress) error {
+	ffmpegErrLog := ""
+	parser := &progressParser{}
 
 	isClosed := false
 	defer func() {
 		isClosed = true
 	}()
 
-	checkPercentageFFMPEG := func(buffer []byte, exit bool) {
-		stringedBuffer := string(buffer)
-		ffmpegErrLog += stringedBuffer
+	stderrFFMPEG := func(buffer []byte, exit bool) {
+		ffmpegErrLog += string(buffer)
+	}
 
-		duration := getDuration(stringedBuffer)
-		if duration != -1 {
-			sendObj.duration = duration
-			sendObj.percent = float64(duration*100) / videoContainer.Video.Duration.Seconds()
+	// -progress pipe:1 writes the encode state to stdout
+	progressFFMPEG := func(buffer []byte, exit bool) {
+		for _, progress := range parser.parse(buffer) {
+			if isClosed {
+				return
+			}
+			progress.pass = pass
+			progress.percent = progress.outTime * 100 / videoContainer.Video.Duration.Seconds()
+			ffmpegProgressChan <- progress
 		}
+	}
 
-		speed := getSpeed(stringedBuffer)
-		if speed != -1 {
-			sendObj.speed = speed
-		}
+	ffmpegCommand := newFFMPEGCommand(ffmpegArguments...).
+		SetWorkDir(job.WorkDir).
+		SetStdoutFunc(progressFFMPEG).
+		SetStderrFunc(stderrFFMPEG)
+	J.terminal.Cmd("FFMPEG Command:%s", ffmpegCommand.GetFullCommand())
 
-		if sendObj.speed != -1 && sendObj.duration != -1 && !isClosed {
-			ffmpegProgressChan <- sendObj
-			sendObj.duration = -1
-			sendObj.speed = -1
-		}
+	exitCode, err := J.runCommand(job, ffmpegCommand)
+	J.saveLog(job.WorkDir, ffmpegLogName, ffmpegCommand.GetFullCommand(), ffmpegErrLog)
+	if err != nil {
+		return fmt.Errorf("%w: stderr:%s", err, logTail(ffmpegErrLog, logTailLines))
 	}
 
-	stdoutFFMPEG := func(buffer []byte, exit bool) {
-		ffmpegOutLog += string(buffer)
+	if exitCode != 0 {
+		return fmt.Errorf("exit code %d: stderr:%s", exitCode, logTail(ffmpegErrLog, logTailLines))
 	}
 
-	sourceFileName := filepath.Base(job.SourceFilePath)
-	encodedFilePath := fmt.Sprintf("%s-encoded.%s", strings.TrimSuffix(sourceFileName, filepath.Ext(sourceFileName)), "mkv")
-	job.TargetFilePath = filepath.Join(job.WorkDir, encodedFilePath)
-
-	ffmpegArguments := ffmpeg.buildArguments(uint8(J.workerConfig.Threads), job.TargetFilePath)
-	J.terminal.Cmd("FFMPEG Command:%s %s", helper.GetFFmpegPath(), ffmpegArguments)
-
-	ffmpegCommand := command.NewCommandByString(helper.GetFFmpegPath(), ffmpegArguments).
-		SetWorkDir(job.WorkDir).
-		SetStdoutFunc(stdoutFFMPEG).
-		SetStderrFunc(checkPercentageFFMPEG)
+	return nil
+}
 
+// newFFMPEGCommand returns an ffmpeg command loading the libraries shipped
+// next to the binary.
+func newFFMPEGCommand(arguments ...string) *command.Command {
+	ffmpegCommand := command.NewCommand(helper.GetFFmpegPath(), arguments...)
 	if runtime.GOOS == "linux" {
 		ffmpegCommand.AddEnv(fmt.Sprintf("LD_LIBRARY_PATH=%s", filepath.Dir(helper.GetFFmpegPath())))
 	}
+	return ffmpegCommand
+}
 
-	exitCode, err := ffmpegCommand.RunWithContext(J.ctx)
-	if err != nil {
-		return fmt.Errorf("%w: stderr:%s stdout:%s", err, ffmpegErrLog, ffmpegOutLog)
-	}
+// analyzeVideo runs an ffmpeg analysis pass and returns its whole output, where
+// filters like cropdetect or libvmaf log their results.
+func (J *EncodeWorker) analyzeVideo(job *model.WorkTaskEncode, arguments []string) (string, error) {
+	// stdout and stderr are read concurrently
+	var outputMu sync.Mutex
+	var output bytes.Buffer
+	collectOutput := func(buffer []byte, exit bool) {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		output.Write(buffer)
+	}
+	analysisCommand := newFFMPEGCommand(arguments...).
+		SetWorkDir(job.WorkDir).
+		SetStdoutFunc(collectOutput).
+		SetStderrFunc(collectOutput)
+	J.terminal.Cmd("FFMPEG Analysis Command:%s", analysisCommand.GetFullCommand())
 
-	if exitCode != 0 {
-		return fmt.Errorf("exit code %d: stderr:%s stdout:%s", exitCode, ffmpegErrLog, ffmpegOutLog)
+	_, err := J.runCommand(job, analysisCommand)
+	J.saveLog(job.WorkDir, ffmpegLogName, analysisCommand.GetFullCommand(), output.String())
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, logTail(output.String(), logTailLines))
 	}
-
-	return nil
+	return output.String(), nil
 }
 
 type ProgressTrackReader struct {
@@ -528,20 +715,68 @@ func (P *ProgressTrackReader) SumSha() []byte {
 
 func (J *EncodeWorker) UploadJob(task *model.WorkTaskEncode, track *TaskTracks) error {
 	J.updateTaskStatus(task, model.UploadNotification, model.ProgressingNotificationStatus, "")
-	err := retry.New(
+	err := J.uploadResult(task, track)
+	if err == nil {
+		err = J.uploadSidecars(task, track)
+	}
+
+	if err != nil {
+		J.updateTaskStatus(task, model.UploadNotification, model.FailedNotificationStatus, "")
+		return err
+	}
+
+	J.updateTaskStatus(task, model.UploadNotification, model.CompletedNotificationStatus, "")
+	return nil
+}
+
+// uploadResult writes the encoded file in place on the shared storage, or
+// uploads it when the destination is not visible to the worker.
+func (J *EncodeWorker) uploadResult(task *model.WorkTaskEncode, track *TaskTracks) error {
+	if destinationPath, ok := sharedPath(J.workerConfig.PathMappings, task.TaskEncode.DestinationPath); ok {
+		err := J.writeShared(task.TargetFilePath, destinationPath, track)
+		if err == nil {
+			return nil
+		}
+		J.terminal.Warn("[%s] result not writable to the shared storage, uploading it: %s", task.TaskEncode.Id.String(), err.Error())
+	}
+	return J.uploadChunked(task.Context(), J.jobPause(task), task.TaskEncode.UploadURL, task.TargetFilePath, track)
+}
+
+// uploadSidecars sends the sidecar files next to the encoded video, each one
+// to the sidecar endpoint under the job upload URL.
+func (J *EncodeWorker) uploadSidecars(task *model.WorkTaskEncode, track *TaskTracks) error {
+	sidecars, err := listSidecars(task.WorkDir)
+	if err != nil {
+		return err
+	}
+	for name, path := range sidecars {
+		sidecarURL := fmt.Sprintf("%s/sidecar/%s", task.TaskEncode.UploadURL, url.PathEscape(name))
+		if err := J.uploadFile(task.Context(), sidecarURL, path, track); err != nil {
+			return fmt.Errorf("error uploading sidecar %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (J *EncodeWorker) uploadFile(ctx context.Context, uploadURL string, filePath string, track *TaskTracks) error {
+	return J.uploadFileAttempts(ctx, uploadURL, filePath, track, uploadRetryAttempts)
+}
+
+func (J *EncodeWorker) uploadFileAttempts(ctx context.Context, uploadURL string, filePath string, track *TaskTracks, attempts uint) error {
+	return retry.New(
 		retry.Delay(time.Second*5),
 		retry.RetryIf(func(err error) bool {
 			return !errors.Is(err, context.Canceled)
 		}),
 		retry.DelayType(retry.FixedDelay),
-		retry.Attempts(uploadRetryAttempts),
+		retry.Attempts(attempts),
 		retry.LastErrorOnly(true),
 		retry.OnRetry(func(n uint, err error) {
 			J.terminal.Error("error on uploading job %s", err.Error())
 		}),
 	).Do(func() error {
 		track.UpdateValue(0)
-		encodedFile, err := os.Open(task.TargetFilePath)
+		encodedFile, err := os.Open(filePath)
 		if err != nil {
 			return err
 		}
@@ -559,7 +794,7 @@ func (J *EncodeWorker) UploadJob(task *model.WorkTaskEncode, track *TaskTracks)
 		reader := NewProgressTrackStream(track, encodedFile)
 
 		client := &http.Client{}
-		req, err := http.NewRequestWithContext(J.ctx, "POST", task.TaskEncode.UploadURL, reader)
+		req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, reader)
 		if err != nil {
 			return err
 		}
@@ -583,24 +818,20 @@ func (J *EncodeWorker) UploadJob(task *model.WorkTaskEncode, track *TaskTracks)
 		track.UpdateValue(fileSize)
 		return nil
 	})
-
-	if err != nil {
-		J.updateTaskStatus(task, model.UploadNotification, model.FailedNotificationStatus, "")
-		return err
-	}
-
-	J.updateTaskStatus(task, model.UploadNotification, model.CompletedNotificationStatus, "")
-	return nil
 }
 
-func (J *EncodeWorker) errorJob(taskEncode *model.WorkTaskEncode, err error) {
-	if errors.Is(err, context.Canceled) {
+func (J *EncodeWorker) errorJob(taskEncode *model.WorkTaskEncode, track *TaskTracks, err error) {
+	J.uploadLogs(taskEncode, track)
+	// a killed command does not always report the cancel of its context
+	if errors.Is(err, context.Canceled) || taskEncode.Context().Err() != nil {
 		J.updateTaskStatus(taskEncode, model.JobNotification, model.CanceledNotificationStatus, "")
+	} else if errors.Is(err, ErrKeptOriginal) {
+		J.updateTaskStatus(taskEncode, model.JobNotification, model.KeptOriginalNotificationStatus, err.Error())
 	} else {
 		J.updateTaskStatus(taskEncode, model.JobNotification, model.FailedNotificationStatus, err.Error())
 	}
 
-	taskEncode.Clean()
+	J.finishJob(taskEncode)
 }
 
 func (J *EncodeWorker) Execute(workData []byte) error {
@@ -615,6 +846,7 @@ func (J *EncodeWorker) Execute(workData []byte) error {
 		WorkDir:    workDir,
 	}
 	os.MkdirAll(workDir, os.ModePerm)
+	J.startJob(workTaskEncode)
 
 	J.updateTaskStatus(workTaskEncode, model.JobNotification, model.ProgressingNotificationStatus, "")
 	J.AddDownloadJob(workTaskEncode)
@@ -624,6 +856,151 @@ func (J *EncodeWorker) Execute(workData []byte) error {
 func (J *EncodeWorker) Cancel() {
 	J.cancelContext()
 }
+
+// RunJobAction applies an action sent by the server to one of the jobs of the
+// worker, false when the job does not run on it or the action is unknown.
+func (J *EncodeWorker) RunJobAction(action model.JobAction, id uuid.UUID) bool {
+	switch action {
+	case model.CancelJobAction:
+		return J.CancelJob(id)
+	case model.PauseJobAction:
+		return J.PauseJob(id)
+	case model.ResumeJobAction:
+		return J.ResumeJob(id)
+	default:
+		J.terminal.Warn("[%s] unknown job action %s", id.String(), action)
+		return false
+	}
+}
+
+// CancelJob stops a single job, its queue reports it canceled and cleans its
+// work directory. It returns false when the job does not run on this worker.
+func (J *EncodeWorker) CancelJob(id uuid.UUID) bool {
+	job, ok := J.jobs.Get(id.String())
+	if !ok {
+		return false
+	}
+	J.terminal.Warn("[%s] canceling job", id.String())
+	job.Cancel()
+	return true
+}
+
+// startJob gives the job its own context, so CancelJob stops it without the
+// other jobs of the worker.
+func (J *EncodeWorker) startJob(job *model.WorkTaskEncode) {
+	job.StartContext(J.ctx)
+	J.jobs.Set(job.TaskEncode.Id.String(), job)
+	J.pauses.Set(job.TaskEncode.Id.String(), newJobPause())
+}
+
+func (J *EncodeWorker) finishJob(job *model.WorkTaskEncode) {
+	job.Clean()
+	job.Cancel()
+	J.jobs.Delete(job.TaskEncode.Id.String())
+	J.pauses.Delete(job.TaskEncode.Id.String())
+	J.workerPaused.Delete(job.TaskEncode.Id.String())
+}
+
+// PauseJob suspends a running job until ResumeJob, reporting it paused. It
+// returns false when the job does not run on this worker or is paused already.
+func (J *EncodeWorker) PauseJob(id uuid.UUID) bool {
+	job, ok := J.jobs.Get(id.String())
+	if !ok {
+		return false
+	}
+	return J.pauseJob(job)
+}
+
+func (J *EncodeWorker) pauseJob(job *model.WorkTaskEncode) bool {
+	paused, err := J.jobPause(job).Pause()
+	if err != nil {
+		J.terminal.Warn("[%s] commands of the job not suspended: %s", job.TaskEncode.Id.String(), err.Error())
+	}
+	if paused {
+		J.sendTaskEvent(job, model.JobNotification, model.PausedNotificationStatus, "", nil)
+		J.savePauseState(job)
+	}
+	return paused
+}
+
+// PauseJobs suspends the running jobs for a pause of the worker. ResumeJobs
+// only continues these, jobs paused on their own stay paused.
+func (J *EncodeWorker) PauseJobs() {
+	for _, job := range J.runningJobs() {
+		if J.jobPause(job).Paused() {
+			continue
+		}
+		// set first so the saved pause is the one of the worker
+		J.workerPaused.Set(job.TaskEncode.Id.String(), job)
+		if !J.pauseJob(job) {
+			J.workerPaused.Delete(job.TaskEncode.Id.String())
+		}
+	}
+}
+
+func (J *EncodeWorker) ResumeJobs() {
+	var paused []*model.WorkTaskEncode
+	for item := range J.workerPaused.Iter() {
+		paused = append(paused, item.Value)
+	}
+	for _, job := range paused {
+		J.ResumeJob(job.TaskEncode.Id)
+	}
+}
+
+// RunningJobs counts the jobs taken by the worker and not finished yet.
+func (J *EncodeWorker) RunningJobs() int {
+	return len(J.runningJobs())
+}
+
+func (J *EncodeWorker) runningJobs() []*model.WorkTaskEncode {
+	var jobs []*model.WorkTaskEncode
+	for item := range J.jobs.Iter() {
+		jobs = append(jobs, item.Value)
+	}
+	return jobs
+}
+
+// ResumeJob continues a job suspended by PauseJob, false when it was not
+// paused.
+func (J *EncodeWorker) ResumeJob(id uuid.UUID) bool {
+	job, ok := J.jobs.Get(id.String())
+	if !ok {
+		return false
+	}
+	J.workerPaused.Delete(id.String())
+	resumed, err := J.jobPause(job).Resume()
+	if err != nil {
+		J.terminal.Warn("[%s] commands of the job not continued: %s", id.String(), err.Error())
+	}
+	if resumed {
+		J.sendTaskEvent(job, model.JobNotification, model.ProgressingNotificationStatus, "", nil)
+		J.savePauseState(job)
+	}
+	return resumed
+}
+
+// jobPause returns the pause of a job, nil for the jobs not started by
+// startJob, which never pause.
+func (J *EncodeWorker) jobPause(job *model.WorkTaskEncode) *jobPause {
+	if J.pauses == nil {
+		return nil
+	}
+	pause, _ := J.pauses.Get(job.TaskEncode.Id.String())
+	return pause
+}
+
+// runCommand runs a command of the job, which PauseJob can suspend.
+func (J *EncodeWorker) runCommand(job *model.WorkTaskEncode, cmd *command.Command, opt ...command.Option) (int, error) {
+	pause := J.jobPause(job)
+	var process *os.Process
+	cmd.SetStartFunc(func(started *os.Process) {
+		process = started
+		pause.track(started)
+	})
+	defer func() { pause.untrack(process) }()
+	return cmd.RunWithContext(job.Context(), opt...)
+}
 func (J *EncodeWorker) StopQueues() {
 	defer close(J.downloadChan)
 	defer close(J.uploadChan)
@@ -635,16 +1012,41 @@ func (J *EncodeWorker) GetID() string {
 	return J.name
 }
 func (J *EncodeWorker) updateTaskStatus(encode *model.WorkTaskEncode, notificationType model.NotificationType, status model.NotificationStatus, message string) {
+	J.updateTaskEvent(encode, notificationType, status, message, nil)
+}
+
+// updateTaskProgress reports the state of a running encode.
+func (J *EncodeWorker) updateTaskProgress(encode *model.WorkTaskEncode, progress *model.EncodeProgress) {
+	J.updateTaskEvent(encode, model.FFMPEGSNotification, model.ProgressingNotificationStatus, "", progress)
+}
+
+func (J *EncodeWorker) updateTaskEvent(encode *model.WorkTaskEncode, notificationType model.NotificationType, status model.NotificationStatus, message string, progress *model.EncodeProgress) {
+	event := J.sendTaskEvent(encode, notificationType, status, message, progress)
+	J.saveTaskStatusDisk(&model.TaskStatus{
+		LastState: &event,
+		Task:      encode,
+	})
+}
+
+// sendTaskEvent reports an event without saving it as the state resumeJobs
+// continues the task from. Pauses and resumes report from another goroutine
+// than the one running the task, the event IDs are taken under the lock the
+// task is saved with.
+func (J *EncodeWorker) sendTaskEvent(encode *model.WorkTaskEncode, notificationType model.NotificationType, status model.NotificationStatus, message string, progress *model.EncodeProgress) model.TaskEvent {
+	J.mu.Lock()
 	encode.TaskEncode.EventID++
+	eventID := encode.TaskEncode.EventID
+	J.mu.Unlock()
 	event := model.TaskEvent{
 		Id:               encode.TaskEncode.Id,
-		EventID:          encode.TaskEncode.EventID,
+		EventID:          eventID,
 		EventType:        model.NotificationEvent,
 		WorkerName:       J.workerConfig.Name,
 		EventTime:        time.Now(),
 		NotificationType: notificationType,
 		Status:           status,
 		Message:          message,
+		Progress:         progress,
 	}
 	if err := J.Manager.EventNotification(event); err != nil {
 		J.terminal.Error("failed to send event notification: %v", err)
@@ -652,25 +1054,47 @@ func (J *EncodeWorker) updateTaskStatus(encode *model.WorkTaskEncode, notificati
 
 	if event.Message != "" {
 		J.terminal.Log("[%s] %s has been %s: %s", event.Id.String(), event.NotificationType, event.Status, event.Message)
+	} else if event.Progress != nil {
+		J.terminal.Log("[%s] %s has been %s: %.2f%% at %.1f fps", event.Id.String(), event.NotificationType, event.Status, event.Progress.Percent, event.Progress.FPS)
 	} else {
 		J.terminal.Log("[%s] %s has been %s", event.Id.String(), event.NotificationType, event.Status)
 	}
-
-	J.saveTaskStatusDisk(&model.TaskStatus{
-		LastState: &event,
-		Task:      encode,
-	})
-
+	return event
 }
 
 func (J *EncodeWorker) saveTaskStatusDisk(taskEncode *model.TaskStatus) {
 	J.mu.Lock()
 	defer J.mu.Unlock()
+	J.writeTaskStatus(taskEncode)
+}
+
+// savePauseState saves a job paused or resumed, keeping the state resumeJobs
+// continues it from.
+func (J *EncodeWorker) savePauseState(job *model.WorkTaskEncode) {
+	J.mu.Lock()
+	defer J.mu.Unlock()
+	statusPath := taskStatusPath(job)
+	if _, err := os.Stat(statusPath); err != nil {
+		return
+	}
+	taskStatus := J.readTaskStatusFromDiskByPath(statusPath)
+	taskStatus.Task = job
+	J.writeTaskStatus(taskStatus)
+}
+
+func taskStatusPath(job *model.WorkTaskEncode) string {
+	return filepath.Join(job.WorkDir, fmt.Sprintf("%s.json", job.TaskEncode.Id))
+}
+
+// writeTaskStatus saves the task with whether it is paused, J.mu held.
+func (J *EncodeWorker) writeTaskStatus(taskEncode *model.TaskStatus) {
+	taskEncode.Paused = J.jobPause(taskEncode.Task).Paused()
+	_, taskEncode.WorkerPaused = J.workerPaused.Get(taskEncode.Task.TaskEncode.Id.String())
 	b, err := json.MarshalIndent(taskEncode, "", "\t")
 	if err != nil {
 		panic(err)
 	}
-	eventFile, err := os.OpenFile(filepath.Join(taskEncode.Task.WorkDir, fmt.Sprintf("%s.json", taskEncode.Task.TaskEncode.Id)), os.O_TRUNC|os.O_CREATE|os.O_RDWR, os.ModePerm)
+	eventFile, err := os.OpenFile(taskStatusPath(taskEncode.Task), os.O_TRUNC|os.O_CREATE|os.O_RDWR, os.ModePerm)
 	if err != nil {
 		return
 	}
@@ -766,8 +1190,8 @@ func (J *EncodeWorker) convertPGSToSrt(taskEncode *model.WorkTaskEncode, contain
 	helper.Debug("start the PGs counter")
 	for {
 		select {
-		case <-J.ctx.Done():
-			return J.ctx.Err()
+		case <-taskEncode.Context().Done():
+			return taskEncode.Context().Err()
 		case <-time.After(pgsConversionTimeout):
 			return errors.New("timeout waiting for PGS job done")
 		case response, ok := <-out:
@@ -775,6 +1199,9 @@ func (J *EncodeWorker) convertPGSToSrt(taskEncode *model.WorkTaskEncode, contain
 				return nil
 			}
 			helper.Debugf("response: %+v", response)
+			if response.Log != "" {
+				J.saveLog(taskEncode.WorkDir, fmt.Sprintf("pgstosrt-%d.log", response.PGSID), "PGSToSrt", response.Log)
+			}
 			if response.Err != "" {
 				return fmt.Errorf("error on process PGS %d: %s", response.PGSID, response.Err)
 			}
@@ -787,20 +1214,35 @@ func (J *EncodeWorker) convertPGSToSrt(taskEncode *model.WorkTaskEncode, contain
 	}
 }
 
+// mkvExtractArguments extracts every subtitle track to <id>.sup in the work dir.
+func mkvExtractArguments(sourceFilePath string, subtitles []*Subtitle) []string {
+	arguments := []string{"tracks", sourceFilePath}
+	for _, subtitle := range subtitles {
+		arguments = append(arguments, fmt.Sprintf("%d:%d.sup", subtitle.Id, subtitle.Id))
+	}
+	return arguments
+}
+
 func (J *EncodeWorker) MKVExtract(subtitles []*Subtitle, taskEncode *model.WorkTaskEncode) error {
-	mkvExtractCommand := command.NewCommand(helper.GetMKVExtractPath(), "tracks", taskEncode.SourceFilePath).
+	mkvExtractCommand := command.NewCommand(helper.GetMKVExtractPath(), mkvExtractArguments(taskEncode.SourceFilePath, subtitles)...).
 		SetWorkDir(taskEncode.WorkDir)
 	if runtime.GOOS == "linux" {
 		mkvExtractCommand.AddEnv(fmt.Sprintf("LD_LIBRARY_PATH=%s", filepath.Dir(helper.GetMKVExtractPath())))
 	}
-	for _, subtitle := range subtitles {
-		mkvExtractCommand.AddParam(fmt.Sprintf("%d:%d.sup", subtitle.Id, subtitle.Id))
+	var outputMutex sync.Mutex
+	mkvExtractOutput := ""
+	appendOutput := func(buffer []byte, exit bool) {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		mkvExtractOutput += string(buffer)
 	}
+	mkvExtractCommand.SetStdoutFunc(appendOutput).SetStderrFunc(appendOutput)
 
-	_, err := mkvExtractCommand.RunWithContext(J.ctx, command.NewAllowedCodesOption(0, 1))
+	_, err := J.runCommand(taskEncode, mkvExtractCommand, command.NewAllowedCodesOption(0, 1))
+	J.saveLog(taskEncode.WorkDir, mkvExtractLogName, mkvExtractCommand.GetFullCommand(), mkvExtractOutput)
 	if err != nil {
 		J.terminal.Cmd("MKVExtract command:%s", mkvExtractCommand.GetFullCommand())
-		return fmt.Errorf("MKVExtract unexpected error:%v", err.Error())
+		return fmt.Errorf("MKVExtract unexpected error:%v: %s", err.Error(), logTail(mkvExtractOutput, logTailLines))
 	}
 
 	return nil
@@ -830,13 +1272,28 @@ func (J *EncodeWorker) downloadQueue() {
 			}
 
 			taskTrack := J.terminal.AddTask(job.TaskEncode.Id.String(), DownloadJobStepType)
+			if err := job.Context().Err(); err != nil {
+				taskTrack.Error()
+				J.errorJob(job, taskTrack, err)
+				atomic.AddUint32(&J.prefetchJobs, ^uint32(0))
+				continue
+			}
 
 			J.updateTaskStatus(job, model.DownloadNotification, model.ProgressingNotificationStatus, "")
 			err := J.downloadFile(job, taskTrack)
+			if err == nil && len(job.TaskEncode.SegmentURLs) > 0 {
+				err = J.downloadSegments(job, taskTrack)
+			}
+			if err != nil && J.ctx.Err() != nil {
+				// the worker is stopping, resumeJobs continues the partial download
+				taskTrack.Error()
+				atomic.AddUint32(&J.prefetchJobs, ^uint32(0))
+				continue
+			}
 			if err != nil {
 				J.updateTaskStatus(job, model.DownloadNotification, model.FailedNotificationStatus, err.Error())
 				taskTrack.Error()
-				J.errorJob(job, err)
+				J.errorJob(job, taskTrack, err)
 				atomic.AddUint32(&J.prefetchJobs, ^uint32(0))
 				continue
 			}
@@ -862,16 +1319,22 @@ func (J *EncodeWorker) uploadQueue() {
 				continue
 			}
 			taskTrack := J.terminal.AddTask(job.TaskEncode.Id.String(), UploadJobStepType)
+			if err := job.Context().Err(); err != nil {
+				taskTrack.Error()
+				J.errorJob(job, taskTrack, err)
+				continue
+			}
 			err := J.UploadJob(job, taskTrack)
 			if err != nil {
 				taskTrack.Error()
-				J.errorJob(job, err)
+				J.errorJob(job, taskTrack, err)
 				continue
 			}
 
+			J.uploadLogs(job, taskTrack)
 			J.updateTaskStatus(job, model.JobNotification, model.CompletedNotificationStatus, "")
 			taskTrack.Done()
-			job.Clean()
+			J.finishJob(job)
 		}
 	}
 
@@ -892,10 +1355,20 @@ func (J *EncodeWorker) encodeQueue() {
 			}
 			atomic.AddUint32(&J.prefetchJobs, ^uint32(0))
 			taskTrack := J.terminal.AddTask(job.TaskEncode.Id.String(), EncodeJobStepType)
+			if err := job.Context().Err(); err != nil {
+				taskTrack.Error()
+				J.errorJob(job, taskTrack, err)
+				continue
+			}
 			err := J.encodeVideo(job, taskTrack)
+			if errors.Is(err, ErrKeptOriginal) {
+				taskTrack.Done()
+				J.errorJob(job, taskTrack, err)
+				continue
+			}
 			if err != nil {
 				taskTrack.Error()
-				J.errorJob(job, err)
+				J.errorJob(job, taskTrack, err)
 				continue
 			}
 
@@ -909,153 +1382,411 @@ func (J *EncodeWorker) encodeQueue() {
 func (J *EncodeWorker) encodeVideo(job *model.WorkTaskEncode, track *TaskTracks) error {
 	J.updateTaskStatus(job, model.FFProbeNotification, model.ProgressingNotificationStatus, "")
 	track.Message(string(model.FFProbeNotification))
-	sourceVideoParams, sourceVideoSize, err := J.getVideoParameters(job.SourceFilePath)
+	sourceVideoParams, sourceVideoSize, err := J.getVideoParameters(job.Context(), job.SourceFilePath)
 	if err != nil {
 		J.updateTaskStatus(job, model.FFProbeNotification, model.FailedNotificationStatus, err.Error())
 		return err
 	}
 	J.updateTaskStatus(job, model.FFProbeNotification, model.CompletedNotificationStatus, "")
 
-	videoContainer, err := J.clearData(sourceVideoParams)
+	videoContainer, err := J.clearData(sourceVideoParams, job.TaskEncode.Profile)
 	if err != nil {
 		J.terminal.Warn("error in clear data. Id: %s", J.GetID())
 		return err
 	}
+	sourceDuration := sourceVideoParams.Format.DurationSeconds
+	if err = J.detectInterlace(job, track, videoContainer, sourceDuration); err != nil {
+		return err
+	}
+	if err = J.detectCrop(job, track, videoContainer, sourceDuration); err != nil {
+		return err
+	}
+	// segments get only their cut of the source, the bitrate follows the
+	// whole source analysed by the server
+	bitrateSize, bitrateDuration := sourceVideoSize, sourceDuration
+	if analysis := job.TaskEncode.Analysis; analysis != nil {
+		videoContainer.Video.Crop = detect.ParseCrop(analysis.Crop)
+		videoContainer.Video.Deinterlace = analysis.Deinterlace
+		bitrateSize, bitrateDuration = analysis.Size, analysis.Duration
+	}
+	// joins copy the video of the already encoded segments
+	if profile := job.TaskEncode.Profile; profile != nil && profile.Bitrate.Enabled() && len(job.TaskEncode.SegmentURLs) == 0 {
+		width := outputWidth(profile.MaxWidth, videoContainer.Video.frameWidth())
+		videoContainer.Video.TargetBitrate = targetBitrate(profile.Bitrate, bitrateSize, bitrateDuration, width)
+	}
+	if job.TaskEncode.Segment != nil {
+		// segments carry only the video, audio and subtitles are muxed on join
+		videoContainer.Audios = nil
+		videoContainer.Subtitle = nil
+	}
 	if err = J.PGSMkvExtractDetectAndConvert(job, track, videoContainer); err != nil {
 		return err
 	}
-	J.updateTaskStatus(job, model.FFMPEGSNotification, model.ProgressingNotificationStatus, "")
+	if job.TaskEncode.Profile != nil && job.TaskEncode.Profile.Subtitle.Sidecar {
+		if err = writeSidecars(job.WorkDir, videoContainer.Subtitle); err != nil {
+			return err
+		}
+	}
+	// larger output and quality retries have their own budget
+	largerRetried := false
+	qualityRetries := 0
+	for {
+		encodedVideoParams, err := J.transcode(job, track, videoContainer, sourceDuration, sourceVideoSize)
+		if errors.Is(err, ErrEncodedLarger) {
+			var reencode bool
+			reencode, err = J.handleLargerOutput(job, err, largerRetried)
+			if reencode {
+				largerRetried = true
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		reencode, err := J.verifyQuality(job, track, videoContainer, encodedVideoParams, qualityRetries)
+		if err != nil || !reencode {
+			return err
+		}
+		qualityRetries++
+	}
+}
+
+// transcode runs ffmpeg and checks the encoded file against the source.
+func (J *EncodeWorker) transcode(job *model.WorkTaskEncode, track *TaskTracks, videoContainer *ContainerData, sourceDuration float64, sourceVideoSize int64) (*ffprobe.ProbeData, error) {
+	passes := 1
+	if videoContainer.Video.TargetBitrate > 0 {
+		passes = 2
+	}
+	duration := videoContainer.Video.Duration.Seconds()
+	J.updateTaskProgress(job, encodeProgress(FFMPEGProgress{pass: 1}, 0, duration, passes))
 	track.ResetMessage()
-	track.SetTotal(int64(videoContainer.Video.Duration.Seconds()) * int64(videoContainer.Video.FrameRate))
+	track.UpdateValue(0)
+	track.SetTotal(int64(duration) * int64(videoContainer.Video.FrameRate) * int64(passes))
 	FFMPEGProgressChan := make(chan FFMPEGProgress)
 
 	go func() {
-		lastProgressEvent := float64(0)
-		lastDuration := 0
+		lastProgressEvent := time.Now()
+		lastOutTime := 0.0
+		lastPass := 1
 	loop:
 		for {
 			select {
-			case <-J.ctx.Done():
+			case <-job.Context().Done():
 				return
 			case FFMPEGProgress, open := <-FFMPEGProgressChan:
 				if !open {
 					break loop
 				}
-				encodeFramesIncrement := (FFMPEGProgress.duration - lastDuration) * videoContainer.Video.FrameRate
-				lastDuration = FFMPEGProgress.duration
-
-				track.Increment(encodeFramesIncrement)
+				newPass := FFMPEGProgress.pass != lastPass
+				if newPass {
+					lastPass = FFMPEGProgress.pass
+					lastOutTime = 0
+				}
+				encodeFramesIncrement := int((FFMPEGProgress.outTime - lastOutTime) * float64(videoContainer.Video.FrameRate))
+				if encodeFramesIncrement > 0 {
+					track.Increment(encodeFramesIncrement)
+					lastOutTime = FFMPEGProgress.outTime
+				}
 
-				if FFMPEGProgress.percent-lastProgressEvent > 10 {
-					J.updateTaskStatus(job, model.FFMPEGSNotification, model.ProgressingNotificationStatus, fmt.Sprintf("{\"progress\":\"%.2f\"}", track.PercentDone()))
-					lastProgressEvent = FFMPEGProgress.percent
+				if newPass || time.Since(lastProgressEvent) >= progressEventInterval {
+					J.updateTaskProgress(job, encodeProgress(FFMPEGProgress, track.PercentDone(), duration, passes))
+					lastProgressEvent = time.Now()
 				}
 			}
 		}
 	}()
-	err = J.FFMPEG(job, videoContainer, FFMPEGProgressChan)
+	err := J.FFMPEG(job, videoContainer, FFMPEGProgressChan)
 	if err != nil {
 		J.updateTaskStatus(job, model.FFMPEGSNotification, model.FailedNotificationStatus, err.Error())
-		return err
+		return nil, err
 	}
 	<-time.After(time.Second * 1)
 
-	encodedVideoParams, encodedVideoSize, err := J.getVideoParameters(job.TargetFilePath)
+	encodedVideoParams, encodedVideoSize, err := J.getVideoParameters(job.Context(), job.TargetFilePath)
 	if err != nil {
 		J.updateTaskStatus(job, model.FFMPEGSNotification, model.FailedNotificationStatus, err.Error())
-		return err
+		return nil, err
 	}
-	diffDuration := encodedVideoParams.Format.DurationSeconds - sourceVideoParams.Format.DurationSeconds
+	diffDuration := encodedVideoParams.Format.DurationSeconds - sourceDuration
 	if diffDuration > 60 || diffDuration < -60 {
-		err = fmt.Errorf("source file duration %f is diferent than encoded %f", sourceVideoParams.Format.DurationSeconds, encodedVideoParams.Format.DurationSeconds)
+		err = fmt.Errorf("source file duration %f is diferent than encoded %f", sourceDuration, encodedVideoParams.Format.DurationSeconds)
 		J.updateTaskStatus(job, model.FFMPEGSNotification, model.FailedNotificationStatus, err.Error())
-		return err
+		return nil, err
 	}
-	if encodedVideoSize > sourceVideoSize {
-		err = fmt.Errorf("source file size %d bytes is less than encoded %d bytes", sourceVideoSize, encodedVideoSize)
+	if encodedVideoSize > sourceVideoSize && job.TaskEncode.Segment == nil {
+		err = fmt.Errorf("%w: source file size %d bytes is less than encoded %d bytes", ErrEncodedLarger, sourceVideoSize, encodedVideoSize)
 		J.updateTaskStatus(job, model.FFMPEGSNotification, model.FailedNotificationStatus, err.Error())
-		return err
+		return nil, err
 	}
 	J.updateTaskStatus(job, model.FFMPEGSNotification, model.CompletedNotificationStatus, "")
-	return nil
+	return encodedVideoParams, nil
 }
 
 type FFMPEGGenerator struct {
+	profile        model.EncodingProfile
+	container      string
+	segment        *model.Segment
+	concatInput    string
+	bitrate        int
+	passLogFile    string
+	pass           int
 	inputPaths     []string
-	VideoFilter    string
-	AudioFilter    []string
-	SubtitleFilter []string
-	Metadata       string
+	VideoFilter    []string
+	AudioFilter    [][]string
+	SubtitleFilter [][]string
+	Metadata       []string
 }
 
-func (F *FFMPEGGenerator) setAudioFilters(container *ContainerData) {
+// NewFFMPEGGenerator builds a generator for the given profile, falling back to
+// the default profile for tasks queued without one.
+func NewFFMPEGGenerator(profile *model.EncodingProfile) *FFMPEGGenerator {
+	if profile == nil {
+		defaultProfile := model.DefaultEncodingProfile()
+		profile = &defaultProfile
+	}
+	return &FFMPEGGenerator{
+		profile:   *profile,
+		container: profile.OutputContainer(),
+	}
+}
+
+// setContainer changes the container written, the profile one by default.
+func (F *FFMPEGGenerator) setContainer(container string) {
+	F.container = container
+}
+
+// setSegment encodes a segment, the input is the cut of the source the server
+// sends for it.
+func (F *FFMPEGGenerator) setSegment(segment *model.Segment) {
+	F.segment = segment
+}
+
+// setConcatInput takes the video from the concat list of encoded segments
+// instead of encoding the source one.
+func (F *FFMPEGGenerator) setConcatInput(listPath string) {
+	F.concatInput = listPath
+}
+
+// setBitrate encodes the video at an average bitrate, in kbps, in two passes
+// sharing the statistics in passLogFile. Zero keeps the profile CRF.
+func (F *FFMPEGGenerator) setBitrate(kbps int, passLogFile string) {
+	F.bitrate = kbps
+	F.passLogFile = passLogFile
+}
+
+// setPass selects the pass of a two-pass encode, the video filters have to be
+// set again after changing it.
+func (F *FFMPEGGenerator) setPass(pass int) {
+	F.pass = pass
+}
+
+func (F *FFMPEGGenerator) passes() int {
+	if F.bitrate > 0 && F.concatInput == "" {
+		return 2
+	}
+	return 1
+}
 
-	for index, audioStream := range container.Audios {
+func (F *FFMPEGGenerator) setAudioFilters(container *ContainerData) {
+	policy := F.profile.Audio
+	index := 0
+	for _, audioStream := range container.Audios {
 		//TODO que pasa quan el channelLayout esta empty??
 		title := fmt.Sprintf("%s (%s)", audioStream.Language, audioStream.ChannelLayour)
-		metadata := fmt.Sprintf(" -metadata:s:a:%d \"title=%s\"", index, title)
-		codecQuality := fmt.Sprintf("-c:a:%d %s -vbr %d", index, "libfdk_aac", 5)
-		F.AudioFilter = append(F.AudioFilter, fmt.Sprintf(" -map 0:%d %s %s", audioStream.Id, metadata, codecQuality))
+		if audioStream.Commentary && audioStream.Title != "" {
+			title = audioStream.Title
+		}
+		parameters := []string{"-map", fmt.Sprintf("0:%d", audioStream.Id), fmt.Sprintf("-metadata:s:a:%d", index), "title=" + title}
+		if len(policy.PreferredLanguages) > 0 {
+			parameters = append(parameters, audioDisposition(index, audioStream)...)
+		}
+		passthrough := ((policy.PassthroughLossless && audioStream.isLossless()) || (policy.PassthroughAAC && audioStream.isAAC())) &&
+			passthroughSupported(audioStream, F.container)
+		if passthrough {
+			parameters = append(parameters, fmt.Sprintf("-c:a:%d", index), "copy")
+		} else {
+			parameters = append(parameters, F.audioCodecParameters(index)...)
+		}
+		F.AudioFilter = append(F.AudioFilter, parameters)
+		index++
+
+		if policy.StereoDownmix && audioStream.ChannelsNumber > 2 && !audioStream.Commentary {
+			parameters = []string{"-map", fmt.Sprintf("0:%d", audioStream.Id),
+				fmt.Sprintf("-metadata:s:a:%d", index), fmt.Sprintf("title=%s (stereo)", audioStream.Language),
+				fmt.Sprintf("-disposition:a:%d", index), "0", fmt.Sprintf("-ac:a:%d", index), "2"}
+			F.AudioFilter = append(F.AudioFilter, append(parameters, F.audioCodecParameters(index)...))
+			index++
+		}
 	}
 }
-func (F *FFMPEGGenerator) setVideoFilters(container *ContainerData) {
-	// TODO: Make ffmpeg parameters configurable
-	videoFilterParameters := "\"scale='min(1920,iw)':-1:force_original_aspect_ratio=decrease\""
-	videoEncoderQuality := "-pix_fmt yuv420p10le -c:v libx265 -crf 21 -profile:v main10"
-	//TODO HDR??
-	videoHDR := ""
-	F.VideoFilter = fmt.Sprintf("-map 0:%d -map_chapters -1 -flags +global_header -filter:v %s %s %s", container.Video.Id, videoFilterParameters, videoHDR, videoEncoderQuality)
 
+func (F *FFMPEGGenerator) audioCodecParameters(index int) []string {
+	parameters := []string{fmt.Sprintf("-c:a:%d", index), F.profile.AudioCodec}
+	if F.profile.AudioQuality > 0 {
+		if F.profile.AudioCodec == "libfdk_aac" {
+			parameters = append(parameters, "-vbr", strconv.Itoa(F.profile.AudioQuality))
+		} else {
+			parameters = append(parameters, fmt.Sprintf("-q:a:%d", index), strconv.Itoa(F.profile.AudioQuality))
+		}
+	}
+	if F.profile.AudioBitrate != "" {
+		parameters = append(parameters, fmt.Sprintf("-b:a:%d", index), F.profile.AudioBitrate)
+	}
+	return parameters
+}
+
+// audioDisposition marks the first track, the most preferred language, as the
+// default one and clears the flag of the rest.
+func audioDisposition(index int, audio *Audio) []string {
+	switch {
+	case index == 0:
+		return []string{"-disposition:a:0", "default"}
+	case audio.Commentary:
+		return []string{fmt.Sprintf("-disposition:a:%d", index), "comment"}
+	default:
+		return []string{fmt.Sprintf("-disposition:a:%d", index), "0"}
+	}
+}
+func (F *FFMPEGGenerator) setVideoFilters(container *ContainerData) {
+	if F.concatInput != "" {
+		F.VideoFilter = []string{"-map", fmt.Sprintf("%d:v:0", len(F.inputPaths)), "-c:v", "copy"}
+		return
+	}
+	videoFilters := container.Video.sourceFilters()
+	if F.profile.MaxWidth > 0 {
+		videoFilters = append(videoFilters, fmt.Sprintf("scale='min(%d,iw)':-1:force_original_aspect_ratio=decrease", F.profile.MaxWidth))
+	}
+	parameters := []string{"-map", fmt.Sprintf("0:%d", container.Video.Id), "-flags", "+global_header"}
+	if len(videoFilters) > 0 {
+		parameters = append(parameters, "-filter:v", strings.Join(videoFilters, ","))
+	}
+	var x265Parameters []string
+	if container.Video.HDR != nil {
+		parameters = append(parameters, container.Video.HDR.colorParameters()...)
+		if F.profile.VideoCodec == "libx265" {
+			x265Parameters = append(x265Parameters, container.Video.HDR.x265Parameters())
+		}
+	}
+	// libx265 takes the pass through its own parameters
+	if F.pass > 0 && F.profile.VideoCodec == "libx265" {
+		x265Parameters = append(x265Parameters, fmt.Sprintf("pass=%d:stats=%s.log", F.pass, F.passLogFile))
+	}
+	if len(x265Parameters) > 0 {
+		parameters = append(parameters, "-x265-params", strings.Join(x265Parameters, ":"))
+	}
+	pixelFormat := F.profile.PixelFormat
+	if container.Video.HDR != nil {
+		pixelFormat = container.Video.HDR.pixelFormat(pixelFormat)
+	}
+	if pixelFormat != "" {
+		parameters = append(parameters, "-pix_fmt", pixelFormat)
+	}
+	parameters = append(parameters, "-c:v", F.profile.VideoCodec)
+	if F.bitrate > 0 {
+		parameters = append(parameters, "-b:v", fmt.Sprintf("%dk", F.bitrate))
+	} else if F.profile.CRF > 0 {
+		parameters = append(parameters, "-crf", strconv.Itoa(F.profile.CRF))
+	}
+	if F.profile.Preset != "" {
+		parameters = append(parameters, "-preset", F.profile.Preset)
+	}
+	videoProfile := F.profile.VideoProfile
+	if container.Video.HDR != nil {
+		videoProfile = container.Video.HDR.videoProfile(F.profile.VideoCodec, F.profile.PixelFormat, videoProfile)
+	}
+	if videoProfile != "" {
+		parameters = append(parameters, "-profile:v", videoProfile)
+	}
+	if F.pass > 0 && F.profile.VideoCodec != "libx265" {
+		parameters = append(parameters, "-pass", strconv.Itoa(F.pass), "-passlogfile", F.passLogFile)
+	}
+	F.VideoFilter = parameters
 }
 func (F *FFMPEGGenerator) setSubtFilters(container *ContainerData) {
 	subtInputIndex := 1
-	for index, subtitle := range container.Subtitle {
+	index := 0
+	for _, subtitle := range container.Subtitle {
+		if subtitle.isImageTypeSubtitle() && F.profile.Subtitle.Sidecar {
+			continue
+		}
 		if subtitle.isImageTypeSubtitle() {
-
-			subtitleMap := fmt.Sprintf("-map %d -c:s:%d srt", subtInputIndex, index)
-			subtitleForced := ""
-			subtitleComment := ""
+			parameters := []string{"-map", strconv.Itoa(subtInputIndex), fmt.Sprintf("-c:s:%d", index), model.SubtitleEncoder(F.container)}
 			if subtitle.Forced {
-				subtitleForced = fmt.Sprintf(" -disposition:s:s:%d forced  -disposition:s:s:%d default", index, index)
+				parameters = append(parameters, fmt.Sprintf("-disposition:s:s:%d", index), "forced", fmt.Sprintf("-disposition:s:s:%d", index), "default")
 			}
 			if subtitle.Comment {
-				subtitleComment = fmt.Sprintf(" -disposition:s:s:%d comment", index)
+				parameters = append(parameters, fmt.Sprintf("-disposition:s:s:%d", index), "comment")
 			}
-
-			F.SubtitleFilter = append(F.SubtitleFilter, fmt.Sprintf("%s %s %s -metadata:s:s:%d language=%s -metadata:s:s:%d \"title=%s\" -max_interleave_delta 0", subtitleMap, subtitleForced, subtitleComment, index, subtitle.Language, index, subtitle.Title))
+			parameters = append(parameters, fmt.Sprintf("-metadata:s:s:%d", index), "language="+subtitle.Language,
+				fmt.Sprintf("-metadata:s:s:%d", index), "title="+subtitle.Title, "-max_interleave_delta", "0")
+			F.SubtitleFilter = append(F.SubtitleFilter, parameters)
 			subtInputIndex++
 		} else {
-			F.SubtitleFilter = append(F.SubtitleFilter, fmt.Sprintf("-map 0:%d -c:s:%d copy", subtitle.Id, index))
+			codec := "copy"
+			if F.container == model.ContainerMP4 {
+				codec = model.SubtitleEncoder(F.container)
+			}
+			F.SubtitleFilter = append(F.SubtitleFilter, []string{"-map", fmt.Sprintf("0:%d", subtitle.Id), fmt.Sprintf("-c:s:%d", index), codec})
 		}
-
+		index++
 	}
 }
 func (F *FFMPEGGenerator) setMetadata(container *ContainerData) {
-	F.Metadata = fmt.Sprintf("-metadata encodeParameters='%s'", container.ToJson())
+	if F.segment != nil {
+		F.Metadata = []string{"-map_metadata", "-1", "-map_chapters", "-1"}
+		return
+	}
+	mapMetadata := "0"
+	if F.profile.StripTags {
+		mapMetadata = "-1"
+	}
+	mapChapters := "0"
+	if F.profile.StripChapters {
+		mapChapters = "-1"
+	}
+	F.Metadata = []string{"-map_metadata", mapMetadata, "-map_chapters", mapChapters}
+	// MP4 has no attachments
+	if !F.profile.StripAttachments && F.container != model.ContainerMP4 {
+		F.Metadata = append(F.Metadata, "-map", "0:t?", "-c:t", "copy")
+	}
+	F.Metadata = append(F.Metadata, "-metadata", "encodeParameters="+container.ToJson())
 }
-func (F *FFMPEGGenerator) buildArguments(threads uint8, outputFilePath string) string {
-	coreParameters := fmt.Sprintf("-hide_banner  -threads %d", threads)
-	inputsParameters := ""
+
+// buildArguments returns the ffmpeg argument vector, every path, title and
+// filter is a single argument whatever characters it holds.
+func (F *FFMPEGGenerator) buildArguments(threads uint8, outputFilePath string) []string {
+	arguments := []string{"-hide_banner", "-nostats", "-progress", "pipe:1", "-threads", strconv.Itoa(int(threads))}
 	for _, input := range F.inputPaths {
-		inputsParameters = fmt.Sprintf("%s -i \"%s\"", inputsParameters, input)
+		arguments = append(arguments, "-i", input)
+	}
+	if F.concatInput != "" {
+		arguments = append(arguments, "-f", "concat", "-safe", "0", "-i", F.concatInput)
+	}
+	arguments = append(arguments, "-max_muxing_queue_size", "9999")
+	arguments = append(arguments, F.VideoFilter...)
+	if F.pass == 1 {
+		// the first pass only analyses the video
+		return append(arguments, "-an", "-sn", "-dn", "-f", "null", os.DevNull, "-y")
 	}
-	//-ss 900 -t 10
-	audioParameters := ""
 	for _, audio := range F.AudioFilter {
-		audioParameters = fmt.Sprintf("%s %s", audioParameters, audio)
+		arguments = append(arguments, audio...)
 	}
-	subtParameters := ""
 	for _, subt := range F.SubtitleFilter {
-		subtParameters = fmt.Sprintf("%s %s", subtParameters, subt)
+		arguments = append(arguments, subt...)
 	}
-
-	return fmt.Sprintf("%s %s -max_muxing_queue_size 9999 %s %s %s %s %s -y", coreParameters, inputsParameters, F.VideoFilter, audioParameters, subtParameters, F.Metadata, outputFilePath)
+	arguments = append(arguments, F.Metadata...)
+	if F.container == model.ContainerMP4 {
+		// the index first so players start before the download ends, and the
+		// custom tags like encodeParameters kept
+		arguments = append(arguments, "-movflags", "+faststart+use_metadata_tags")
+	}
+	return append(arguments, "-y", outputFilePath)
 }
 
 func (F *FFMPEGGenerator) setInputFilters(container *ContainerData, sourceFilePath string, tempPath string) {
 	F.inputPaths = append(F.inputPaths, sourceFilePath)
 	inputIndex := 0
-	if container.HaveImageTypeSubtitle() {
+	if container.HaveImageTypeSubtitle() && !F.profile.Subtitle.Sidecar {
 		for _, subt := range container.Subtitle {
 			if subt.isImageTypeSubtitle() {
 				inputIndex++
@@ -1066,9 +1797,20 @@ func (F *FFMPEGGenerator) setInputFilters(container *ContainerData, sourceFilePa
 }
 
 type Video struct {
-	Id        uint8
-	Duration  time.Duration
-	FrameRate int
+	Id         uint8
+	Duration   time.Duration
+	FrameRate  int
+	Codec      string
+	FieldOrder string
+	Width      int
+	Height     int
+	HDR        *HDRMetadata
+	// Deinterlace is the deinterlacing filter of interlaced sources.
+	Deinterlace string
+	// Crop is the black bar free area of the frame, nil when not cropped.
+	Crop *detect.Crop
+	// TargetBitrate is the average bitrate, in kbps, of a two-pass encode.
+	TargetBitrate int
 }
 type Audio struct {
 	Id             uint8
@@ -1079,6 +1821,10 @@ type Audio struct {
 	Default        bool
 	Bitrate        uint
 	Title          string
+	Codec          string
+	Profile        string
+	Original       bool
+	Commentary     bool
 }
 type Subtitle struct {
 	Id       uint8
@@ -1087,6 +1833,7 @@ type Subtitle struct {
 	Comment  bool
 	Format   string
 	Title    string
+	SDH      bool
 }
 type ContainerData struct {
 	Video    *Video
@@ -13,6 +13,8 @@ func TestEncodeWorker_IsTypeAccepted(t *testing.T) {
 		expected bool
 	}{
 		{"encode", true},
+		{"encodesegment", true},
+		{"joinsegments", true},
 		{"pgstosrt", false},
 		{"unknown", false},
 		{"", false},
@@ -108,28 +110,28 @@ func TestFFMPEGProgress(t *testing.T) {
 	tests := []struct {
 		name     string
 		progress FFMPEGProgress
-		duration int
+		outTime  float64
 		speed    float64
 		percent  float64
 	}{
 		{
 			name:     "normal progress",
-			progress: FFMPEGProgress{duration: 60, speed: 1.5, percent: 50.0},
-			duration: 60,
+			progress: FFMPEGProgress{outTime: 60, speed: 1.5, percent: 50.0},
+			outTime:  60,
 			speed:    1.5,
 			percent:  50.0,
 		},
 		{
 			name:     "zero values",
-			progress: FFMPEGProgress{duration: 0, speed: 0, percent: 0},
-			duration: 0,
+			progress: FFMPEGProgress{outTime: 0, speed: 0, percent: 0},
+			outTime:  0,
 			speed:    0,
 			percent:  0,
 		},
 		{
 			name:     "high speed",
-			progress: FFMPEGProgress{duration: 120, speed: 10.5, percent: 100.0},
-			duration: 120,
+			progress: FFMPEGProgress{outTime: 120, speed: 10.5, percent: 100.0},
+			outTime:  120,
 			speed:    10.5,
 			percent:  100.0,
 		},
@@ -137,8 +139,8 @@ func TestFFMPEGProgress(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.progress.duration != tt.duration {
-				t.Errorf("duration = %d, want %d", tt.progress.duration, tt.duration)
+			if tt.progress.outTime != tt.outTime {
+				t.Errorf("outTime = %f, want %f", tt.progress.outTime, tt.outTime)
 			}
 			if tt.progress.speed != tt.speed {
 				t.Errorf("speed = %f, want %f", tt.progress.speed, tt.speed)
@@ -1,117 +1,369 @@
 package task
 
-import "testing"
+import (
+	"encoding/json"
+	"gearr/helper/detect"
+	"gearr/model"
+	"reflect"
+	"strings"
+	"testing"
+)
 
-func TestDurToSec(t *testing.T) {
+func joinArguments(arguments []string) string {
+	return strings.Join(arguments, " ")
+}
+
+// argumentAfter returns the argument following the first flag argument.
+func argumentAfter(arguments []string, flag string) string {
+	for i, argument := range arguments {
+		if argument == flag && i+1 < len(arguments) {
+			return arguments[i+1]
+		}
+	}
+	return ""
+}
+
+func TestFFProbeFrameRate(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected int
+		input       string
+		expected    int
+		expectError bool
 	}{
-		{"00:00:00", 0},
-		{"00:00:01", 1},
-		{"00:01:00", 60},
-		{"01:00:00", 3600},
-		{"01:30:45", 5445},
-		{"12:34:56", 45296},
-		{"", 0},
-		{"invalid", 0},
-		{"00:00", 0},
+		{"24/1", 24, false},
+		{"30/1", 30, false},
+		{"30000/1001", 29, false},
+		{"24000/1001", 23, false},
+		{"60/1", 60, false},
+		{"25/1", 25, false},
+		{"", 0, true},
+		{"24", 0, true},
+		{"24/0", 0, true},
+		{"abc/def", 0, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			result := durToSec(tt.input)
-			if result != tt.expected {
-				t.Errorf("durToSec(%q) = %d, want %d", tt.input, result, tt.expected)
+			result, err := FFProbeFrameRate(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("FFProbeFrameRate(%q) expected error, got nil", tt.input)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("FFProbeFrameRate(%q) unexpected error: %v", tt.input, err)
+				}
+				if result != tt.expected {
+					t.Errorf("FFProbeFrameRate(%q) = %d, want %d", tt.input, result, tt.expected)
+				}
 			}
 		})
 	}
 }
 
-func TestGetSpeed(t *testing.T) {
+func TestFFMPEGGenerator_setVideoFilters(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected float64
+		name        string
+		profile     *model.EncodingProfile
+		deinterlace string
+		crop        *detect.Crop
+		contains    []string
+		excludes    []string
 	}{
-		{"speed=1.0x", 1.0},
-		{"speed=2.5x", 2.5},
-		{"speed=0.5x", 0.5},
-		{"speed=100.123x", 100.123},
-		{"frame=  123 fps= 30 speed=1.5x", 1.5},
-		{"no speed here", -1},
-		{"speed=", -1},
-		{"", -1},
+		{
+			name:     "nil profile uses default",
+			profile:  nil,
+			contains: []string{"-c:v libx265", "-crf 21", "-profile:v main10", "-pix_fmt yuv420p10le", "min(1920,iw)"},
+		},
+		{
+			name: "custom profile",
+			profile: &model.EncodingProfile{
+				Name:        "live-action",
+				VideoCodec:  "libx264",
+				CRF:         18,
+				Preset:      "slow",
+				MaxWidth:    3840,
+				PixelFormat: "yuv420p",
+				AudioCodec:  "aac",
+			},
+			contains: []string{"-c:v libx264", "-crf 18", "-preset slow", "-pix_fmt yuv420p", "min(3840,iw)"},
+			excludes: []string{"-profile:v", "libx265"},
+		},
+		{
+			name: "no scale limit",
+			profile: &model.EncodingProfile{
+				Name:       "archive",
+				VideoCodec: "libx265",
+				Preset:     "veryslow",
+				AudioCodec: "copy",
+			},
+			contains: []string{"-c:v libx265", "-preset veryslow"},
+			excludes: []string{"-filter:v", "-crf", "-pix_fmt"},
+		},
+		{
+			name:     "crop before scale",
+			profile:  nil,
+			crop:     &detect.Crop{Width: 3840, Height: 1600, X: 0, Y: 280},
+			contains: []string{"-filter:v crop=3840:1600:0:280,scale='min(1920,iw)'"},
+		},
+		{
+			name:        "deinterlace first",
+			profile:     nil,
+			deinterlace: "bwdif=mode=send_frame:parity=tff:deint=all",
+			crop:        &detect.Crop{Width: 720, Height: 432, X: 0, Y: 72},
+			contains:    []string{"-filter:v bwdif=mode=send_frame:parity=tff:deint=all,crop=720:432:0:72,scale="},
+		},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := getSpeed(tt.input)
-			if result != tt.expected {
-				t.Errorf("getSpeed(%q) = %f, want %f", tt.input, result, tt.expected)
+		t.Run(tt.name, func(t *testing.T) {
+			container := &ContainerData{Video: &Video{Id: 0, Deinterlace: tt.deinterlace, Crop: tt.crop}}
+			ffmpeg := NewFFMPEGGenerator(tt.profile)
+			ffmpeg.setVideoFilters(container)
+			for _, c := range tt.contains {
+				if !strings.Contains(joinArguments(ffmpeg.VideoFilter), c) {
+					t.Errorf("VideoFilter %q does not contain %q", ffmpeg.VideoFilter, c)
+				}
+			}
+			for _, e := range tt.excludes {
+				if strings.Contains(joinArguments(ffmpeg.VideoFilter), e) {
+					t.Errorf("VideoFilter %q should not contain %q", ffmpeg.VideoFilter, e)
+				}
 			}
 		})
 	}
 }
 
-func TestGetDuration(t *testing.T) {
+func TestFFMPEGGenerator_setAudioFilters(t *testing.T) {
+	container := &ContainerData{
+		Audios: []*Audio{{Id: 1, Language: "eng", ChannelLayour: "5.1"}},
+	}
+
 	tests := []struct {
-		input    string
-		expected int
+		name    string
+		profile *model.EncodingProfile
+		want    string
 	}{
-		{"frame=123 time=00:00:00 bitrate=1000", 0},
-		{"frame=456 time=00:00:01 speed=1.5x", 1},
-		{"frame=789 time=00:01:30 bitrate=2000", 90},
-		{"frame=000 time=01:00:00 speed=2.0x", 3600},
-		{"frame=123 time=00:05:30 bitrate=1000", 330},
-		{"no time here", -1},
-		{"time=", -1},
-		{"time=00:00:00", -1},
-		{"", -1},
+		{"default profile", nil, "-c:a:0 libfdk_aac -vbr 5"},
+		{"native aac quality", &model.EncodingProfile{AudioCodec: "aac", AudioQuality: 2}, "-c:a:0 aac -q:a:0 2"},
+		{"bitrate", &model.EncodingProfile{AudioCodec: "libopus", AudioBitrate: "128k"}, "-c:a:0 libopus -b:a:0 128k"},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := getDuration(tt.input)
-			if result != tt.expected {
-				t.Errorf("getDuration(%q) = %d, want %d", tt.input, result, tt.expected)
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg := NewFFMPEGGenerator(tt.profile)
+			ffmpeg.setAudioFilters(container)
+			if len(ffmpeg.AudioFilter) != 1 {
+				t.Fatalf("len(AudioFilter) = %d, want 1", len(ffmpeg.AudioFilter))
+			}
+			if !strings.HasSuffix(joinArguments(ffmpeg.AudioFilter[0]), tt.want) {
+				t.Errorf("AudioFilter = %q, want suffix %q", ffmpeg.AudioFilter[0], tt.want)
 			}
 		})
 	}
 }
 
-func TestFFProbeFrameRate(t *testing.T) {
+func TestFFMPEGGenerator_setMetadata(t *testing.T) {
+	container := &ContainerData{Video: &Video{Id: 0}}
+
 	tests := []struct {
-		input       string
-		expected    int
-		expectError bool
+		name     string
+		profile  *model.EncodingProfile
+		contains []string
+		excludes []string
 	}{
-		{"24/1", 24, false},
-		{"30/1", 30, false},
-		{"30000/1001", 29, false},
-		{"24000/1001", 23, false},
-		{"60/1", 60, false},
-		{"25/1", 25, false},
-		{"", 0, true},
-		{"24", 0, true},
-		{"24/0", 0, true},
-		{"abc/def", 0, true},
+		{
+			name:     "keep by default",
+			profile:  nil,
+			contains: []string{"-map_metadata 0", "-map_chapters 0", "-map 0:t? -c:t copy", "-metadata encodeParameters="},
+		},
+		{
+			name:     "strip everything",
+			profile:  &model.EncodingProfile{StripChapters: true, StripAttachments: true, StripTags: true},
+			contains: []string{"-map_metadata -1", "-map_chapters -1", "-metadata encodeParameters="},
+			excludes: []string{"0:t?"},
+		},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result, err := FFProbeFrameRate(tt.input)
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("FFProbeFrameRate(%q) expected error, got nil", tt.input)
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg := NewFFMPEGGenerator(tt.profile)
+			ffmpeg.setMetadata(container)
+			for _, c := range tt.contains {
+				if !strings.Contains(joinArguments(ffmpeg.Metadata), c) {
+					t.Errorf("Metadata %q does not contain %q", ffmpeg.Metadata, c)
 				}
-			} else {
-				if err != nil {
-					t.Errorf("FFProbeFrameRate(%q) unexpected error: %v", tt.input, err)
+			}
+			for _, e := range tt.excludes {
+				if strings.Contains(joinArguments(ffmpeg.Metadata), e) {
+					t.Errorf("Metadata %q should not contain %q", ffmpeg.Metadata, e)
 				}
-				if result != tt.expected {
-					t.Errorf("FFProbeFrameRate(%q) = %d, want %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFFMPEGGenerator_buildArgumentsSegment(t *testing.T) {
+	container := &ContainerData{Video: &Video{Id: 0}}
+
+	ffmpeg := NewFFMPEGGenerator(nil)
+	ffmpeg.setSegment(&model.Segment{Index: 1, Start: 300.5, End: 600.25})
+	ffmpeg.setInputFilters(container, "source.mkv", "/tmp")
+	ffmpeg.setVideoFilters(container)
+	ffmpeg.setAudioFilters(container)
+	ffmpeg.setSubtFilters(container)
+	ffmpeg.setMetadata(container)
+	arguments := joinArguments(ffmpeg.buildArguments(4, "segment.mkv"))
+
+	for _, c := range []string{"-i source.mkv", "-c:v libx265", "-map_metadata -1 -map_chapters -1"} {
+		if !strings.Contains(arguments, c) {
+			t.Errorf("arguments %q do not contain %q", arguments, c)
+		}
+	}
+	// the server sends only the cut of the source of the segment
+	if strings.Contains(arguments, "-ss") {
+		t.Errorf("segment arguments %q should not seek the input", arguments)
+	}
+	if strings.Contains(arguments, "-c:a") {
+		t.Errorf("segment arguments %q should not contain audio", arguments)
+	}
+}
+
+func TestFFMPEGGenerator_buildArgumentsJoin(t *testing.T) {
+	container := &ContainerData{
+		Video:  &Video{Id: 0},
+		Audios: []*Audio{{Id: 1, Language: "eng", ChannelLayour: "5.1"}},
+	}
+
+	ffmpeg := NewFFMPEGGenerator(nil)
+	ffmpeg.setConcatInput("segments.txt")
+	ffmpeg.setInputFilters(container, "source.mkv", "/tmp")
+	ffmpeg.setVideoFilters(container)
+	ffmpeg.setAudioFilters(container)
+	ffmpeg.setSubtFilters(container)
+	ffmpeg.setMetadata(container)
+	arguments := joinArguments(ffmpeg.buildArguments(4, "joined.mkv"))
+
+	for _, c := range []string{"-i source.mkv -f concat -safe 0 -i segments.txt", "-map 1:v:0 -c:v copy", "-map 0:1", "-map_chapters 0"} {
+		if !strings.Contains(arguments, c) {
+			t.Errorf("arguments %q do not contain %q", arguments, c)
+		}
+	}
+	if strings.Contains(arguments, "libx265") {
+		t.Errorf("join arguments %q should not encode video", arguments)
+	}
+}
+
+func TestFFMPEGGenerator_buildArgumentsTwoPass(t *testing.T) {
+	container := &ContainerData{
+		Video:  &Video{Id: 0},
+		Audios: []*Audio{{Id: 1, Language: "eng", ChannelLayour: "5.1"}},
+	}
+
+	tests := []struct {
+		name     string
+		profile  *model.EncodingProfile
+		pass     int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "x265 first pass",
+			profile:  nil,
+			pass:     1,
+			contains: []string{"-b:v 4000k", "-x265-params pass=1:stats=/tmp/ffmpeg2pass.log", "-an -sn -dn -f null"},
+			excludes: []string{"-crf", "-c:a", "encoded.mkv"},
+		},
+		{
+			name:     "x265 second pass",
+			profile:  nil,
+			pass:     2,
+			contains: []string{"-b:v 4000k", "pass=2:stats=/tmp/ffmpeg2pass.log", "-c:a:0", "encoded.mkv"},
+			excludes: []string{"-f null"},
+		},
+		{
+			name:     "x264 second pass",
+			profile:  &model.EncodingProfile{Name: "archive", VideoCodec: "libx264", CRF: 20, AudioCodec: "aac"},
+			pass:     2,
+			contains: []string{"-b:v 4000k", "-pass 2 -passlogfile /tmp/ffmpeg2pass", "encoded.mkv"},
+			excludes: []string{"-crf", "-x265-params"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpeg := NewFFMPEGGenerator(tt.profile)
+			ffmpeg.setBitrate(4000, "/tmp/ffmpeg2pass")
+			if ffmpeg.passes() != 2 {
+				t.Fatalf("passes() = %d, want 2", ffmpeg.passes())
+			}
+			ffmpeg.setPass(tt.pass)
+			ffmpeg.setInputFilters(container, "source.mkv", "/tmp")
+			ffmpeg.setVideoFilters(container)
+			ffmpeg.setAudioFilters(container)
+			ffmpeg.setSubtFilters(container)
+			ffmpeg.setMetadata(container)
+			arguments := joinArguments(ffmpeg.buildArguments(4, "encoded.mkv"))
+			for _, c := range tt.contains {
+				if !strings.Contains(arguments, c) {
+					t.Errorf("arguments %q do not contain %q", arguments, c)
+				}
+			}
+			for _, e := range tt.excludes {
+				if strings.Contains(arguments, e) {
+					t.Errorf("arguments %q should not contain %q", arguments, e)
 				}
 			}
 		})
 	}
 }
+
+func TestFFMPEGGenerator_buildArgumentsHostileNames(t *testing.T) {
+	sourcePath := `/work/Movie "Director's Cut" $(rm -rf ~) & more.mkv`
+	outputPath := `/work/Movie "Director's Cut" $(rm -rf ~) & more-encoded.mkv`
+	audioTitle := `Commentary with "Bob" & 'Alice'; echo`
+	subtitleTitle := `Forced "signs" it's \ $HOME`
+	container := &ContainerData{
+		Video:    &Video{Id: 0},
+		Audios:   []*Audio{{Id: 1, Language: "eng", ChannelLayour: "stereo", Commentary: true, Title: audioTitle}},
+		Subtitle: []*Subtitle{{Id: 2, Language: "eng", Format: "hdmv_pgs_subtitle", Forced: true, Title: subtitleTitle}},
+	}
+
+	ffmpeg := NewFFMPEGGenerator(nil)
+	ffmpeg.setInputFilters(container, sourcePath, "/work")
+	ffmpeg.setVideoFilters(container)
+	ffmpeg.setAudioFilters(container)
+	ffmpeg.setSubtFilters(container)
+	ffmpeg.setMetadata(container)
+	arguments := ffmpeg.buildArguments(4, outputPath)
+
+	if got := argumentAfter(arguments, "-i"); got != sourcePath {
+		t.Errorf("input = %q, want %q", got, sourcePath)
+	}
+	if got := arguments[len(arguments)-1]; got != outputPath {
+		t.Errorf("output = %q, want %q", got, outputPath)
+	}
+	if got := argumentAfter(arguments, "-metadata:s:a:0"); got != "title="+audioTitle {
+		t.Errorf("audio title = %q, want %q", got, "title="+audioTitle)
+	}
+	if got := ffmpeg.SubtitleFilter[0][len(ffmpeg.SubtitleFilter[0])-3]; got != "title="+subtitleTitle {
+		t.Errorf("subtitle title = %q, want %q", got, "title="+subtitleTitle)
+	}
+
+	encodeParameters := strings.TrimPrefix(argumentAfter(arguments, "-metadata"), "encodeParameters=")
+	decoded := &ContainerData{}
+	if err := json.Unmarshal([]byte(encodeParameters), decoded); err != nil {
+		t.Fatalf("encodeParameters %q is not valid JSON: %v", encodeParameters, err)
+	}
+	if decoded.Audios[0].Title != audioTitle || decoded.Subtitle[0].Title != subtitleTitle {
+		t.Errorf("encodeParameters = %s, want the titles unchanged", encodeParameters)
+	}
+}
+
+func TestMKVExtractArguments(t *testing.T) {
+	sourcePath := `/work/it's a "test" $(id).mkv`
+	arguments := mkvExtractArguments(sourcePath, []*Subtitle{{Id: 3}, {Id: 5}})
+	expected := []string{"tracks", sourcePath, "3:3.sup", "5:5.sup"}
+	if !reflect.DeepEqual(arguments, expected) {
+		t.Errorf("mkvExtractArguments() = %q, want %q", arguments, expected)
+	}
+}
@@ -0,0 +1,164 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const (
+	dolbyVisionSideDataType = "DOVI configuration record"
+	transferPQ              = "smpte2084"
+	transferHLG             = "arib-std-b67"
+)
+
+var ErrDolbyVisionUnsupported = errors.New("dolby vision stream without HDR10/HLG compatible base layer")
+
+// HDRMetadata holds the color information that has to be carried to the
+// encoded stream, otherwise HDR sources are tagged as SDR and look washed out.
+type HDRMetadata struct {
+	ColorPrimaries string
+	ColorTransfer  string
+	ColorSpace     string
+	ColorRange     string
+	MasterDisplay  string
+	MaxCLL         string
+}
+
+// IsHLG reports whether the stream uses the hybrid log-gamma transfer.
+func (H *HDRMetadata) IsHLG() bool {
+	return H.ColorTransfer == transferHLG
+}
+
+// parseHDRMetadata extracts HDR10/HLG metadata from a video stream. It returns
+// nil for SDR streams and ErrDolbyVisionUnsupported for Dolby Vision profiles
+// whose base layer can not be played without the enhancement data.
+func parseHDRMetadata(stream *ffprobe.Stream) (*HDRMetadata, error) {
+	if err := checkDolbyVision(stream); err != nil {
+		return nil, err
+	}
+	if stream.ColorTransfer != transferPQ && stream.ColorTransfer != transferHLG {
+		return nil, nil
+	}
+
+	hdr := &HDRMetadata{
+		ColorPrimaries: stream.ColorPrimaries,
+		ColorTransfer:  stream.ColorTransfer,
+		ColorSpace:     stream.ColorSpace,
+		ColorRange:     stream.ColorRange,
+	}
+	if masterDisplay, err := stream.SideDataList.GetMasteringDisplayMetadata(); err == nil {
+		hdr.MasterDisplay = formatMasterDisplay(masterDisplay)
+	}
+	if lightLevel, err := stream.SideDataList.GetContentLightLevel(); err == nil {
+		hdr.MaxCLL = fmt.Sprintf("%d,%d", lightLevel.MaxContent, lightLevel.MaxAverage)
+	}
+	return hdr, nil
+}
+
+func checkDolbyVision(stream *ffprobe.Stream) error {
+	dovi, err := stream.SideDataList.FindUnknownSideData(dolbyVisionSideDataType)
+	if err != nil {
+		return nil
+	}
+	profile, _ := ffprobe.Tags(*dovi).GetInt("dv_profile")
+	compatibility, compatibilityErr := ffprobe.Tags(*dovi).GetInt("dv_bl_signal_compatibility_id")
+	// Profile 5 and compatibility id 0 mean the base layer uses a proprietary
+	// color space, re-encoding it without the RPU would produce wrong colors.
+	if profile == 5 || (compatibilityErr == nil && compatibility == 0) {
+		return fmt.Errorf("%w: profile %d, compatibility id %d", ErrDolbyVisionUnsupported, profile, compatibility)
+	}
+	return nil
+}
+
+// formatMasterDisplay converts the mastering display metadata to the x265
+// master-display syntax, chromaticity in 0.00002 units and luminance in 0.0001 units.
+func formatMasterDisplay(m *ffprobe.SideDataMasteringDisplayMetadata) string {
+	chroma := func(value ffprobe.FlexFloat) int64 {
+		return int64(math.Round(float64(value) * 50000))
+	}
+	luminance := func(value ffprobe.FlexFloat) int64 {
+		return int64(math.Round(float64(value) * 10000))
+	}
+	return fmt.Sprintf("G(%d,%d)B(%d,%d)R(%d,%d)WP(%d,%d)L(%d,%d)",
+		chroma(m.GreenX), chroma(m.GreenY),
+		chroma(m.BlueX), chroma(m.BlueY),
+		chroma(m.RedX), chroma(m.RedY),
+		chroma(m.WhitePointX), chroma(m.WhitePointY),
+		luminance(m.MaxLuminance), luminance(m.MinLuminance))
+}
+
+// colorParameters returns the ffmpeg output options that tag the stream with
+// the source color information, valid for every encoder.
+func (H *HDRMetadata) colorParameters() []string {
+	var parameters []string
+	if H.ColorPrimaries != "" {
+		parameters = append(parameters, "-color_primaries", H.ColorPrimaries)
+	}
+	if H.ColorTransfer != "" {
+		parameters = append(parameters, "-color_trc", H.ColorTransfer)
+	}
+	if H.ColorSpace != "" {
+		parameters = append(parameters, "-colorspace", H.ColorSpace)
+	}
+	if H.ColorRange != "" {
+		parameters = append(parameters, "-color_range", H.ColorRange)
+	}
+	return parameters
+}
+
+// pixelFormat keeps a 10 bit output, HDR transfers are meaningless in 8 bit.
+func (H *HDRMetadata) pixelFormat(profilePixelFormat string) string {
+	if strings.Contains(profilePixelFormat, "10") || strings.Contains(profilePixelFormat, "12") {
+		return profilePixelFormat
+	}
+	return "yuv420p10le"
+}
+
+// tenBitVideoProfiles are the 10 bit profiles of the encoders whose 8 bit
+// profiles ffmpeg refuses with a 10 bit output.
+var tenBitVideoProfiles = map[string]map[string]string{
+	"libx264": {"baseline": "high10", "main": "high10", "high": "high10"},
+	"libx265": {"main": "main10", "main-intra": "main10-intra"},
+}
+
+// videoProfile follows pixelFormat, moving the 8 bit profile of the encoder
+// to its 10 bit one when the output is forced to 10 bit.
+func (H *HDRMetadata) videoProfile(encoder string, profilePixelFormat string, profile string) string {
+	if H.pixelFormat(profilePixelFormat) == profilePixelFormat {
+		return profile
+	}
+	if tenBitProfile, ok := tenBitVideoProfiles[encoder][profile]; ok {
+		return tenBitProfile
+	}
+	return profile
+}
+
+// x265Parameters returns the value for -x265-params so the HDR SEI messages
+// are written in the bitstream.
+func (H *HDRMetadata) x265Parameters() string {
+	var parameters []string
+	if H.ColorPrimaries != "" {
+		parameters = append(parameters, "colorprim="+H.ColorPrimaries)
+	}
+	if H.ColorTransfer != "" {
+		parameters = append(parameters, "transfer="+H.ColorTransfer)
+	}
+	if H.ColorSpace != "" {
+		parameters = append(parameters, "colormatrix="+H.ColorSpace)
+	}
+	if H.MasterDisplay != "" {
+		parameters = append(parameters, "master-display="+H.MasterDisplay)
+	}
+	if H.MaxCLL != "" {
+		parameters = append(parameters, "max-cll="+H.MaxCLL)
+	}
+	if !H.IsHLG() {
+		parameters = append(parameters, "hdr10=1", "hdr10-opt=1")
+	}
+	parameters = append(parameters, "repeat-headers=1")
+	return strings.Join(parameters, ":")
+}
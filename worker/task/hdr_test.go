@@ -0,0 +1,179 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"gearr/model"
+	"strings"
+	"testing"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const hdr10StreamJSON = `{
+	"index": 0,
+	"codec_name": "hevc",
+	"codec_type": "video",
+	"pix_fmt": "yuv420p10le",
+	"color_range": "tv",
+	"color_space": "bt2020nc",
+	"color_transfer": "smpte2084",
+	"color_primaries": "bt2020",
+	"side_data_list": [
+		{
+			"side_data_type": "Mastering display metadata",
+			"red_x": "34000/50000",
+			"red_y": "16000/50000",
+			"green_x": "13250/50000",
+			"green_y": "34500/50000",
+			"blue_x": "7500/50000",
+			"blue_y": "3000/50000",
+			"white_point_x": "15635/50000",
+			"white_point_y": "16450/50000",
+			"min_luminance": "50/10000",
+			"max_luminance": "10000000/10000"
+		},
+		{
+			"side_data_type": "Content light level metadata",
+			"max_content": 1000,
+			"max_average": 400
+		}
+	]
+}`
+
+func unmarshalStream(t *testing.T, data string) *ffprobe.Stream {
+	t.Helper()
+	stream := &ffprobe.Stream{}
+	if err := json.Unmarshal([]byte(data), stream); err != nil {
+		t.Fatalf("error unmarshalling stream: %v", err)
+	}
+	return stream
+}
+
+func TestParseHDRMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		stream        string
+		expected      *HDRMetadata
+		expectedError error
+	}{
+		{
+			name:     "SDR",
+			stream:   `{"index": 0, "codec_type": "video", "color_transfer": "bt709", "color_primaries": "bt709"}`,
+			expected: nil,
+		},
+		{
+			name:   "HDR10",
+			stream: hdr10StreamJSON,
+			expected: &HDRMetadata{
+				ColorPrimaries: "bt2020",
+				ColorTransfer:  "smpte2084",
+				ColorSpace:     "bt2020nc",
+				ColorRange:     "tv",
+				MasterDisplay:  "G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,50)",
+				MaxCLL:         "1000,400",
+			},
+		},
+		{
+			name:   "HLG",
+			stream: `{"index": 0, "codec_type": "video", "color_transfer": "arib-std-b67", "color_primaries": "bt2020", "color_space": "bt2020nc"}`,
+			expected: &HDRMetadata{
+				ColorPrimaries: "bt2020",
+				ColorTransfer:  "arib-std-b67",
+				ColorSpace:     "bt2020nc",
+			},
+		},
+		{
+			name:          "Dolby Vision profile 5",
+			stream:        `{"index": 0, "codec_type": "video", "side_data_list": [{"side_data_type": "DOVI configuration record", "dv_profile": 5, "dv_bl_signal_compatibility_id": 0}]}`,
+			expectedError: ErrDolbyVisionUnsupported,
+		},
+		{
+			name:   "Dolby Vision profile 8.1 keeps HDR10 base layer",
+			stream: `{"index": 0, "codec_type": "video", "color_transfer": "smpte2084", "color_primaries": "bt2020", "side_data_list": [{"side_data_type": "DOVI configuration record", "dv_profile": 8, "dv_bl_signal_compatibility_id": 1}]}`,
+			expected: &HDRMetadata{
+				ColorPrimaries: "bt2020",
+				ColorTransfer:  "smpte2084",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr, err := parseHDRMetadata(unmarshalStream(t, tt.stream))
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.expected == nil {
+				if hdr != nil {
+					t.Errorf("expected no HDR metadata, got %+v", hdr)
+				}
+				return
+			}
+			if hdr == nil || *hdr != *tt.expected {
+				t.Errorf("parseHDRMetadata() = %+v, want %+v", hdr, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHDRMetadata_x265Parameters(t *testing.T) {
+	hdr, err := parseHDRMetadata(unmarshalStream(t, hdr10StreamJSON))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "colorprim=bt2020:transfer=smpte2084:colormatrix=bt2020nc:master-display=G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,50):max-cll=1000,400:hdr10=1:hdr10-opt=1:repeat-headers=1"
+	if result := hdr.x265Parameters(); result != expected {
+		t.Errorf("x265Parameters() = %s, want %s", result, expected)
+	}
+
+	hlg := &HDRMetadata{ColorPrimaries: "bt2020", ColorTransfer: "arib-std-b67"}
+	if result := hlg.x265Parameters(); strings.Contains(result, "hdr10") {
+		t.Errorf("HLG parameters should not enable hdr10, got %s", result)
+	}
+}
+
+func TestFFMPEGGenerator_setVideoFiltersHDR(t *testing.T) {
+	hdr := &HDRMetadata{ColorPrimaries: "bt2020", ColorTransfer: "smpte2084", ColorSpace: "bt2020nc"}
+	profile := &model.EncodingProfile{Name: "sdr", VideoCodec: "libx265", PixelFormat: "yuv420p", VideoProfile: "main", AudioCodec: "aac"}
+	generator := NewFFMPEGGenerator(profile)
+	generator.setVideoFilters(&ContainerData{Video: &Video{Id: 0, HDR: hdr}})
+
+	for _, expected := range []string{"-pix_fmt yuv420p10le", "-color_trc smpte2084", "-x265-params colorprim=bt2020", "-profile:v main10"} {
+		if !strings.Contains(joinArguments(generator.VideoFilter), expected) {
+			t.Errorf("expected %q in %q", expected, generator.VideoFilter)
+		}
+	}
+}
+
+func TestHDRMetadata_videoProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		encoder     string
+		pixelFormat string
+		profile     string
+		want        string
+	}{
+		{"x265 main forced to 10 bit", "libx265", "yuv420p", "main", "main10"},
+		{"x265 main of a 10 bit profile", "libx265", "yuv420p10le", "main10", "main10"},
+		{"x264 high forced to 10 bit", "libx264", "", "high", "high10"},
+		{"x264 high444 keeps 10 bit", "libx264", "yuv420p", "high444", "high444"},
+		{"svt-av1 main takes 10 bit", "libsvtav1", "yuv420p", "main", "main"},
+		{"no profile", "libx265", "yuv420p", "", ""},
+	}
+
+	hdr := &HDRMetadata{ColorPrimaries: "bt2020", ColorTransfer: "smpte2084"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hdr.videoProfile(tt.encoder, tt.pixelFormat, tt.profile); got != tt.want {
+				t.Errorf("videoProfile() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package task
+
+import (
+	"fmt"
+	"gearr/helper/codec"
+	"gearr/helper/detect"
+	"gearr/model"
+)
+
+// detectInterlace runs idet on windows sampled over the whole source of the
+// codecs used by interlaced captures, and deinterlaces the sources found
+// interlaced. Segments take the decision of the server on the whole source.
+func (J *EncodeWorker) detectInterlace(job *model.WorkTaskEncode, track *TaskTracks, container *ContainerData, duration float64) error {
+	deinterlacer := ""
+	if profile := job.TaskEncode.Profile; profile != nil {
+		deinterlacer = profile.Deinterlacer
+	}
+	if deinterlacer == model.DeinterlacerNone || len(job.TaskEncode.SegmentURLs) > 0 || job.TaskEncode.Analysis != nil || !codec.MayBeInterlaced(container.Video.Codec, container.Video.FieldOrder) {
+		return nil
+	}
+
+	J.updateTaskStatus(job, model.IdetNotification, model.ProgressingNotificationStatus, "")
+	track.Message(string(model.IdetNotification))
+	var result detect.IdetResult
+	for _, start := range detect.IdetSamples(duration) {
+		output, err := J.analyzeVideo(job, detect.IdetArguments(job.SourceFilePath, int(container.Video.Id), start))
+		if err == nil {
+			var window detect.IdetResult
+			window, err = detect.ParseIdet(output)
+			result.Add(window)
+		}
+		if err != nil {
+			J.updateTaskStatus(job, model.IdetNotification, model.FailedNotificationStatus, err.Error())
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("progressive (%s)", result)
+	if result.Interlaced() {
+		container.Video.Deinterlace = detect.DeinterlaceFilter(deinterlacer, result.Parity())
+		message = fmt.Sprintf("interlaced %s (%s), deinterlacing with %s", result.Parity(), result, container.Video.Deinterlace)
+		J.terminal.Log("[%s] source is %s", job.TaskEncode.Id.String(), message)
+	}
+	J.updateTaskStatus(job, model.IdetNotification, model.CompletedNotificationStatus, message)
+	return nil
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"gearr/model"
+)
+
+const (
+	// largerOutputCRFStep raises the CRF of the retry of encodes larger than
+	// their source.
+	largerOutputCRFStep = 4
+	maxCRF              = 63
+)
+
+var (
+	ErrEncodedLarger = errors.New("encoded file is larger than the source")
+	ErrKeptOriginal  = errors.New("original file kept")
+)
+
+// handleLargerOutput applies the larger output policy of the profile to an
+// encode larger than its source. It returns true when the video has to be
+// encoded again, after raising the CRF of the task profile. Joins copy the
+// already encoded segments and bitrate encodes ignore the CRF, so they keep the
+// original instead of retrying.
+func (J *EncodeWorker) handleLargerOutput(job *model.WorkTaskEncode, cause error, retried bool) (bool, error) {
+	profile := job.TaskEncode.Profile
+	policy := model.LargerOutputFail
+	if profile != nil && profile.LargerOutput != "" {
+		policy = profile.LargerOutput
+	}
+
+	switch policy {
+	case model.LargerOutputRetry:
+		if !retried && len(job.TaskEncode.SegmentURLs) == 0 && !profile.Bitrate.Enabled() && profile.CRF+largerOutputCRFStep <= maxCRF {
+			J.terminal.Warn("[%s] %s, encoding again with crf %d", job.TaskEncode.Id.String(), cause, profile.CRF+largerOutputCRFStep)
+			raised := *profile
+			raised.CRF += largerOutputCRFStep
+			job.TaskEncode.Profile = &raised
+			return true, nil
+		}
+		fallthrough
+	case model.LargerOutputKeep:
+		return false, fmt.Errorf("%w: %s", ErrKeptOriginal, cause)
+	}
+	return false, cause
+}
@@ -0,0 +1,45 @@
+package task
+
+import (
+	"errors"
+	"gearr/model"
+	"testing"
+)
+
+func TestHandleLargerOutput(t *testing.T) {
+	worker := &EncodeWorker{terminal: NewConsoleWorkerPrinter()}
+	cause := ErrEncodedLarger
+
+	tests := []struct {
+		name        string
+		profile     *model.EncodingProfile
+		segmentURLs []string
+		retried     bool
+		reencode    bool
+		wantErr     error
+		wantCRF     int
+	}{
+		{"fail by default", nil, nil, false, false, ErrEncodedLarger, 0},
+		{"keep original", &model.EncodingProfile{CRF: 21, LargerOutput: model.LargerOutputKeep}, nil, false, false, ErrKeptOriginal, 21},
+		{"retry at higher crf", &model.EncodingProfile{CRF: 21, LargerOutput: model.LargerOutputRetry}, nil, false, true, nil, 25},
+		{"keep original after retry", &model.EncodingProfile{CRF: 25, LargerOutput: model.LargerOutputRetry}, nil, true, false, ErrKeptOriginal, 25},
+		{"join keeps original", &model.EncodingProfile{CRF: 21, LargerOutput: model.LargerOutputRetry}, []string{"segment"}, false, false, ErrKeptOriginal, 21},
+		{"bitrate keeps original", &model.EncodingProfile{CRF: 21, LargerOutput: model.LargerOutputRetry, Bitrate: model.BitratePolicy{SourcePercent: 40}}, nil, false, false, ErrKeptOriginal, 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &model.WorkTaskEncode{TaskEncode: &model.TaskEncode{Profile: tt.profile, SegmentURLs: tt.segmentURLs}}
+			reencode, err := worker.handleLargerOutput(job, cause, tt.retried)
+			if reencode != tt.reencode {
+				t.Errorf("handleLargerOutput() reencode = %v, want %v", reencode, tt.reencode)
+			}
+			if (tt.wantErr == nil) != (err == nil) || (err != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("handleLargerOutput() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.profile != nil && job.TaskEncode.Profile.CRF != tt.wantCRF {
+				t.Errorf("profile crf = %d, want %d", job.TaskEncode.Profile.CRF, tt.wantCRF)
+			}
+		})
+	}
+}
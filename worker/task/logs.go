@@ -0,0 +1,77 @@
+package task
+
+import (
+	"fmt"
+	"gearr/model"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	logsDirName            = "logs"
+	ffmpegLogName          = "ffmpeg.log"
+	mkvExtractLogName      = "mkvextract.log"
+	logTailLines           = 20
+	logUploadRetryAttempts = 3
+)
+
+// appendLog adds the command line and the output of a command to a log of the
+// job, the logs are uploaded to the server with the job result.
+func appendLog(workDir string, name string, commandLine string, output string) error {
+	logsPath := filepath.Join(workDir, logsDirName)
+	if err := os.MkdirAll(logsPath, os.ModePerm); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(logsPath, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(logFile, "$ %s\n%s\n", commandLine, output); err != nil {
+		logFile.Close()
+		return err
+	}
+	return logFile.Close()
+}
+
+// logTail returns the last lines of a command output. Errors carry only the
+// tail, the whole output is in the job logs.
+func logTail(output string, lines int) string {
+	output = strings.TrimRight(output, "\n")
+	split := strings.Split(output, "\n")
+	if len(split) <= lines {
+		return output
+	}
+	return strings.Join(split[len(split)-lines:], "\n")
+}
+
+// saveLog appends a command output to a job log, a log that can not be written
+// does not fail the job.
+func (J *EncodeWorker) saveLog(workDir string, name string, commandLine string, output string) {
+	if err := appendLog(workDir, name, commandLine, output); err != nil {
+		J.terminal.Error("error writing log %s: %s", name, err.Error())
+	}
+}
+
+// uploadLogs sends the logs of the job to the server. It is best effort, a
+// missing log must not change the job result.
+func (J *EncodeWorker) uploadLogs(task *model.WorkTaskEncode, track *TaskTracks) {
+	entries, err := os.ReadDir(filepath.Join(task.WorkDir, logsDirName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			J.terminal.Error("error listing logs of job %s: %s", task.TaskEncode.Id.String(), err.Error())
+		}
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		logURL := fmt.Sprintf("%s/log/%s", task.TaskEncode.UploadURL, url.PathEscape(entry.Name()))
+		logPath := filepath.Join(task.WorkDir, logsDirName, entry.Name())
+		if err := J.uploadFileAttempts(J.ctx, logURL, logPath, track, logUploadRetryAttempts); err != nil {
+			J.terminal.Error("error uploading log %s: %s", entry.Name(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogTail(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		lines  int
+		want   string
+	}{
+		{"shorter than the tail", "a\nb\n", 3, "a\nb"},
+		{"longer than the tail", "a\nb\nc\nd\n", 2, "c\nd"},
+		{"empty", "", 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logTail(tt.output, tt.lines); got != tt.want {
+				t.Errorf("logTail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendLog(t *testing.T) {
+	workDir := t.TempDir()
+	if err := appendLog(workDir, ffmpegLogName, "ffmpeg -pass 1", "first pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendLog(workDir, ffmpegLogName, "ffmpeg -pass 2", "second pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workDir, logsDirName, ffmpegLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "$ ffmpeg -pass 1\nfirst pass\n$ ffmpeg -pass 2\nsecond pass\n"
+	if string(content) != want {
+		t.Errorf("log = %q, want %q", content, want)
+	}
+}
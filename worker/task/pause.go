@@ -0,0 +1,130 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// jobPause holds a paused job: its running commands are suspended and its
+// transfers wait before their next read until the job is resumed. A nil
+// jobPause never pauses, like the jobs not started by the worker.
+type jobPause struct {
+	mu        sync.Mutex
+	resumed   chan struct{}
+	processes map[*os.Process]struct{}
+}
+
+func newJobPause() *jobPause {
+	return &jobPause{processes: make(map[*os.Process]struct{})}
+}
+
+// Pause suspends the job, false when it was already paused.
+func (P *jobPause) Pause() (bool, error) {
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	if P.resumed != nil {
+		return false, nil
+	}
+	P.resumed = make(chan struct{})
+	var errs []error
+	for process := range P.processes {
+		errs = append(errs, ignoreProcessDone(stopProcess(process)))
+	}
+	return true, errors.Join(errs...)
+}
+
+// Resume continues the job, false when it was not paused.
+func (P *jobPause) Resume() (bool, error) {
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	if P.resumed == nil {
+		return false, nil
+	}
+	var errs []error
+	for process := range P.processes {
+		errs = append(errs, ignoreProcessDone(continueProcess(process)))
+	}
+	close(P.resumed)
+	P.resumed = nil
+	return true, errors.Join(errs...)
+}
+
+// Paused reports whether the job is suspended.
+func (P *jobPause) Paused() bool {
+	if P == nil {
+		return false
+	}
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	return P.resumed != nil
+}
+
+// Wait blocks while the job is paused.
+func (P *jobPause) Wait(ctx context.Context) error {
+	if P == nil {
+		return ctx.Err()
+	}
+	P.mu.Lock()
+	resumed := P.resumed
+	P.mu.Unlock()
+	if resumed == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a started command of the job, suspended right away when the
+// job is paused.
+func (P *jobPause) track(process *os.Process) {
+	if P == nil {
+		return
+	}
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	P.processes[process] = struct{}{}
+	if P.resumed != nil {
+		stopProcess(process)
+	}
+}
+
+func (P *jobPause) untrack(process *os.Process) {
+	if P == nil || process == nil {
+		return
+	}
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	delete(P.processes, process)
+}
+
+func ignoreProcessDone(err error) error {
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+// pausableReader holds the reads of a transfer while its job is paused.
+type pausableReader struct {
+	io.ReadCloser
+	ctx   context.Context
+	pause *jobPause
+}
+
+func newPausableReader(ctx context.Context, pause *jobPause, reader io.ReadCloser) io.ReadCloser {
+	return &pausableReader{ReadCloser: reader, ctx: ctx, pause: pause}
+}
+
+func (P *pausableReader) Read(p []byte) (int, error) {
+	if err := P.pause.Wait(P.ctx); err != nil {
+		return 0, err
+	}
+	return P.ReadCloser.Read(p)
+}
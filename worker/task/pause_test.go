@@ -0,0 +1,221 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gearr/model"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPausableReader(t *testing.T) {
+	pause := newJobPause()
+	if paused, _ := pause.Pause(); !paused {
+		t.Fatal("Pause() = false, want true")
+	}
+	if paused, _ := pause.Pause(); paused {
+		t.Error("Pause() of a paused job = true, want false")
+	}
+
+	reader := newPausableReader(context.Background(), pause, io.NopCloser(strings.NewReader("source")))
+	read := make(chan string, 1)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		read <- string(data)
+	}()
+	select {
+	case <-read:
+		t.Fatal("read while the job is paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if resumed, _ := pause.Resume(); !resumed {
+		t.Fatal("Resume() = false, want true")
+	}
+	select {
+	case data := <-read:
+		if data != "source" {
+			t.Errorf("read %q, want %q", data, "source")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read not continued after resume")
+	}
+
+	// a canceled job does not wait for the resume
+	ctx, cancel := context.WithCancel(context.Background())
+	pause.Pause()
+	cancel()
+	if _, err := newPausableReader(ctx, pause, io.NopCloser(strings.NewReader("source"))).Read(make([]byte, 1)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Read() of a canceled job error = %v, want context.Canceled", err)
+	}
+}
+
+// waitProcessState waits for the state of /proc/<pid>/stat, T when stopped.
+func waitProcessState(t *testing.T, pid int, want string) {
+	state := ""
+	for range 50 {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the state follows the command name between parentheses
+		state = strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))[0]
+		if state == want {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("process state = %s, want %s", state, want)
+}
+
+func TestJobPause_SuspendsProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process state is read from /proc")
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	pause := newJobPause()
+	pause.Pause()
+	// commands started while the job is paused are suspended right away
+	pause.track(cmd.Process)
+	waitProcessState(t, cmd.Process.Pid, "T")
+
+	pause.Resume()
+	waitProcessState(t, cmd.Process.Pid, "S")
+}
+
+func TestEncodeWorker_PauseJob(t *testing.T) {
+	recorder := &eventRecorder{}
+	worker := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir()}, "worker", NewConsoleWorkerPrinter())
+	worker.Manager = recorder
+	job := &model.WorkTaskEncode{TaskEncode: &model.TaskEncode{Id: uuid.New()}, WorkDir: t.TempDir()}
+	worker.startJob(job)
+
+	if worker.RunJobAction(model.PauseJobAction, uuid.New()) {
+		t.Error("RunJobAction() of an unknown job = true, want false")
+	}
+	actions := []struct {
+		action  model.JobAction
+		applied bool
+	}{
+		{model.PauseJobAction, true},
+		{model.PauseJobAction, false},
+		{model.ResumeJobAction, true},
+		{model.ResumeJobAction, false},
+	}
+	for _, tt := range actions {
+		if applied := worker.RunJobAction(tt.action, job.TaskEncode.Id); applied != tt.applied {
+			t.Fatalf("RunJobAction(%s) = %t, want %t", tt.action, applied, tt.applied)
+		}
+	}
+
+	var statuses []model.NotificationStatus
+	for _, event := range recorder.events {
+		statuses = append(statuses, event.Status)
+	}
+	want := []model.NotificationStatus{model.PausedNotificationStatus, model.ProgressingNotificationStatus}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", statuses, want)
+	}
+	// resumeJobs continues from the state of the stage, not from the pause
+	if _, err := os.Stat(filepath.Join(job.WorkDir, job.TaskEncode.Id.String()+".json")); !os.IsNotExist(err) {
+		t.Error("pause saved as the state of the task")
+	}
+}
+
+func TestEncodeWorker_PauseJobs(t *testing.T) {
+	worker := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir()}, "worker", NewConsoleWorkerPrinter())
+	worker.Manager = &eventRecorder{}
+	newJob := func() *model.WorkTaskEncode {
+		job := &model.WorkTaskEncode{TaskEncode: &model.TaskEncode{Id: uuid.New()}, WorkDir: t.TempDir()}
+		worker.startJob(job)
+		return job
+	}
+	pausedJob, runningJob := newJob(), newJob()
+	worker.PauseJob(pausedJob.TaskEncode.Id)
+
+	worker.PauseJobs()
+	for _, job := range []*model.WorkTaskEncode{pausedJob, runningJob} {
+		if paused, _ := worker.jobPause(job).Pause(); paused {
+			t.Errorf("job %s not paused with the worker", job.TaskEncode.Id)
+		}
+	}
+
+	// the job paused on its own stays paused
+	worker.ResumeJobs()
+	if resumed, _ := worker.jobPause(pausedJob).Resume(); !resumed {
+		t.Error("job paused on its own resumed with the worker")
+	}
+	if resumed, _ := worker.jobPause(runningJob).Resume(); resumed {
+		t.Error("job paused by the worker not resumed")
+	}
+}
+
+func TestEncodeWorker_SavedPause(t *testing.T) {
+	tests := []struct {
+		name         string
+		workerPause  bool
+		startsPaused bool
+		wantPaused   bool
+		wantResumed  bool
+	}{
+		{"job paused", false, false, true, false},
+		{"worker paused", true, false, false, true},
+		{"worker paused and restarted paused", true, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir()}, "worker", NewConsoleWorkerPrinter())
+			worker.Manager = &eventRecorder{}
+			job := &model.WorkTaskEncode{TaskEncode: &model.TaskEncode{Id: uuid.New()}, WorkDir: t.TempDir()}
+			worker.startJob(job)
+			worker.updateTaskStatus(job, model.FFMPEGSNotification, model.ProgressingNotificationStatus, "")
+			if tt.workerPause {
+				worker.PauseJobs()
+			} else {
+				worker.PauseJob(job.TaskEncode.Id)
+			}
+
+			saved := worker.readTaskStatusFromDiskByPath(taskStatusPath(job))
+			if !saved.Paused || saved.WorkerPaused != tt.workerPause {
+				t.Errorf("saved Paused = %t, WorkerPaused = %t, want true, %t", saved.Paused, saved.WorkerPaused, tt.workerPause)
+			}
+			if !saved.LastState.IsEncoding() {
+				t.Errorf("saved state = %s %s, want the encode it continues from", saved.LastState.NotificationType, saved.LastState.Status)
+			}
+			if saved.Task.TaskEncode.EventID != 2 {
+				t.Errorf("saved EventID = %d, want 2", saved.Task.TaskEncode.EventID)
+			}
+
+			// the worker restarts
+			recorder := &eventRecorder{}
+			restarted := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir(), Paused: tt.startsPaused}, "worker", NewConsoleWorkerPrinter())
+			restarted.Manager = recorder
+			restarted.startJob(saved.Task)
+			restarted.restorePause(saved)
+			if paused := restarted.jobPause(saved.Task).Paused(); paused != tt.wantPaused {
+				t.Errorf("job paused after the restart = %t, want %t", paused, tt.wantPaused)
+			}
+			if resumed := len(recorder.events) == 1 && recorder.events[0].Status == model.ProgressingNotificationStatus; resumed != tt.wantResumed {
+				t.Errorf("events after the restart = %v, want a progressing one %t", recorder.events, tt.wantResumed)
+			}
+			if tt.startsPaused && !restarted.ResumeJob(saved.Task.TaskEncode.Id) {
+				t.Error("ResumeJob() after the restart = false, want true")
+			}
+		})
+	}
+}
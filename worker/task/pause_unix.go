@@ -0,0 +1,16 @@
+//go:build !windows
+
+package task
+
+import (
+	"os"
+	"syscall"
+)
+
+func stopProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGSTOP)
+}
+
+func continueProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package task
+
+import (
+	"errors"
+	"os"
+)
+
+var errSuspendNotSupported = errors.New("suspending processes is not supported on windows")
+
+// Windows workers only hold the transfers of paused jobs.
+func stopProcess(process *os.Process) error {
+	return errSuspendNotSupported
+}
+
+func continueProcess(process *os.Process) error {
+	return errSuspendNotSupported
+}
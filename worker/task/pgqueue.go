@@ -44,6 +44,9 @@ type PostgresClient struct {
 	printer           *ConsoleWorkerPrinter
 	pollInterval      time.Duration
 	pgsJobControls    *concurrent.Map[string, *TaskPGSJobControl]
+	stateMu           sync.Mutex
+	state             model.WorkerState
+	encoders          []string
 }
 
 func NewBrokerClientPostgres(dbConfig repository.SQLServerConfig, workerConfig Config, printer *ConsoleWorkerPrinter) (*PostgresClient, error) {
@@ -58,6 +61,16 @@ func NewBrokerClientPostgres(dbConfig repository.SQLServerConfig, workerConfig C
 	pgsJobControls := concurrent.NewMap[string, *TaskPGSJobControl]()
 	pgsJobControls.Set("_init", nil)
 
+	state := model.ActiveWorkerState
+	if workerConfig.Paused {
+		state = model.PausedWorkerState
+	}
+
+	encoders, err := detectEncoders(context.Background())
+	if err != nil {
+		helper.Warnf("failed to list ffmpeg encoders and filters, jobs of any profile will be accepted: %v", err)
+	}
+
 	return &PostgresClient{
 		repo:              repo,
 		workerConfig:      workerConfig,
@@ -65,6 +78,8 @@ func NewBrokerClientPostgres(dbConfig repository.SQLServerConfig, workerConfig C
 		printer:           printer,
 		pollInterval:      time.Second,
 		pgsJobControls:    pgsJobControls,
+		state:             state,
+		encoders:          encoders,
 	}, nil
 }
 
@@ -138,7 +153,7 @@ func (p *PostgresClient) eventProcessor(ctx context.Context) {
 	if p.workerConfig.Jobs.IsAccepted(model.PGSToSrtJobType) {
 		go p.pgsQueueProcessor(ctx)
 	}
-	if p.workerConfig.Jobs.IsAccepted(model.EncodeJobType) {
+	if len(p.workerConfig.Jobs.Accepted(model.EncodeJobTypes)) > 0 {
 		go p.encodeQueueProcessor(ctx)
 	}
 
@@ -148,23 +163,14 @@ func (p *PostgresClient) eventProcessor(ctx context.Context) {
 	pingTicker := time.NewTicker(30 * time.Second)
 	defer pingTicker.Stop()
 
+	// the server routes job actions to the queue of the last ping
+	p.ping()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-pingTicker.C:
-			ip, err := helper.GetPublicIP()
-			if err != nil {
-				helper.Warnf("failed to get public IP: %v", err)
-			}
-			pingEvent := model.TaskEvent{
-				EventType:   model.PingEvent,
-				WorkerName:  p.workerConfig.Name,
-				WorkerQueue: p.workerUniqueQueue,
-				EventTime:   time.Now(),
-				IP:          ip,
-			}
-			p.EventNotification(pingEvent)
+			p.ping()
 		case <-ticker.C:
 			p.checkPGSResponses()
 			p.checkJobActions(ctx)
@@ -172,6 +178,23 @@ func (p *PostgresClient) eventProcessor(ctx context.Context) {
 	}
 }
 
+func (p *PostgresClient) ping() {
+	ip, err := helper.GetPublicIP()
+	if err != nil {
+		helper.Warnf("failed to get public IP: %v", err)
+	}
+	pingEvent := model.TaskEvent{
+		EventType:    model.PingEvent,
+		WorkerName:   p.workerConfig.Name,
+		WorkerQueue:  p.workerUniqueQueue,
+		WorkerState:  p.reportedState(),
+		EventTime:    time.Now(),
+		IP:           ip,
+		Capabilities: p.capabilities(),
+	}
+	p.EventNotification(pingEvent)
+}
+
 func (p *PostgresClient) checkPGSResponses() {
 	resp, err := p.repo.DequeuePGSResponse(context.Background(), p.workerUniqueQueue)
 	if err != nil {
@@ -200,8 +223,74 @@ func (p *PostgresClient) checkJobActions(ctx context.Context) {
 		return
 	}
 	for _, action := range actions {
+		if action.Id == uuid.Nil {
+			p.applyWorkerAction(action.Action)
+			continue
+		}
 		helper.Infof("received job action %s for job %s", action.Action, action.Id.String())
+		if p.EncodeWorker == nil || !p.EncodeWorker.encodeWorker.RunJobAction(action.Action, action.Id) {
+			helper.Warnf("job action %s not applied, job %s is not running on this worker or already in that state", action.Action, action.Id.String())
+		}
+	}
+}
+
+// applyWorkerAction changes the state of the worker and reports it right away.
+func (p *PostgresClient) applyWorkerAction(action model.JobAction) {
+	helper.Infof("received worker action %s", action)
+	switch action {
+	case model.DrainWorkerAction:
+		p.setState(model.DrainingWorkerState)
+	case model.PauseWorkerAction:
+		p.setState(model.PausedWorkerState)
+		if p.EncodeWorker != nil {
+			p.EncodeWorker.encodeWorker.PauseJobs()
+		}
+	case model.ResumeWorkerAction:
+		p.setState(model.ActiveWorkerState)
+		if p.EncodeWorker != nil {
+			p.EncodeWorker.encodeWorker.ResumeJobs()
+		}
+	default:
+		helper.Warnf("unknown worker action %s", action)
+		return
+	}
+	p.ping()
+}
+
+func (p *PostgresClient) setState(state model.WorkerState) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.state = state
+}
+
+func (p *PostgresClient) getState() model.WorkerState {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	return p.state
+}
+
+// acceptJobs is false for drained and paused workers, which take no new jobs.
+func (p *PostgresClient) acceptJobs() bool {
+	return p.getState() == model.ActiveWorkerState
+}
+
+// reportedState tells a draining worker that already finished its jobs, so
+// it can be stopped.
+func (p *PostgresClient) reportedState() model.WorkerState {
+	state := p.getState()
+	if state == model.DrainingWorkerState && !p.hasRunningJobs() {
+		return model.DrainedWorkerState
+	}
+	return state
+}
+
+func (p *PostgresClient) hasRunningJobs() bool {
+	for _, worker := range p.PGSWorker {
+		if worker.active {
+			return true
+		}
 	}
+	return p.EncodeWorker != nil && p.EncodeWorker.encodeWorker.RunningJobs() > 0
 }
 
 func (p *PostgresClient) pgsQueueProcessor(ctx context.Context) {
@@ -215,7 +304,7 @@ func (p *PostgresClient) pgsQueueProcessor(ctx context.Context) {
 			return
 		case <-ticker.C:
 			for _, worker := range p.PGSWorker {
-				if !worker.active && worker.pgsWorker.AcceptJobs() {
+				if !worker.active && p.acceptJobs() && worker.pgsWorker.AcceptJobs() {
 					pgsJob, err := p.repo.DequeuePGSJob(ctx, p.workerUniqueQueue)
 					if err != nil {
 						helper.Errorf("failed to dequeue PGS job: %v", err)
@@ -247,6 +336,7 @@ func (p *PostgresClient) pgsQueueProcessor(ctx context.Context) {
 
 func (p *PostgresClient) encodeQueueProcessor(ctx context.Context) {
 	helper.Info("starting encode queue processor")
+	jobTypes := p.workerConfig.Jobs.Accepted(model.EncodeJobTypes)
 	ticker := time.NewTicker(p.pollInterval)
 	defer ticker.Stop()
 
@@ -258,8 +348,8 @@ func (p *PostgresClient) encodeQueueProcessor(ctx context.Context) {
 			if p.EncodeWorker == nil || p.EncodeWorker.encodeWorker == nil {
 				continue
 			}
-			if p.EncodeWorker.encodeWorker.AcceptJobs() {
-				task, err := p.repo.DequeueEncodeJob(ctx, p.workerUniqueQueue)
+			if p.acceptJobs() && p.EncodeWorker.encodeWorker.AcceptJobs() {
+				task, err := p.repo.DequeueEncodeJob(ctx, p.workerUniqueQueue, jobTypes, p.capabilities())
 				if err != nil {
 					helper.Errorf("failed to dequeue encode job: %v", err)
 					continue
@@ -275,7 +365,7 @@ func (p *PostgresClient) encodeQueueProcessor(ctx context.Context) {
 				}
 
 				if err := p.EncodeWorker.encodeWorker.Execute(taskData); err != nil {
-					helper.Errorf("[%s] Error Preparing Job Execution: %v", model.EncodeJobType, err)
+					helper.Errorf("[%s] Error Preparing Job Execution: %v", task.JobType(), err)
 					continue
 				}
 				helper.Debug("execute a new encoder job")
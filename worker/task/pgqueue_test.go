@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"testing"
 
 	"gearr/model"
@@ -99,3 +100,30 @@ func TestJobWorker_Active(t *testing.T) {
 		t.Error("worker should be active after setting")
 	}
 }
+
+func TestPostgresClient_ReportedState(t *testing.T) {
+	encodeWorker := NewEncodeWorker(context.Background(), Config{TemporalPath: t.TempDir()}, "worker", NewConsoleWorkerPrinter())
+	encodeWorker.Manager = &eventRecorder{}
+	job := &model.WorkTaskEncode{TaskEncode: &model.TaskEncode{Id: uuid.New()}, WorkDir: t.TempDir()}
+	encodeWorker.startJob(job)
+	client := &PostgresClient{
+		state:        model.DrainingWorkerState,
+		EncodeWorker: &JobWorker{encodeWorker: encodeWorker},
+	}
+
+	if client.acceptJobs() {
+		t.Error("acceptJobs() of a draining worker = true, want false")
+	}
+	if state := client.reportedState(); state != model.DrainingWorkerState {
+		t.Errorf("reportedState() with a running job = %s, want %s", state, model.DrainingWorkerState)
+	}
+	encodeWorker.finishJob(job)
+	if state := client.reportedState(); state != model.DrainedWorkerState {
+		t.Errorf("reportedState() without jobs = %s, want %s", state, model.DrainedWorkerState)
+	}
+
+	client.setState(model.ActiveWorkerState)
+	if !client.acceptJobs() {
+		t.Error("acceptJobs() of an active worker = false, want true")
+	}
+}
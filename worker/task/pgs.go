@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -77,6 +78,8 @@ func (P *PGSWorker) Execute() (err error) {
 	outputFileName := strconv.Itoa(P.task.PGSID) + ".srt"
 	outputFilePath := filepath.Join(P.tempPath, outputFileName)
 	var outputBytes []byte
+	var logMutex sync.Mutex
+	pgsToSrtLog := ""
 	defer func() {
 		errString := ""
 		if err != nil {
@@ -90,6 +93,7 @@ func (P *PGSWorker) Execute() (err error) {
 			PGSID: P.task.PGSID,
 			Srt:   outputBytes,
 			Err:   errString,
+			Log:   pgsToSrtLog,
 			Queue: P.task.ReplyTo,
 		}
 		helper.Debugf("task response: %+v", pgsTaskResponse)
@@ -104,6 +108,13 @@ func (P *PGSWorker) Execute() (err error) {
 	language := calculateTesseractLanguage(P.task.PGSLanguage)
 	PGSToSrtCommand := command.NewCommand(P.workerConfig.DotnetPath, fmt.Sprintf("%s", P.workerConfig.PGSTOSrtDLLPath), "--input", inputFilePath, "--output", outputFilePath, "--tesseractlanguage", language, "--tesseractdata", P.workerConfig.TesseractDataPath).
 		SetWorkDir(P.tempPath)
+	// the output goes back with the response to the job logs
+	appendLog := func(buffer []byte, exit bool) {
+		logMutex.Lock()
+		defer logMutex.Unlock()
+		pgsToSrtLog += string(buffer)
+	}
+	PGSToSrtCommand.SetStdoutFunc(appendLog).SetStderrFunc(appendLog)
 	helper.Debugf("pgstosrt command: %s", PGSToSrtCommand.GetFullCommand())
 	ecode, err := PGSToSrtCommand.RunWithContext(P.ctx)
 	if err != nil {
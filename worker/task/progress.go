@@ -0,0 +1,119 @@
+package task
+
+import (
+	"gearr/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// progressEventInterval is how often a running encode reports its progress.
+const progressEventInterval = 10 * time.Second
+
+// FFMPEGProgress is a block of the ffmpeg -progress output. outTime is the
+// encoded media time in seconds, bitrate in kbps and size in bytes.
+type FFMPEGProgress struct {
+	outTime float64
+	frame   int64
+	fps     float64
+	bitrate float64
+	size    int64
+	speed   float64
+	percent float64
+	pass    int
+}
+
+// progressParser reads the key=value lines written by ffmpeg -progress. Every
+// block ends with a progress=continue or progress=end line, and lines may be
+// split between reads of the pipe.
+type progressParser struct {
+	pending  string
+	progress FFMPEGProgress
+}
+
+// parse consumes a chunk of output and returns the blocks it completes.
+func (P *progressParser) parse(buffer []byte) []FFMPEGProgress {
+	var blocks []FFMPEGProgress
+	P.pending += string(buffer)
+	for {
+		i := strings.IndexByte(P.pending, '\n')
+		if i < 0 {
+			return blocks
+		}
+		line := strings.TrimSpace(P.pending[:i])
+		P.pending = P.pending[i+1:]
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if P.setValue(strings.TrimSpace(key), strings.TrimSpace(value)) {
+			blocks = append(blocks, P.progress)
+		}
+	}
+}
+
+// setValue stores a progress value, returning true when it ends a block.
+// Values ffmpeg does not know yet are reported as N/A and keep the last one.
+func (P *progressParser) setValue(key string, value string) bool {
+	switch key {
+	case "frame":
+		if frame, err := strconv.ParseInt(value, 10, 64); err == nil {
+			P.progress.frame = frame
+		}
+	case "fps":
+		if fps, err := strconv.ParseFloat(value, 64); err == nil {
+			P.progress.fps = fps
+		}
+	case "bitrate":
+		if bitrate, err := strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64); err == nil {
+			P.progress.bitrate = bitrate
+		}
+	case "total_size":
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+			P.progress.size = size
+		}
+	case "out_time_us":
+		if outTime, err := strconv.ParseInt(value, 10, 64); err == nil && outTime >= 0 {
+			P.progress.outTime = float64(outTime) / 1e6
+		}
+	case "speed":
+		if speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+			P.progress.speed = speed
+		}
+	case "progress":
+		return true
+	}
+	return false
+}
+
+// encodeProgress is the progress event of an encode, percent being the done
+// share of all the passes.
+func encodeProgress(progress FFMPEGProgress, percent float64, duration float64, passes int) *model.EncodeProgress {
+	encodeProgress := &model.EncodeProgress{
+		Percent:    percent,
+		Frame:      progress.frame,
+		FPS:        progress.fps,
+		Bitrate:    progress.bitrate,
+		OutputSize: progress.size,
+		Speed:      progress.speed,
+		ETA:        encodeETA(progress, duration, passes),
+	}
+	if passes > 1 {
+		encodeProgress.Pass = progress.pass
+		encodeProgress.Passes = passes
+	}
+	return encodeProgress
+}
+
+// encodeETA is the estimated seconds left to encode, from the media time still
+// to encode in this and the following passes and the current speed.
+func encodeETA(progress FFMPEGProgress, duration float64, passes int) int64 {
+	if progress.speed <= 0 || duration <= 0 {
+		return 0
+	}
+	remaining := duration - progress.outTime + duration*float64(passes-progress.pass)
+	if remaining < 0 {
+		return 0
+	}
+	return int64(remaining / progress.speed)
+}
@@ -0,0 +1,76 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+)
+
+const progressBlock = `frame=1200
+fps=48.25
+stream_0_0_q=28.0
+bitrate=2150.3kbits/s
+total_size=13443072
+out_time_us=50050000
+out_time_ms=50050000
+out_time=00:00:50.050000
+dup_frames=0
+drop_frames=0
+speed=2.01x
+progress=continue
+`
+
+func TestProgressParser(t *testing.T) {
+	parser := &progressParser{}
+	blocks := parser.parse([]byte(progressBlock))
+	want := FFMPEGProgress{outTime: 50.05, frame: 1200, fps: 48.25, bitrate: 2150.3, size: 13443072, speed: 2.01}
+	if len(blocks) != 1 || !reflect.DeepEqual(blocks[0], want) {
+		t.Errorf("parse() = %+v, want %+v", blocks, want)
+	}
+}
+
+func TestProgressParserSplitLines(t *testing.T) {
+	parser := &progressParser{}
+	var blocks []FFMPEGProgress
+	for _, chunk := range []string{progressBlock[:20], progressBlock[20:101], progressBlock[101:], "frame=1300\nsp", "eed=2.1x\nprogress=end\n"} {
+		blocks = append(blocks, parser.parse([]byte(chunk))...)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("parse() returned %d blocks, want 2", len(blocks))
+	}
+	if blocks[0].frame != 1200 || blocks[0].speed != 2.01 {
+		t.Errorf("first block = %+v", blocks[0])
+	}
+	if blocks[1].frame != 1300 || blocks[1].speed != 2.1 || blocks[1].size != 13443072 {
+		t.Errorf("second block = %+v, want the new frame and speed over the last values", blocks[1])
+	}
+}
+
+func TestProgressParserNotAvailable(t *testing.T) {
+	parser := &progressParser{}
+	blocks := parser.parse([]byte("frame=0\nfps=0.00\nbitrate=N/A\ntotal_size=N/A\nout_time_us=N/A\nspeed=N/A\nprogress=continue\n"))
+	if len(blocks) != 1 || !reflect.DeepEqual(blocks[0], FFMPEGProgress{}) {
+		t.Errorf("parse() = %+v, want zero values", blocks)
+	}
+}
+
+func TestEncodeETA(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress FFMPEGProgress
+		passes   int
+		want     int64
+	}{
+		{"single pass", FFMPEGProgress{outTime: 600, speed: 2, pass: 1}, 1, 1500},
+		{"first of two passes", FFMPEGProgress{outTime: 600, speed: 4, pass: 1}, 2, 1650},
+		{"second of two passes", FFMPEGProgress{outTime: 3000, speed: 1, pass: 2}, 2, 600},
+		{"unknown speed", FFMPEGProgress{outTime: 600, pass: 1}, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeETA(tt.progress, 3600, tt.passes); got != tt.want {
+				t.Errorf("encodeETA() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"gearr/model"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const (
+	defaultQualitySamples       = 3
+	defaultQualitySampleSeconds = 10
+	defaultQualityCRFStep       = 2
+	// qualityPixelFormat is the common format both videos are converted to,
+	// the metric filters need matching inputs.
+	qualityPixelFormat = "yuv420p10le"
+)
+
+var ErrQualityBelowThreshold = errors.New("encoded video quality below threshold")
+
+var (
+	vmafScoreRegex = regexp.MustCompile(`VMAF score[:=]\s*(\d+(?:\.\d+)?)`)
+	ssimScoreRegex = regexp.MustCompile(`SSIM .*All:(\d+(?:\.\d+)?)`)
+)
+
+// qualitySamples returns the start of each sampled window, spread evenly over
+// the video, and the length of the windows. Videos shorter than all the
+// samples together are scored whole.
+func qualitySamples(policy model.QualityPolicy, duration float64) ([]float64, float64) {
+	samples := policy.Samples
+	if samples == 0 {
+		samples = defaultQualitySamples
+	}
+	length := float64(policy.SampleSeconds)
+	if length == 0 {
+		length = defaultQualitySampleSeconds
+	}
+	if float64(samples)*length >= duration {
+		return []float64{0}, duration
+	}
+
+	starts := make([]float64, samples)
+	for i := range starts {
+		starts[i] = (duration - length) * float64(i+1) / float64(samples+1)
+	}
+	return starts, length
+}
+
+// qualityFilter compares the first video of the encoded input against the
+// source video stream, deinterlaced and cropped like the encode and scaled to
+// the encoded size.
+func qualityFilter(metric string, source *Video, width int, height int, threads int) string {
+	filter := "ssim"
+	if metric == model.QualityMetricVMAF {
+		filter = fmt.Sprintf("libvmaf=n_threads=%d", threads)
+	}
+	sourceFilters := ""
+	for _, sourceFilter := range source.sourceFilters() {
+		sourceFilters += sourceFilter + ","
+	}
+	return fmt.Sprintf("[0:v:0]setpts=PTS-STARTPTS,format=%[1]s[main];"+
+		"[1:%[2]d]%[3]sscale=%[4]d:%[5]d:flags=bicubic,setpts=PTS-STARTPTS,format=%[1]s[ref];"+
+		"[main][ref]%[6]s", qualityPixelFormat, source.Id, sourceFilters, width, height, filter)
+}
+
+// parseQualityScore reads the pooled score logged by the libvmaf or ssim filter.
+func parseQualityScore(metric string, output string) (float64, error) {
+	regex := ssimScoreRegex
+	if metric == model.QualityMetricVMAF {
+		regex = vmafScoreRegex
+	}
+	matches := regex.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no %s score found in ffmpeg output", metric)
+	}
+	return strconv.ParseFloat(matches[len(matches)-1][1], 64)
+}
+
+// measureQuality scores the encoded video against the source, averaging the
+// score of every sampled window.
+func (J *EncodeWorker) measureQuality(job *model.WorkTaskEncode, policy model.QualityPolicy, container *ContainerData, encodedVideoParams *ffprobe.ProbeData) (float64, error) {
+	encodedVideo := encodedVideoParams.FirstVideoStream()
+	if encodedVideo == nil {
+		return 0, errors.New("encoded file has no video stream")
+	}
+	filter := qualityFilter(policy.Metric, container.Video, encodedVideo.Width, encodedVideo.Height, J.workerConfig.Threads)
+
+	starts, length := qualitySamples(policy, encodedVideoParams.Format.DurationSeconds)
+	total := 0.0
+	for _, start := range starts {
+		arguments := []string{"-hide_banner", "-nostats",
+			"-ss", fmt.Sprintf("%.3f", start), "-t", fmt.Sprintf("%.3f", length), "-i", job.TargetFilePath,
+			"-ss", fmt.Sprintf("%.3f", start), "-t", fmt.Sprintf("%.3f", length), "-i", job.SourceFilePath,
+			"-lavfi", filter, "-f", "null", "-"}
+		output, err := J.analyzeVideo(job, arguments)
+		if err != nil {
+			return 0, err
+		}
+		score, err := parseQualityScore(policy.Metric, output)
+		if err != nil {
+			return 0, err
+		}
+		total += score
+	}
+	return total / float64(len(starts)), nil
+}
+
+// verifyQuality checks the encode against the quality policy of the profile.
+// It returns true when the video has to be encoded again, after lowering the
+// CRF of the task profile, never for bitrate encodes ignoring the CRF. Sample
+// clips are always scored, with SSIM unless the profile sets a metric, but
+// never encoded again nor failed.
+func (J *EncodeWorker) verifyQuality(job *model.WorkTaskEncode, track *TaskTracks, container *ContainerData, encodedVideoParams *ffprobe.ProbeData, retries int) (bool, error) {
+	profile := job.TaskEncode.Profile
+	// joins copy the already verified segments
+	if profile == nil || len(job.TaskEncode.SegmentURLs) > 0 {
+		return false, nil
+	}
+	policy := profile.Quality
+	if job.TaskEncode.Sample && !policy.Enabled() {
+		policy.Metric = model.QualityMetricSSIM
+	}
+	if !policy.Enabled() {
+		return false, nil
+	}
+
+	J.updateTaskStatus(job, model.QualityNotification, model.ProgressingNotificationStatus, "")
+	track.Message(string(model.QualityNotification))
+	score, err := J.measureQuality(job, policy, container, encodedVideoParams)
+	if err != nil {
+		J.updateTaskStatus(job, model.QualityNotification, model.FailedNotificationStatus, err.Error())
+		return false, err
+	}
+
+	result := model.QualityResult{
+		Metric: policy.Metric,
+		Score:  score,
+		CRF:    profile.CRF,
+		Passed: score >= policy.MinScore,
+	}
+	if result.Passed || job.TaskEncode.Sample {
+		J.updateTaskStatus(job, model.QualityNotification, model.CompletedNotificationStatus, result.ToJson())
+		return false, nil
+	}
+
+	step := policy.CRFStep
+	if step == 0 {
+		step = defaultQualityCRFStep
+	}
+	if retries >= policy.Retries || profile.CRF-step < 0 || profile.Bitrate.Enabled() {
+		J.updateTaskStatus(job, model.QualityNotification, model.FailedNotificationStatus, result.ToJson())
+		return false, fmt.Errorf("%w: %s %.4f is below %.4f at crf %d", ErrQualityBelowThreshold, policy.Metric, score, policy.MinScore, profile.CRF)
+	}
+
+	J.updateTaskStatus(job, model.QualityNotification, model.CompletedNotificationStatus, result.ToJson())
+	J.terminal.Warn("[%s] %s %.4f is below %.4f, encoding again with crf %d", job.TaskEncode.Id.String(), policy.Metric, score, policy.MinScore, profile.CRF-step)
+	lowered := *profile
+	lowered.CRF -= step
+	job.TaskEncode.Profile = &lowered
+	return true, nil
+}
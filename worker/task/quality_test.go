@@ -0,0 +1,74 @@
+package task
+
+import (
+	"gearr/helper/detect"
+	"gearr/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQualitySamples(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     model.QualityPolicy
+		duration   float64
+		wantStarts []float64
+		wantLength float64
+	}{
+		{"defaults", model.QualityPolicy{Metric: "vmaf"}, 130, []float64{30, 60, 90}, 10},
+		{"custom", model.QualityPolicy{Metric: "vmaf", Samples: 1, SampleSeconds: 20}, 100, []float64{40}, 20},
+		{"short video scored whole", model.QualityPolicy{Metric: "ssim"}, 25, []float64{0}, 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starts, length := qualitySamples(tt.policy, tt.duration)
+			if !reflect.DeepEqual(starts, tt.wantStarts) || length != tt.wantLength {
+				t.Errorf("qualitySamples() = %v, %f, want %v, %f", starts, length, tt.wantStarts, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestQualityFilter(t *testing.T) {
+	filter := qualityFilter("vmaf", &Video{Id: 2}, 1920, 800, 4)
+	for _, c := range []string{"[1:2]scale=1920:800", "[main][ref]libvmaf=n_threads=4"} {
+		if !strings.Contains(filter, c) {
+			t.Errorf("filter %q does not contain %q", filter, c)
+		}
+	}
+	if filter := qualityFilter("ssim", &Video{Id: 0}, 1280, 720, 4); !strings.HasSuffix(filter, "[main][ref]ssim") {
+		t.Errorf("filter %q should end with the ssim filter", filter)
+	}
+	cropped := &Video{Id: 0, Crop: &detect.Crop{Width: 3840, Height: 1600, X: 0, Y: 280}}
+	if filter := qualityFilter("ssim", cropped, 1920, 800, 4); !strings.Contains(filter, "[1:0]crop=3840:1600:0:280,scale=1920:800") {
+		t.Errorf("filter %q should crop the source before scaling", filter)
+	}
+}
+
+func TestParseQualityScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		metric      string
+		output      string
+		expected    float64
+		expectError bool
+	}{
+		{"vmaf", "vmaf", "[Parsed_libvmaf_4 @ 0x55d] VMAF score: 94.872134\n", 94.872134, false},
+		{"ssim", "ssim", "[Parsed_ssim_4 @ 0x55d] SSIM Y:0.991 (20.4) U:0.995 (23.1) V:0.994 (22.6) All:0.992321 (21.1)\n", 0.992321, false},
+		{"missing score", "vmaf", "Conversion failed!", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := parseQualityScore(tt.metric, tt.output)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseQualityScore() error = %v, expectError %v", err, tt.expectError)
+			}
+			if score != tt.expected {
+				t.Errorf("parseQualityScore() = %f, want %f", score, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package task
+
+import (
+	"encoding/hex"
+	"fmt"
+	"gearr/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathMapping maps a directory of the server to the directory where the worker
+// mounts the same storage.
+type PathMapping struct {
+	Server string `mapstructure:"server"`
+	Worker string `mapstructure:"worker"`
+}
+
+// sharedPath returns the worker path of a file of the server from the longest
+// mapping covering it, false when there is none or its mount is not visible to
+// the worker, which then falls back to HTTP.
+func sharedPath(mappings []PathMapping, serverPath string) (string, bool) {
+	if serverPath == "" {
+		return "", false
+	}
+	workerPath := ""
+	longestMatch := -1
+	for _, mapping := range mappings {
+		if mapping.Server == "" || mapping.Worker == "" {
+			continue
+		}
+		serverRoot := filepath.Clean(mapping.Server)
+		relativePath, err := filepath.Rel(serverRoot, filepath.Clean(serverPath))
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(serverRoot) <= longestMatch {
+			continue
+		}
+		if info, err := os.Stat(mapping.Worker); err != nil || !info.IsDir() {
+			continue
+		}
+		workerPath = filepath.Join(mapping.Worker, relativePath)
+		longestMatch = len(serverRoot)
+	}
+	return workerPath, longestMatch >= 0
+}
+
+// openSharedSource uses the source straight from the shared storage, after
+// checking it against the checksum of the server like a download. The
+// checksum is requested first, the cut of a segment exists once it is ready.
+func (J *EncodeWorker) openSharedSource(job *model.WorkTaskEncode, sourcePath string, track *TaskTracks) error {
+	checksum, err := J.calculateChecksum(job.Context(), job.TaskEncode.ChecksumURL)
+	if err != nil {
+		return err
+	}
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	info, err := sourceFile.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", sourcePath)
+	}
+
+	track.UpdateValue(0)
+	track.SetTotal(info.Size())
+	reader := NewProgressTrackStream(track, newPausableReader(job.Context(), J.jobPause(job), sourceFile))
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return err
+	}
+	if sourceChecksum := hex.EncodeToString(reader.SumSha()); sourceChecksum != checksum {
+		return fmt.Errorf("checksum error on shared source:%s read:%s", checksum, sourceChecksum)
+	}
+
+	job.SourceFilePath = sourcePath
+	return nil
+}
+
+// writeShared copies the encoded file next to its destination on the shared
+// storage and renames it in place once its checksum is verified, so the
+// server never sees a partial file.
+func (J *EncodeWorker) writeShared(filePath string, destinationPath string, track *TaskTracks) error {
+	size, checksum, err := fileChecksum(filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(destinationPath), os.ModePerm); err != nil {
+		return err
+	}
+	temporalPath := destinationPath + ".upload"
+	if err := copyTrackedFile(filePath, temporalPath, track, size); err != nil {
+		os.Remove(temporalPath)
+		return err
+	}
+	if _, writtenChecksum, err := fileChecksum(temporalPath); err != nil || writtenChecksum != checksum {
+		os.Remove(temporalPath)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("checksum error on shared destination:%s written:%s", checksum, writtenChecksum)
+	}
+	return os.Rename(temporalPath, destinationPath)
+}
+
+func copyTrackedFile(sourcePath string, destinationPath string, track *TaskTracks, size int64) error {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destinationFile, err := os.OpenFile(destinationPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+
+	track.UpdateValue(0)
+	track.SetTotal(size)
+	if _, err := io.Copy(destinationFile, NewProgressTrackStream(track, sourceFile)); err != nil {
+		return err
+	}
+	if err := destinationFile.Sync(); err != nil {
+		return err
+	}
+	return destinationFile.Close()
+}
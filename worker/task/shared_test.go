@@ -0,0 +1,132 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gearr/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSharedPath(t *testing.T) {
+	mount := t.TempDir()
+	moviesMount := t.TempDir()
+	mappings := []PathMapping{
+		{Server: "/data", Worker: mount},
+		{Server: "/data/movies/", Worker: moviesMount},
+		{Server: "/missing", Worker: filepath.Join(mount, "not-mounted")},
+	}
+
+	tests := []struct {
+		name       string
+		serverPath string
+		want       string
+		wantOK     bool
+	}{
+		{"mapped path", "/data/tv/show.mkv", filepath.Join(mount, "tv/show.mkv"), true},
+		{"longest mapping", "/data/movies/movie.mkv", filepath.Join(moviesMount, "movie.mkv"), true},
+		{"path out of the mappings", "/other/movie.mkv", "", false},
+		{"prefix of another directory", "/database/movie.mkv", "", false},
+		{"escaping the mapping", "/data/../etc/passwd", "", false},
+		{"mount not visible", "/missing/movie.mkv", "", false},
+		{"empty path", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sharedPath(mappings, tt.serverPath)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("sharedPath(%q) = %q, %v, want %q, %v", tt.serverPath, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWriteShared(t *testing.T) {
+	content := []byte("encoded video")
+	encodedPath := filepath.Join(t.TempDir(), "movie-encoded.mkv")
+	os.WriteFile(encodedPath, content, os.ModePerm)
+	destinationPath := filepath.Join(t.TempDir(), "movies", "movie.mkv")
+
+	printer := NewConsoleWorkerPrinter()
+	worker := &EncodeWorker{ctx: context.Background(), terminal: printer}
+	if err := worker.writeShared(encodedPath, destinationPath, printer.AddTask("upload", UploadJobStepType)); err != nil {
+		t.Fatalf("writeShared() error = %v", err)
+	}
+
+	written, err := os.ReadFile(destinationPath)
+	if err != nil || !bytes.Equal(written, content) {
+		t.Errorf("destination = %q, %v, want %q", written, err, content)
+	}
+	if _, err := os.Stat(destinationPath + ".upload"); !os.IsNotExist(err) {
+		t.Errorf("temporal file left next to the destination")
+	}
+}
+
+func TestDownloadFileShared(t *testing.T) {
+	content := []byte("source video")
+	sha := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sha[:])
+
+	tests := []struct {
+		name          string
+		sharedContent []byte
+		wantShared    bool
+	}{
+		{"source read from the shared storage", content, true},
+		{"different file falls back to the download", []byte("stale video"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads := 0
+			mux := http.NewServeMux()
+			mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+				downloads++
+				w.Header().Set("Content-Disposition", "attachment; filename=movie.mkv")
+				w.Write(content)
+			})
+			mux.HandleFunc("/checksum", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(checksum))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			mount := t.TempDir()
+			os.WriteFile(filepath.Join(mount, "movie.mkv"), tt.sharedContent, os.ModePerm)
+			job := &model.WorkTaskEncode{
+				TaskEncode: &model.TaskEncode{
+					Id:          uuid.New(),
+					DownloadURL: server.URL + "/download",
+					ChecksumURL: server.URL + "/checksum",
+					SourcePath:  "/data/movies/movie.mkv",
+				},
+				WorkDir: t.TempDir(),
+			}
+
+			printer := NewConsoleWorkerPrinter()
+			worker := &EncodeWorker{
+				ctx:          context.Background(),
+				terminal:     printer,
+				workerConfig: Config{PathMappings: []PathMapping{{Server: "/data/movies", Worker: mount}}},
+			}
+			if err := worker.downloadFile(job, printer.AddTask(job.TaskEncode.Id.String(), DownloadJobStepType)); err != nil {
+				t.Fatalf("downloadFile() error = %v", err)
+			}
+
+			if shared := job.SourceFilePath == filepath.Join(mount, "movie.mkv"); shared != tt.wantShared {
+				t.Errorf("SourceFilePath = %s, shared %v, want %v", job.SourceFilePath, shared, tt.wantShared)
+			}
+			if wantDownloads := map[bool]int{true: 0, false: 1}[tt.wantShared]; downloads != wantDownloads {
+				t.Errorf("downloads = %d, want %d", downloads, wantDownloads)
+			}
+		})
+	}
+}
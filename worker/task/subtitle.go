@@ -0,0 +1,103 @@
+package task
+
+import (
+	"fmt"
+	"gearr/model"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const sidecarFilePrefix = "sidecar."
+
+var sdhRegex = regexp.MustCompile(`(?i)\b(sdh|cc|hearing impaired)\b`)
+
+// selectSubtitleStreams removes the subtitles that the policy does not keep.
+func selectSubtitleStreams(subtitles []*Subtitle, policy model.SubtitlePolicy) []*Subtitle {
+	var selected []*Subtitle
+	for _, subtitle := range subtitles {
+		if len(policy.Languages) > 0 && !containsLanguage(policy.Languages, subtitle.Language) {
+			continue
+		}
+		if policy.DropForced && subtitle.Forced {
+			continue
+		}
+		if policy.DropSDH && subtitle.SDH {
+			continue
+		}
+		selected = append(selected, subtitle)
+	}
+	return selected
+}
+
+// sidecarName returns the name suffix of the sidecar file, the destination
+// file name without extension is prepended by the server.
+func (S *Subtitle) sidecarName() string {
+	language := S.Language
+	if language == "" {
+		language = "und"
+	}
+	name := language
+	if S.Forced {
+		name += ".forced"
+	}
+	if S.SDH {
+		name += ".sdh"
+	}
+	return name
+}
+
+// writeSidecars copies the SRT converted from image subtitles to the work
+// directory with their sidecar name, they are uploaded after the video.
+func writeSidecars(workDir string, subtitles []*Subtitle) error {
+	used := make(map[string]bool)
+	for _, subtitle := range subtitles {
+		if !subtitle.isImageTypeSubtitle() {
+			continue
+		}
+		name := subtitle.sidecarName()
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s.%d", subtitle.sidecarName(), i)
+		}
+		used[name] = true
+
+		err := copyFile(filepath.Join(workDir, fmt.Sprintf("%d.srt", subtitle.Id)), filepath.Join(workDir, sidecarFilePrefix+name+".srt"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listSidecars returns the sidecar files waiting to be uploaded, indexed by
+// the name sent to the server.
+func listSidecars(workDir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(workDir, sidecarFilePrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sidecars := make(map[string]string, len(paths))
+	for _, path := range paths {
+		sidecars[strings.TrimPrefix(filepath.Base(path), sidecarFilePrefix)] = path
+	}
+	return sidecars, nil
+}
+
+func copyFile(source string, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	return err
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"fmt"
+	"gearr/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testSubtitles() []*Subtitle {
+	return []*Subtitle{
+		{Id: 3, Language: "eng", Format: "subrip"},
+		{Id: 4, Language: "eng", Format: "hdmv_pgs_subtitle", Forced: true},
+		{Id: 5, Language: "eng", Format: "hdmv_pgs_subtitle", SDH: true},
+		{Id: 6, Language: "spa", Format: "hdmv_pgs_subtitle"},
+	}
+}
+
+func subtitleIds(subtitles []*Subtitle) []uint8 {
+	var ids []uint8
+	for _, subtitle := range subtitles {
+		ids = append(ids, subtitle.Id)
+	}
+	return ids
+}
+
+func TestSelectSubtitleStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   model.SubtitlePolicy
+		expected []uint8
+	}{
+		{"zero policy keeps everything", model.SubtitlePolicy{}, []uint8{3, 4, 5, 6}},
+		{"languages", model.SubtitlePolicy{Languages: []string{"spa"}}, []uint8{6}},
+		{"drop forced", model.SubtitlePolicy{DropForced: true}, []uint8{3, 5, 6}},
+		{"drop sdh", model.SubtitlePolicy{Languages: []string{"eng"}, DropSDH: true}, []uint8{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := subtitleIds(selectSubtitleStreams(testSubtitles(), tt.policy))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("selectSubtitleStreams() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteSidecars(t *testing.T) {
+	workDir := t.TempDir()
+	subtitles := append(testSubtitles(), &Subtitle{Id: 7, Language: "spa", Format: "hdmv_pgs_subtitle"})
+	for _, subtitle := range subtitles {
+		if subtitle.isImageTypeSubtitle() {
+			if err := os.WriteFile(filepath.Join(workDir, fmt.Sprintf("%d.srt", subtitle.Id)), []byte("1"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := writeSidecars(workDir, subtitles); err != nil {
+		t.Fatalf("writeSidecars() unexpected error: %v", err)
+	}
+	sidecars, err := listSidecars(workDir)
+	if err != nil {
+		t.Fatalf("listSidecars() unexpected error: %v", err)
+	}
+
+	var names []string
+	for name := range sidecars {
+		names = append(names, name)
+	}
+	expected := []string{"eng.forced.srt", "eng.sdh.srt", "spa.2.srt", "spa.srt"}
+	if len(names) != len(expected) {
+		t.Fatalf("listSidecars() = %v, want %v", names, expected)
+	}
+	for _, name := range expected {
+		if _, ok := sidecars[name]; !ok {
+			t.Errorf("sidecar %s not found in %v", name, names)
+		}
+	}
+}
+
+func TestFFMPEGGenerator_sidecarSubtitles(t *testing.T) {
+	profile := model.DefaultEncodingProfile()
+	profile.Subtitle.Sidecar = true
+	container := &ContainerData{Subtitle: testSubtitles()}
+
+	ffmpeg := NewFFMPEGGenerator(&profile)
+	ffmpeg.setInputFilters(container, "source.mkv", "/tmp")
+	ffmpeg.setSubtFilters(container)
+
+	if len(ffmpeg.inputPaths) != 1 {
+		t.Errorf("inputPaths = %v, want only the source", ffmpeg.inputPaths)
+	}
+	if len(ffmpeg.SubtitleFilter) != 1 || joinArguments(ffmpeg.SubtitleFilter[0]) != "-map 0:3 -c:s:0 copy" {
+		t.Errorf("SubtitleFilter = %v, want only the text subtitle", ffmpeg.SubtitleFilter)
+	}
+}
@@ -0,0 +1,151 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gearr/model"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/avast/retry-go/v5"
+)
+
+// uploadChunkSize is the size of the chunks of an encoded file, a failed
+// request only resends its chunk.
+const uploadChunkSize = 16 * 1024 * 1024
+
+// uploadChunked sends the encoded file through the upload session of the job.
+// Every attempt asks the server for the offset it stored, so retries and
+// workers restarted by resumeJobs continue the upload where it stopped.
+func (J *EncodeWorker) uploadChunked(ctx context.Context, pause *jobPause, uploadURL string, filePath string, track *TaskTracks) error {
+	sessionURL := uploadURL + "/session"
+	size, checksum, err := fileChecksum(filePath)
+	if err != nil {
+		return err
+	}
+	track.SetTotal(size)
+
+	return retry.New(
+		retry.Delay(time.Second*5),
+		retry.RetryIf(func(err error) bool {
+			return !errors.Is(err, context.Canceled)
+		}),
+		retry.DelayType(retry.FixedDelay),
+		retry.Attempts(uploadRetryAttempts),
+		retry.LastErrorOnly(true),
+		retry.OnRetry(func(n uint, err error) {
+			J.terminal.Error("error on uploading job %s", err.Error())
+		}),
+	).Do(func() error {
+		session, err := J.startUploadSession(ctx, sessionURL, size)
+		if err != nil {
+			return err
+		}
+		track.UpdateValue(session.Offset)
+
+		encodedFile, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer encodedFile.Close()
+
+		chunk := make([]byte, uploadChunkSize)
+		for offset := session.Offset; offset < size; {
+			if err := pause.Wait(ctx); err != nil {
+				return err
+			}
+			n, err := encodedFile.ReadAt(chunk[:min(uploadChunkSize, size-offset)], offset)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			if offset, err = J.uploadChunk(ctx, sessionURL, chunk[:n], offset, size); err != nil {
+				return err
+			}
+			track.UpdateValue(offset)
+		}
+
+		return J.finalizeUpload(ctx, sessionURL, checksum)
+	})
+}
+
+// startUploadSession starts the upload session, or resumes the one with the
+// same size, and returns the offset to upload from.
+func (J *EncodeWorker) startUploadSession(ctx context.Context, sessionURL string, size int64) (*model.UploadSession, error) {
+	body, err := json.Marshal(model.UploadSession{Size: size})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sessionURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	session := &model.UploadSession{}
+	return session, doUploadRequest(req, http.StatusOK, session)
+}
+
+// uploadChunk sends a chunk at offset and returns the new offset of the upload.
+func (J *EncodeWorker) uploadChunk(ctx context.Context, sessionURL string, chunk []byte, offset int64, size int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURL, bytes.NewReader(chunk))
+	if err != nil {
+		return offset, err
+	}
+	sha := sha256.Sum256(chunk)
+	req.Header.Set("checksum", hex.EncodeToString(sha[:]))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, size))
+	session := &model.UploadSession{}
+	if err := doUploadRequest(req, http.StatusOK, session); err != nil {
+		return offset, err
+	}
+	return session.Offset, nil
+}
+
+// finalizeUpload asks the server to check the whole file and move it to the
+// destination of the job.
+func (J *EncodeWorker) finalizeUpload(ctx context.Context, sessionURL string, checksum string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sessionURL+"/finalize", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("checksum", checksum)
+	return doUploadRequest(req, http.StatusCreated, nil)
+}
+
+func doUploadRequest(req *http.Request, expectedStatus int, response interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedStatus {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("invalid status code %d on %s %s: %s", resp.StatusCode, req.Method, req.URL.Path, body)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// fileChecksum returns the size and the sha256 of a file.
+func fileChecksum(filePath string) (int64, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	sha := sha256.New()
+	size, err := io.Copy(sha, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(sha.Sum(nil)), nil
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gearr/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadChunkedResume(t *testing.T) {
+	content := bytes.Repeat([]byte("encoded "), 100)
+	// the server kept the first bytes of an interrupted upload
+	received := append([]byte{}, content[:300]...)
+	var contentRanges []string
+	finalized := ""
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload/session", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			contentRanges = append(contentRanges, r.Header.Get("Content-Range"))
+			chunk, _ := io.ReadAll(r.Body)
+			received = append(received, chunk...)
+		}
+		json.NewEncoder(w).Encode(model.UploadSession{Size: int64(len(content)), Offset: int64(len(received))})
+	})
+	mux.HandleFunc("/upload/session/finalize", func(w http.ResponseWriter, r *http.Request) {
+		finalized = r.Header.Get("checksum")
+		w.WriteHeader(http.StatusCreated)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "movie-encoded.mkv")
+	os.WriteFile(filePath, content, os.ModePerm)
+	printer := NewConsoleWorkerPrinter()
+	worker := &EncodeWorker{ctx: context.Background(), terminal: printer}
+	if err := worker.uploadChunked(context.Background(), nil, server.URL+"/upload", filePath, printer.AddTask("upload", UploadJobStepType)); err != nil {
+		t.Fatalf("uploadChunked() error = %v", err)
+	}
+
+	wantRange := fmt.Sprintf("bytes 300-%d/%d", len(content)-1, len(content))
+	if len(contentRanges) != 1 || contentRanges[0] != wantRange {
+		t.Errorf("chunks = %q, want only %q", contentRanges, wantRange)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("server received %d bytes, want the %d bytes of the file", len(received), len(content))
+	}
+	_, checksum, _ := fileChecksum(filePath)
+	if finalized != checksum {
+		t.Errorf("finalize checksum = %q, want %q", finalized, checksum)
+	}
+}
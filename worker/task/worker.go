@@ -45,7 +45,7 @@ func (W *WorkerRuntime) Run(wg *sync.WaitGroup, ctx context.Context) {
 	}()
 }
 func (W *WorkerRuntime) start(ctx context.Context) {
-	if W.config.Jobs.IsAccepted(model.EncodeJobType) {
+	if len(W.config.Jobs.Accepted(model.EncodeJobTypes)) > 0 {
 		W.EncodeWorker = NewEncodeWorker(ctx, W.config, fmt.Sprintf("%s-%d", model.EncodeJobType, 1), W.printer)
 		W.brokerClient.RegisterEncodeWorker(W.EncodeWorker)
 		W.EncodeWorker.Initialize()